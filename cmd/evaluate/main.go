@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/db"
+	"github.com/amcishara/web_Tracking_system/models"
+	"gorm.io/gorm"
+)
+
+// Offline evaluation of the recommendation strategies.
+// Copies everything known before a cutoff into a scratch schema, runs the
+// registered strategies against it and scores them on what visitors went on
+// to view afterwards. The application schema is only read.
+func main() {
+	k := flag.Int("k", 10, "number of recommendations scored per visitor")
+	split := flag.Float64("split", 0.8, "share of views (by time) used for training")
+	cutoffFlag := flag.String("cutoff", "", "explicit train/test cutoff (RFC3339), overrides -split")
+	format := flag.String("format", "markdown", "report format: markdown or json")
+	out := flag.String("out", "", "write the report to this file instead of stdout")
+	strategiesFlag := flag.String("strategies", "collaborative,category,price_range,trending,popularity,recent_categories,co_viewed", "comma-separated registered strategies to evaluate")
+	snapshotDB := flag.String("snapshot-db", "", "scratch schema for the train snapshot (default <database>_eval), created and dropped by the run")
+	flag.Parse()
+
+	if *k <= 0 {
+		log.Fatal("-k must be positive")
+	}
+	if *split <= 0 || *split >= 1 {
+		log.Fatal("-split must be between 0 and 1")
+	}
+	if *format != "markdown" && *format != "json" {
+		log.Fatalf("unknown format: %s", *format)
+	}
+
+	var strategies []string
+	for _, name := range strings.Split(*strategiesFlag, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := models.GetRecommender(name); !ok {
+			log.Fatalf("unknown strategy: %s", name)
+		}
+		strategies = append(strategies, name)
+	}
+
+	// Plain connection: an offline report must not migrate the schema
+	database, err := db.Connect()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	records, err := models.LoadInteractionLog(database)
+	if err != nil {
+		log.Fatalf("Failed to load interactions: %v", err)
+	}
+	catalog, err := models.LoadCatalog(database)
+	if err != nil {
+		log.Fatalf("Failed to load products: %v", err)
+	}
+	if len(records) == 0 {
+		log.Fatal("No interactions to evaluate")
+	}
+
+	cutoff := models.SplitCutoff(records, *split)
+	if *cutoffFlag != "" {
+		cutoff, err = time.Parse(time.RFC3339, *cutoffFlag)
+		if err != nil {
+			log.Fatalf("Invalid cutoff: %v", err)
+		}
+	}
+
+	var report models.EvalReport
+	err = models.WithTrainSnapshot(database, cutoff, *snapshotDB, func(snapshot *gorm.DB) error {
+		report, err = models.Evaluate(snapshot, records, catalog, cutoff, *k, strategies)
+		return err
+	})
+	if err != nil {
+		log.Fatalf("Evaluation failed: %v", err)
+	}
+
+	var output []byte
+	if *format == "json" {
+		output, err = json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode report: %v", err)
+		}
+		output = append(output, '\n')
+	} else {
+		output = []byte(report.Markdown())
+	}
+
+	if *out == "" {
+		fmt.Print(string(output))
+		return
+	}
+	if err := os.WriteFile(*out, output, 0644); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+	log.Printf("Report written to %s", *out)
+}
@@ -13,7 +13,8 @@ import (
 
 var DB *gorm.DB
 
-func InitDB() (*gorm.DB, error) {
+// Connect opens a connection to the configured database without touching the schema
+func Connect() (*gorm.DB, error) {
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
 		log.Printf("Warning: .env file not found, using default values")
@@ -40,6 +41,14 @@ func InitDB() (*gorm.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %v", err)
 	}
+	return db, nil
+}
+
+func InitDB() (*gorm.DB, error) {
+	db, err := Connect()
+	if err != nil {
+		return nil, err
+	}
 
 	DB = db // Set the global DB variable
 
@@ -58,12 +67,21 @@ func InitDB() (*gorm.DB, error) {
 				email VARCHAR(255) NOT NULL UNIQUE,
 				password VARCHAR(255) NOT NULL,
 				role VARCHAR(50) DEFAULT 'user',
-				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				email_verified_at TIMESTAMP NULL
 			)
 		`).Error
 		if err != nil {
 			log.Fatal("Failed to create users table:", err)
 		}
+	} else if !DB.Migrator().HasColumn(&models.User{}, "email_verified_at") {
+		// Accounts created before email verification existed count as verified
+		if err := DB.Migrator().AddColumn(&models.User{}, "EmailVerifiedAt"); err != nil {
+			log.Fatal("Failed to add users.email_verified_at:", err)
+		}
+		if err := DB.Exec("UPDATE users SET email_verified_at = created_at").Error; err != nil {
+			log.Fatal("Failed to mark existing users verified:", err)
+		}
 	}
 
 	if !hasProducts {
@@ -118,15 +136,55 @@ func InitDB() (*gorm.DB, error) {
 		}
 	}
 
+	// Sessions used to be keyed by the plaintext token; drop them so users sign in again
+	if DB.Migrator().HasColumn(&models.Session{}, "token") {
+		if err := DB.Migrator().DropTable(&models.Session{}); err != nil {
+			log.Fatal("Failed to drop legacy sessions table:", err)
+		}
+	}
+
 	// Create other tables only if they don't exist
 	err = DB.AutoMigrate(
 		&models.Session{},
 		&models.CartItem{},
+		&models.Event{},
+		&models.GuestUserLink{},
+		&models.ProductViewBucket{},
+		&models.ProductSimilarity{},
+		&models.Experiment{},
+		&models.ExperimentVariant{},
+		&models.ExperimentExposure{},
+		&models.ExperimentExposureItem{},
+		&models.Order{},
+		&models.OrderItem{},
+		&models.InventoryMovement{},
+		&models.Payment{},
+		&models.PaymentWebhookEvent{},
+		&models.StockReservation{},
+		&models.Promotion{},
+		&models.PromotionRedemption{},
+		&models.CartCoupon{},
+		&models.AbandonedCart{},
+		&models.AbandonedCartItem{},
+		&models.Wishlist{},
+		&models.WishlistItem{},
+		&models.RefreshToken{},
+		&models.AccountToken{},
+		&models.EmailMessage{},
+		&models.LoginThrottle{},
+		&models.LoginAuditEvent{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// Cart items no longer reference products by foreign key so they outlive a deleted product
+	if DB.Migrator().HasConstraint(&models.CartItem{}, "fk_cart_items_product") {
+		if err := DB.Migrator().DropConstraint(&models.CartItem{}, "fk_cart_items_product"); err != nil {
+			log.Fatal("Failed to drop cart item product constraint:", err)
+		}
+	}
+
 	fmt.Println("Database connection and migration completed successfully")
 	return DB, nil
 }
@@ -10,6 +10,32 @@ import (
 
 func Migrate(db *gorm.DB) error {
 	// Drop existing tables in correct order
+	db.Migrator().DropTable(&models.LoginAuditEvent{})
+	db.Migrator().DropTable(&models.LoginThrottle{})
+	db.Migrator().DropTable(&models.EmailMessage{})
+	db.Migrator().DropTable(&models.AccountToken{})
+	db.Migrator().DropTable(&models.RefreshToken{})
+	db.Migrator().DropTable(&models.WishlistItem{})
+	db.Migrator().DropTable(&models.Wishlist{})
+	db.Migrator().DropTable(&models.AbandonedCartItem{})
+	db.Migrator().DropTable(&models.AbandonedCart{})
+	db.Migrator().DropTable(&models.CartCoupon{})
+	db.Migrator().DropTable(&models.PromotionRedemption{})
+	db.Migrator().DropTable(&models.Promotion{})
+	db.Migrator().DropTable(&models.StockReservation{})
+	db.Migrator().DropTable(&models.PaymentWebhookEvent{})
+	db.Migrator().DropTable(&models.Payment{})
+	db.Migrator().DropTable(&models.InventoryMovement{})
+	db.Migrator().DropTable(&models.OrderItem{})
+	db.Migrator().DropTable(&models.Order{})
+	db.Migrator().DropTable(&models.ExperimentExposureItem{})
+	db.Migrator().DropTable(&models.ExperimentExposure{})
+	db.Migrator().DropTable(&models.ExperimentVariant{})
+	db.Migrator().DropTable(&models.Experiment{})
+	db.Migrator().DropTable(&models.ProductSimilarity{})
+	db.Migrator().DropTable(&models.ProductViewBucket{})
+	db.Migrator().DropTable(&models.GuestUserLink{})
+	db.Migrator().DropTable(&models.Event{})
 	db.Migrator().DropTable(&models.CartItem{})
 	db.Migrator().DropTable(&models.GuestInteraction{})
 	db.Migrator().DropTable(&models.Product{})
@@ -37,5 +63,109 @@ func Migrate(db *gorm.DB) error {
 		return fmt.Errorf("failed to migrate guest interactions table: %v", err)
 	}
 
+	if err := db.AutoMigrate(&models.Event{}); err != nil {
+		return fmt.Errorf("failed to migrate events table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.GuestUserLink{}); err != nil {
+		return fmt.Errorf("failed to migrate guest user links table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.ProductViewBucket{}); err != nil {
+		return fmt.Errorf("failed to migrate product view buckets table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.ProductSimilarity{}); err != nil {
+		return fmt.Errorf("failed to migrate product similarities table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.Experiment{}); err != nil {
+		return fmt.Errorf("failed to migrate experiments table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.ExperimentVariant{}); err != nil {
+		return fmt.Errorf("failed to migrate experiment variants table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.ExperimentExposure{}); err != nil {
+		return fmt.Errorf("failed to migrate experiment exposures table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.ExperimentExposureItem{}); err != nil {
+		return fmt.Errorf("failed to migrate experiment exposure items table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.Order{}); err != nil {
+		return fmt.Errorf("failed to migrate orders table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.OrderItem{}); err != nil {
+		return fmt.Errorf("failed to migrate order items table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.InventoryMovement{}); err != nil {
+		return fmt.Errorf("failed to migrate inventory movements table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.Payment{}); err != nil {
+		return fmt.Errorf("failed to migrate payments table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.PaymentWebhookEvent{}); err != nil {
+		return fmt.Errorf("failed to migrate payment webhook events table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.StockReservation{}); err != nil {
+		return fmt.Errorf("failed to migrate stock reservations table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.Promotion{}); err != nil {
+		return fmt.Errorf("failed to migrate promotions table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.PromotionRedemption{}); err != nil {
+		return fmt.Errorf("failed to migrate promotion redemptions table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.CartCoupon{}); err != nil {
+		return fmt.Errorf("failed to migrate cart coupons table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.AbandonedCart{}); err != nil {
+		return fmt.Errorf("failed to migrate abandoned carts table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.AbandonedCartItem{}); err != nil {
+		return fmt.Errorf("failed to migrate abandoned cart items table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.Wishlist{}); err != nil {
+		return fmt.Errorf("failed to migrate wishlists table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.WishlistItem{}); err != nil {
+		return fmt.Errorf("failed to migrate wishlist items table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.RefreshToken{}); err != nil {
+		return fmt.Errorf("failed to migrate refresh tokens table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.AccountToken{}); err != nil {
+		return fmt.Errorf("failed to migrate account tokens table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.EmailMessage{}); err != nil {
+		return fmt.Errorf("failed to migrate email outbox table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.LoginThrottle{}); err != nil {
+		return fmt.Errorf("failed to migrate login throttles table: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.LoginAuditEvent{}); err != nil {
+		return fmt.Errorf("failed to migrate login audit events table: %v", err)
+	}
+
 	return nil
 }
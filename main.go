@@ -1,8 +1,18 @@
 package main
 
 import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/amcishara/web_Tracking_system/db"
+	"github.com/amcishara/web_Tracking_system/models"
 	"github.com/amcishara/web_Tracking_system/routes"
+	"github.com/amcishara/web_Tracking_system/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,6 +23,243 @@ func main() {
 	// Initialize database
 	db.InitDB()
 
+	// Start buffered view ingestion
+	defaults := models.DefaultIngestionConfig()
+	models.StartIngestion(db.DB, models.IngestionConfig{
+		QueueSize:     utils.GetEnvInt("INGEST_QUEUE_SIZE", defaults.QueueSize),
+		Workers:       utils.GetEnvInt("INGEST_WORKERS", defaults.Workers),
+		BatchSize:     utils.GetEnvInt("INGEST_BATCH_SIZE", defaults.BatchSize),
+		FlushInterval: utils.GetEnvDuration("INGEST_FLUSH_INTERVAL", defaults.FlushInterval),
+		BlockTimeout:  utils.GetEnvDuration("INGEST_BLOCK_TIMEOUT", defaults.BlockTimeout),
+	})
+
+	// Weight guest histories and wishlists against user histories in co-view recommendations
+	models.CollaborativeWeights = models.CoViewWeights{
+		User:     utils.GetEnvFloat("RECO_USER_WEIGHT", models.CollaborativeWeights.User),
+		Guest:    utils.GetEnvFloat("RECO_GUEST_WEIGHT", models.CollaborativeWeights.Guest),
+		Wishlist: utils.GetEnvFloat("RECO_WISHLIST_WEIGHT", models.CollaborativeWeights.Wishlist),
+	}
+
+	// Override recommendation placements, e.g. {"homepage":{"strategies":[{"strategy":"trending","weight":1}],"limit":8}}
+	if placements := utils.GetEnv("RECO_PLACEMENTS", ""); placements != "" {
+		if err := models.LoadPlacements(placements); err != nil {
+			log.Fatalf("Failed to load recommendation placements: %v", err)
+		}
+	}
+
+	// Periodically rebuild the item-to-item similarity index
+	similarityDefaults := models.DefaultSimilarityConfig()
+	models.SimilaritySettings = models.SimilarityConfig{
+		Metric:             utils.GetEnv("SIMILARITY_METRIC", similarityDefaults.Metric),
+		MinSupport:         utils.GetEnvInt("SIMILARITY_MIN_SUPPORT", similarityDefaults.MinSupport),
+		TopK:               utils.GetEnvInt("SIMILARITY_TOP_K", similarityDefaults.TopK),
+		MaxItemsPerVisitor: similarityDefaults.MaxItemsPerVisitor,
+		Weights:            models.CollaborativeWeights,
+	}
+	rebuildSimilarities := func() error {
+		return models.ComputeProductSimilarities(db.DB, models.SimilaritySettings)
+	}
+	go func() {
+		if err := rebuildSimilarities(); err != nil {
+			log.Printf("Initial similarity build failed: %v", err)
+		}
+	}()
+	stopSimilarities := models.StartJob("compute-similarities",
+		jobInterval("SIMILARITY_INTERVAL", 15*time.Minute), rebuildSimilarities)
+
+	// Prune view buckets that have aged out of every trending window
+	stopPruner := models.StartJob("prune-view-buckets", time.Hour, func() error {
+		return models.PruneViewBuckets(db.DB, 8*24*time.Hour)
+	})
+
+	// JWT signing keys: a key file with kid-identified keys, re-read so new keys and rotations
+	// take effect without a restart, or a single HS256 JWT_SECRET
+	stopKeyReload := func() {}
+	if path := utils.GetEnv("JWT_KEYS_FILE", ""); path != "" {
+		keys, err := utils.LoadKeySetFile(path)
+		if err != nil {
+			log.Fatalf("Failed to load JWT keys: %v", err)
+		}
+		utils.SetKeySet(keys)
+		stopKeyReload = models.StartJob("reload-jwt-keys",
+			jobInterval("JWT_KEYS_RELOAD_INTERVAL", 5*time.Minute), func() error {
+				keys, err := utils.LoadKeySetFile(path)
+				if err != nil {
+					return err // Keep the keys that were loaded last
+				}
+				utils.SetKeySet(keys)
+				return nil
+			})
+	} else if secret := utils.GetEnv("JWT_SECRET", ""); secret != "" {
+		keys, err := utils.NewKeySet([]*utils.SigningKey{utils.NewHMACKey("default", []byte(secret))})
+		if err != nil {
+			log.Fatalf("Invalid JWT_SECRET: %v", err)
+		}
+		utils.SetKeySet(keys)
+	} else {
+		log.Println("Warning: JWT_KEYS_FILE and JWT_SECRET are unset, signing tokens with the development secret")
+	}
+
+	// Short-lived access tokens renewed by rotating refresh tokens; expired ones are pruned hourly
+	utils.AccessTokenTTL = utils.GetEnvDuration("ACCESS_TOKEN_TTL", utils.AccessTokenTTL)
+	models.RefreshTokenTTL = utils.GetEnvDuration("REFRESH_TOKEN_TTL", models.RefreshTokenTTL)
+	stopTokenPruner := models.StartJob("prune-auth-tokens", time.Hour, func() error {
+		if _, err := models.PruneAuthTokens(db.DB); err != nil {
+			return err
+		}
+		_, err := models.PruneLoginThrottles(db.DB, time.Now())
+		return err
+	})
+
+	// Brute-force protection on login: a delay that doubles with each failure, then a lockout
+	// that doubles with each further lockout; counts are shared by every instance through the database
+	loginDefaults := models.DefaultLoginProtectionConfig()
+	models.LoginProtection = models.LoginProtectionConfig{
+		AccountMaxFailures: utils.GetEnvInt("LOGIN_MAX_FAILURES", loginDefaults.AccountMaxFailures),
+		IPMaxFailures:      utils.GetEnvInt("LOGIN_IP_MAX_FAILURES", loginDefaults.IPMaxFailures),
+		FailureWindow:      utils.GetEnvDuration("LOGIN_FAILURE_WINDOW", loginDefaults.FailureWindow),
+		BaseDelay:          utils.GetEnvDuration("LOGIN_BASE_DELAY", loginDefaults.BaseDelay),
+		MaxDelay:           utils.GetEnvDuration("LOGIN_MAX_DELAY", loginDefaults.MaxDelay),
+		LockoutDuration:    utils.GetEnvDuration("LOGIN_LOCKOUT", loginDefaults.LockoutDuration),
+		MaxLockoutDuration: utils.GetEnvDuration("LOGIN_MAX_LOCKOUT", loginDefaults.MaxLockoutDuration),
+	}
+
+	// Email verification and password reset links, and what unverified accounts may do
+	models.EmailVerificationURL = utils.GetEnv("EMAIL_VERIFY_URL", models.EmailVerificationURL)
+	models.PasswordResetURL = utils.GetEnv("PASSWORD_RESET_URL", models.PasswordResetURL)
+	models.EmailVerificationTTL = utils.GetEnvDuration("EMAIL_VERIFICATION_TTL", models.EmailVerificationTTL)
+	models.PasswordResetTTL = utils.GetEnvDuration("PASSWORD_RESET_TTL", models.PasswordResetTTL)
+	models.UnverifiedAccountPolicy = utils.GetEnv("UNVERIFIED_ACCOUNT_POLICY", models.UnverifiedAccountPolicy)
+	if !models.ValidUnverifiedPolicies[models.UnverifiedAccountPolicy] {
+		log.Fatalf("Invalid UNVERIFIED_ACCOUNT_POLICY: %s", models.UnverifiedAccountPolicy)
+	}
+
+	// Deliver the email outbox over SMTP, or to .eml files in MAIL_DIR for development.
+	// Without either, emails wait in the outbox.
+	mailFrom := utils.GetEnv("MAIL_FROM", "no-reply@localhost")
+	if addr := utils.GetEnv("SMTP_ADDR", ""); addr != "" {
+		models.DefaultMailer = models.NewSMTPMailer(addr,
+			utils.GetEnv("SMTP_USERNAME", ""), utils.GetEnv("SMTP_PASSWORD", ""), mailFrom)
+	} else if dir := utils.GetEnv("MAIL_DIR", ""); dir != "" {
+		mailer, err := models.NewFileMailer(dir, mailFrom)
+		if err != nil {
+			log.Fatalf("Failed to set up file mailer: %v", err)
+		}
+		models.DefaultMailer = mailer
+	} else {
+		log.Println("Warning: SMTP_ADDR and MAIL_DIR are unset, emails stay in the outbox")
+	}
+	var stopEmailDelivery func()
+	if models.DefaultMailer != nil {
+		stopEmailDelivery = models.StartJob("deliver-emails",
+			jobInterval("EMAIL_DELIVERY_INTERVAL", 30*time.Second), func() error {
+				_, err := models.DeliverEmails(db.DB, models.DefaultMailer, 100)
+				return err
+			})
+	}
+
+	// Release cart stock reservations once they expire
+	models.ReservationTTL = utils.GetEnvDuration("CART_RESERVATION_TTL", models.ReservationTTL)
+	stopReservationSweeper := models.StartJob("release-reservations",
+		jobInterval("RESERVATION_SWEEP_INTERVAL", time.Minute), func() error {
+			released, err := models.ReleaseExpiredReservations(db.DB)
+			if released > 0 {
+				log.Printf("Released %d expired stock reservations", released)
+			}
+			return err
+		})
+
+	// Cancel orders left unpaid so their stock goes back on sale
+	models.PendingOrderTTL = utils.GetEnvDuration("PENDING_ORDER_TTL", models.PendingOrderTTL)
+	stopOrderSweeper := models.StartJob("expire-pending-orders",
+		jobInterval("PENDING_ORDER_SWEEP_INTERVAL", time.Minute), func() error {
+			cancelled, err := models.ExpirePendingOrders(db.DB, time.Now().Add(-models.PendingOrderTTL))
+			if cancelled > 0 {
+				log.Printf("Cancelled %d unpaid orders", cancelled)
+			}
+			return err
+		})
+
+	// Abandoned cart detection, with an optional webhook for marketing follow-up
+	models.AbandonedCartAfter = utils.GetEnvDuration("CART_ABANDON_AFTER", models.AbandonedCartAfter)
+	if url := utils.GetEnv("ABANDONED_CART_WEBHOOK_URL", ""); url != "" {
+		models.DefaultAbandonedCartNotifier = models.NewWebhookNotifier(url, utils.GetEnv("ABANDONED_CART_WEBHOOK_SECRET", ""))
+	}
+	stopAbandonedCartScan := models.StartJob("detect-abandoned-carts",
+		jobInterval("ABANDONED_CART_SCAN_INTERVAL", 5*time.Minute), func() error {
+			detected, err := models.DetectAbandonedCarts(db.DB, time.Now())
+			if err != nil {
+				return err
+			}
+			if detected > 0 {
+				log.Printf("Detected %d abandoned carts", detected)
+			}
+			if models.DefaultAbandonedCartNotifier == nil {
+				return nil
+			}
+			_, err = models.NotifyAbandonedCarts(db.DB, models.DefaultAbandonedCartNotifier)
+			return err
+		})
+
+	// Wishlist price drop and back in stock alerts, sent only when a webhook is configured
+	var stopWishlistAlerts func()
+	if url := utils.GetEnv("WISHLIST_WEBHOOK_URL", ""); url != "" {
+		models.DefaultWishlistNotifier = models.NewWebhookNotifier(url, utils.GetEnv("WISHLIST_WEBHOOK_SECRET", ""))
+		stopWishlistAlerts = models.StartJob("check-wishlist-alerts",
+			jobInterval("WISHLIST_ALERT_INTERVAL", 15*time.Minute), func() error {
+				sent, err := models.CheckWishlistAlerts(db.DB, models.DefaultWishlistNotifier)
+				if sent > 0 {
+					log.Printf("Sent %d wishlist alerts", sent)
+				}
+				return err
+			})
+	}
+
+	// How guest carts merge into user carts at login: sum, max or cap
+	models.CartMergeRule = utils.GetEnv("CART_MERGE_RULE", models.CartMergeRule)
+	if !models.ValidCartMergeRules[models.CartMergeRule] {
+		log.Fatalf("Invalid CART_MERGE_RULE: %s", models.CartMergeRule)
+	}
+
+	// Tax and shipping tables; without them no tax is charged and shipping is free
+	if path := utils.GetEnv("TAX_TABLE_FILE", ""); path != "" {
+		table, err := models.LoadTaxTable(path)
+		if err != nil {
+			log.Fatalf("Failed to load tax table: %v", err)
+		}
+		models.DefaultTaxCalculator = table
+	}
+	if path := utils.GetEnv("SHIPPING_TABLE_FILE", ""); path != "" {
+		table, err := models.LoadShippingTable(path)
+		if err != nil {
+			log.Fatalf("Failed to load shipping table: %v", err)
+		}
+		models.DefaultShippingCalculator = table
+	}
+
+	// Payments need an explicitly chosen provider and a webhook secret; without a provider checkout payments are refused
+	models.DefaultPaymentProvider = utils.GetEnv("PAYMENT_PROVIDER", "")
+	webhookSecret := utils.GetEnv("PAYMENT_WEBHOOK_SECRET", "")
+	if err := models.ValidatePaymentConfig(models.DefaultPaymentProvider, webhookSecret); err != nil {
+		log.Fatalf("Invalid payment configuration: %v", err)
+	}
+	if models.DefaultPaymentProvider == "fake" {
+		// Local fake payment gateway for development; its webhooks are processed in-process like real deliveries
+		gateway := models.NewFakeGateway(webhookSecret)
+		gateway.Delay = utils.GetEnvDuration("FAKE_PAYMENT_DELAY", 2*time.Second)
+		gateway.Duplicates = utils.GetEnvInt("FAKE_PAYMENT_DUPLICATES", 0)
+		gateway.Deliver = func(webhook models.FakeWebhook) {
+			event, err := gateway.VerifyWebhook(webhook.Payload, webhook.Signature)
+			if err == nil {
+				_, err = models.ProcessPaymentWebhook(db.DB, gateway.Name(), event)
+			}
+			if err != nil {
+				log.Printf("Fake payment webhook failed: %v", err)
+			}
+		}
+		models.RegisterPaymentProvider(gateway)
+	}
+
 	// Create router with default middleware
 	router := gin.Default()
 
@@ -23,5 +270,49 @@ func main() {
 	routes.SetupRouter(router)
 
 	// Start server
-	router.Run(":8000")
+	srv := &http.Server{
+		Addr:    ":8000",
+		Handler: router,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	// Wait for interrupt, then stop accepting requests and flush buffered views
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
+	}
+
+	stopReservationSweeper()
+	stopOrderSweeper()
+	stopAbandonedCartScan()
+	if stopWishlistAlerts != nil {
+		stopWishlistAlerts()
+	}
+	if stopEmailDelivery != nil {
+		stopEmailDelivery()
+	}
+	stopPruner()
+	stopTokenPruner()
+	stopKeyReload()
+	stopSimilarities()
+	models.StopIngestion()
+}
+
+// Helper function to read a background job interval, falling back to the default when it is not positive
+func jobInterval(key string, fallback time.Duration) time.Duration {
+	interval := utils.GetEnvDuration(key, fallback)
+	if interval <= 0 {
+		log.Printf("Warning: %s must be positive, using %v", key, fallback)
+		return fallback
+	}
+	return interval
 }
@@ -1,11 +1,13 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/amcishara/web_Tracking_system/db"
 	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -27,6 +29,13 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
+		// Check the signature, kid and expiry so removed or expired keys stop working immediately
+		if _, err := utils.ValidateToken(token); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session"})
+			c.Abort()
+			return
+		}
+
 		// Validate session
 		session, err := models.GetSession(db.DB, token)
 		if err != nil {
@@ -35,8 +44,40 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
-		// Set user ID in context
+		// Record device activity without failing the request
+		if err := models.TouchSession(db.DB, session, c.ClientIP()); err != nil {
+			fmt.Printf("Failed to update session %d: %v\n", session.ID, err)
+		}
+
+		// Set user ID and session ID in context
 		c.Set("user_id", session.UserID)
+		c.Set("session_id", session.ID)
+		c.Next()
+	}
+}
+
+// OptionalAuth sets user_id when a valid session is present but never rejects the request
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get token from cookie or Authorization header
+		token, _ := c.Cookie("token")
+		if token == "" {
+			authHeader := c.GetHeader("Authorization")
+			if len(strings.Split(authHeader, " ")) == 2 {
+				token = strings.Split(authHeader, " ")[1]
+			}
+		}
+
+		if _, err := utils.ValidateToken(token); token != "" && err == nil {
+			if session, err := models.GetSession(db.DB, token); err == nil {
+				if err := models.TouchSession(db.DB, session, c.ClientIP()); err != nil {
+					fmt.Printf("Failed to update session %d: %v\n", session.ID, err)
+				}
+				c.Set("user_id", session.UserID)
+				c.Set("session_id", session.ID)
+			}
+		}
+
 		c.Next()
 	}
 }
@@ -62,3 +62,47 @@ func CustomerMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// CartMiddleware lets guests and customers use the cart; admins are still turned away.
+// It expects OptionalAuth to have run, so user_id is only set for valid sessions.
+func CartMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid := c.GetUint("user_id")
+		if uid == 0 {
+			// Guest cart, keyed by the guest_id cookie in the handlers
+			c.Next()
+			return
+		}
+
+		// Check if user is NOT an admin
+		if models.IsAdmin(db.DB, uid) {
+			c.JSON(403, gin.H{"error": "Cart functionality is for customers only"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// VerifiedEmailRequired turns away users who have not confirmed their email address,
+// unless models.UnverifiedAccountPolicy allows it. It expects AuthRequired to have run.
+func VerifiedEmailRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if models.UnverifiedAccountPolicy == models.UnverifiedPolicyAllow {
+			c.Next()
+			return
+		}
+
+		if !models.IsEmailVerified(db.DB, c.GetUint("user_id")) {
+			c.JSON(403, gin.H{
+				"error":       "Please verify your email address first",
+				"redirect_to": "/verify-email",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,408 @@
+package models
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AbandonedCartAfter is how long a cart must sit idle before it counts as abandoned
+var AbandonedCartAfter = time.Hour
+
+// AbandonedCartMaxAttempts is how many times a notification is tried before giving up
+var AbandonedCartMaxAttempts = 5
+
+// AbandonedCart is a snapshot of a cart that went idle, kept for reporting and follow-up
+type AbandonedCart struct {
+	ID               uint                `gorm:"primaryKey" json:"id"`
+	UserID           uint                `gorm:"not null;default:0;index" json:"user_id,omitempty"` // 0 for guest carts
+	GuestID          string              `gorm:"type:varchar(100);index" json:"guest_id,omitempty"`
+	Email            string              `gorm:"size:255" json:"email,omitempty"`
+	ItemCount        int                 `gorm:"not null" json:"item_count"`
+	Value            float64             `gorm:"not null" json:"value"`
+	LastActivityAt   time.Time           `gorm:"not null;index" json:"last_activity_at"`
+	DetectedAt       time.Time           `gorm:"not null;index" json:"detected_at"`
+	RecoveredAt      *time.Time          `json:"recovered_at,omitempty"`
+	RecoveredOrderID *uint               `json:"recovered_order_id,omitempty"`
+	NotifiedAt       *time.Time          `json:"notified_at,omitempty"`
+	NotifyAttempts   int                 `gorm:"not null;default:0" json:"-"`
+	NotifyError      string              `gorm:"size:255" json:"-"`
+	Items            []AbandonedCartItem `gorm:"foreignKey:AbandonedCartID" json:"items"`
+}
+
+// AbandonedCartItem is a product line of an abandoned cart, priced when the cart was detected
+type AbandonedCartItem struct {
+	ID              uint    `gorm:"primaryKey" json:"-"`
+	AbandonedCartID uint    `gorm:"not null;index" json:"-"`
+	ProductID       uint    `gorm:"not null;index" json:"product_id"`
+	ProductName     string  `gorm:"not null" json:"name"`
+	Category        string  `gorm:"index" json:"category"`
+	UnitPrice       float64 `gorm:"not null" json:"unit_price"`
+	Quantity        int     `gorm:"not null" json:"quantity"`
+	Subtotal        float64 `gorm:"not null" json:"subtotal"`
+}
+
+// TableName overrides the table name
+func (AbandonedCart) TableName() string {
+	return "abandoned_carts"
+}
+
+// TableName overrides the table name
+func (AbandonedCartItem) TableName() string {
+	return "abandoned_cart_items"
+}
+
+// AbandonedCartNotifier hands an abandoned cart to marketing for follow-up
+type AbandonedCartNotifier interface {
+	NotifyAbandonedCart(cart *AbandonedCart) error
+}
+
+// DefaultAbandonedCartNotifier receives new abandoned carts; nil sends nothing
+var DefaultAbandonedCartNotifier AbandonedCartNotifier
+
+// WebhookNotifier posts abandoned carts and wishlist alerts as signed JSON to a URL
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// NewWebhookNotifier creates a webhook notifier with a request timeout
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		Secret: secret,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NotifyAbandonedCart implements AbandonedCartNotifier
+func (n *WebhookNotifier) NotifyAbandonedCart(cart *AbandonedCart) error {
+	return n.post(map[string]interface{}{
+		"type": string(EventCartAbandoned),
+		"cart": cart,
+	})
+}
+
+// NotifyWishlistAlert implements WishlistNotifier
+func (n *WebhookNotifier) NotifyWishlistAlert(alert *WishlistAlert) error {
+	return n.post(map[string]interface{}{
+		"type":  alert.Type,
+		"alert": alert,
+	})
+}
+
+// Helper function to post a payload to the webhook.
+// The body is signed with HMAC-SHA256 in the X-Webhook-Signature header.
+func (n *WebhookNotifier) post(body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.Secret))
+		mac.Write(payload)
+		req.Header.Set("X-Webhook-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// DetectAbandonedCarts snapshots carts idle since before now minus AbandonedCartAfter.
+// A cart is recorded once per idle period; activity after that starts a new one.
+func DetectAbandonedCarts(db *gorm.DB, now time.Time) (int, error) {
+	var owners []struct {
+		UserID       uint
+		GuestID      string
+		LastActivity time.Time
+	}
+	err := db.Model(&CartItem{}).
+		Select("user_id, guest_id, MAX(updated_at) AS last_activity").
+		Group("user_id, guest_id").
+		Having("MAX(updated_at) < ?", now.Add(-AbandonedCartAfter)).
+		Scan(&owners).Error
+	if err != nil {
+		return 0, err
+	}
+
+	detected := 0
+	for _, o := range owners {
+		owner := CartOwner{UserID: o.UserID, GuestID: o.GuestID}
+		if owner.validate() != nil {
+			continue
+		}
+
+		// Skip idle periods that were already recorded
+		var count int64
+		err := owner.scope(db.Model(&AbandonedCart{})).
+			Where("last_activity_at >= ?", o.LastActivity).
+			Count(&count).Error
+		if err != nil {
+			return detected, err
+		}
+		if count > 0 {
+			continue
+		}
+
+		created, err := recordAbandonedCart(db, owner, o.LastActivity, now)
+		if err != nil {
+			return detected, err
+		}
+		if created {
+			detected++
+		}
+	}
+	return detected, nil
+}
+
+// Helper function to snapshot one idle cart and record its abandonment event
+func recordAbandonedCart(db *gorm.DB, owner CartOwner, lastActivity, now time.Time) (bool, error) {
+	var items []CartItem
+	if err := owner.scope(db.Preload("Product")).Find(&items).Error; err != nil {
+		return false, err
+	}
+
+	cart := AbandonedCart{
+		UserID:         owner.UserID,
+		LastActivityAt: lastActivity,
+		DetectedAt:     now,
+	}
+	if owner.UserID == 0 {
+		cart.GuestID = owner.GuestID
+	} else {
+		var user User
+		if err := db.Select("user_id, email").First(&user, owner.UserID).Error; err == nil {
+			cart.Email = user.Email
+		}
+	}
+
+	for _, item := range items {
+		// Deleted products have no value to recover
+		if item.Product.ID == 0 {
+			continue
+		}
+		subtotal := float64(item.Quantity) * item.Product.Price
+		cart.Items = append(cart.Items, AbandonedCartItem{
+			ProductID:   item.ProductID,
+			ProductName: item.Product.Name,
+			Category:    item.Product.Category,
+			UnitPrice:   item.Product.Price,
+			Quantity:    item.Quantity,
+			Subtotal:    subtotal,
+		})
+		cart.ItemCount += item.Quantity
+		cart.Value += subtotal
+	}
+	if len(cart.Items) == 0 {
+		return false, nil
+	}
+	cart.Value = roundCents(cart.Value)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&cart).Error; err != nil {
+			return err
+		}
+		return recordCartEvent(tx, &cart, EventCartAbandoned, EventProperties{
+			"abandoned_cart_id": cart.ID,
+			"item_count":        cart.ItemCount,
+			"value":             cart.Value,
+		})
+	})
+	return err == nil, err
+}
+
+// Helper function to record an abandonment or recovery event for a cart's owner
+func recordCartEvent(tx *gorm.DB, cart *AbandonedCart, eventType EventType, props EventProperties) error {
+	event := Event{Type: eventType, GuestID: cart.GuestID, Properties: props}
+	if cart.UserID != 0 {
+		userID := cart.UserID
+		event.UserID = &userID
+	}
+	return RecordEvent(tx, &event)
+}
+
+// NotifyAbandonedCarts sends carts that were not notified yet and returns how many went out.
+// Failed sends are retried on later runs up to AbandonedCartMaxAttempts.
+func NotifyAbandonedCarts(db *gorm.DB, notifier AbandonedCartNotifier) (int, error) {
+	var carts []AbandonedCart
+	err := db.Preload("Items").
+		Where("notified_at IS NULL AND recovered_at IS NULL AND notify_attempts < ?", AbandonedCartMaxAttempts).
+		Order("id").
+		Find(&carts).Error
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range carts {
+		cart := &carts[i]
+		if notifyErr := notifier.NotifyAbandonedCart(cart); notifyErr != nil {
+			message := notifyErr.Error()
+			if len(message) > 255 {
+				message = message[:255]
+			}
+			err := db.Model(cart).Updates(map[string]interface{}{
+				"notify_attempts": gorm.Expr("notify_attempts + 1"),
+				"notify_error":    message,
+			}).Error
+			if err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		if err := db.Model(cart).Update("notified_at", time.Now()).Error; err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// Helper function to mark a user's open abandoned carts as recovered by an order
+func markCartsRecovered(tx *gorm.DB, userID, orderID uint) error {
+	var carts []AbandonedCart
+	if err := tx.Where("user_id = ? AND recovered_at IS NULL", userID).Find(&carts).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range carts {
+		err := tx.Model(&carts[i]).Updates(map[string]interface{}{
+			"recovered_at":       now,
+			"recovered_order_id": orderID,
+		}).Error
+		if err != nil {
+			return err
+		}
+		err = recordCartEvent(tx, &carts[i], EventCartRecovered, EventProperties{
+			"abandoned_cart_id": carts[i].ID,
+			"order_id":          orderID,
+			"value":             carts[i].Value,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AbandonedProductValue is the abandoned cart value of one product
+type AbandonedProductValue struct {
+	ProductID uint    `json:"product_id"`
+	Name      string  `json:"name"`
+	Category  string  `json:"category"`
+	Carts     int64   `json:"carts"`
+	Quantity  int64   `json:"quantity"`
+	Value     float64 `json:"value"`
+}
+
+// AbandonedCategoryValue is the abandoned cart value of one category
+type AbandonedCategoryValue struct {
+	Category string  `json:"category"`
+	Carts    int64   `json:"carts"`
+	Quantity int64   `json:"quantity"`
+	Value    float64 `json:"value"`
+}
+
+// AbandonedCartReport sums up carts abandoned in a period
+type AbandonedCartReport struct {
+	Since          time.Time                `json:"since"`
+	Until          time.Time                `json:"until"`
+	Carts          int64                    `json:"carts"`
+	Value          float64                  `json:"value"`
+	RecoveredCarts int64                    `json:"recovered_carts"`
+	RecoveredValue float64                  `json:"recovered_value"`
+	RecoveryRate   float64                  `json:"recovery_rate"`
+	ByProduct      []AbandonedProductValue  `json:"by_product"`
+	ByCategory     []AbandonedCategoryValue `json:"by_category"`
+}
+
+// GetAbandonedCartReport reports carts detected as abandoned between since and until
+func GetAbandonedCartReport(db *gorm.DB, since, until time.Time) (*AbandonedCartReport, error) {
+	report := &AbandonedCartReport{
+		Since:      since,
+		Until:      until,
+		ByProduct:  make([]AbandonedProductValue, 0),
+		ByCategory: make([]AbandonedCategoryValue, 0),
+	}
+
+	var totals struct {
+		Carts          int64
+		Value          float64
+		RecoveredCarts int64
+		RecoveredValue float64
+	}
+	err := db.Model(&AbandonedCart{}).
+		Select(`COUNT(*) AS carts, COALESCE(SUM(value), 0) AS value,
+			COALESCE(SUM(CASE WHEN recovered_at IS NOT NULL THEN 1 ELSE 0 END), 0) AS recovered_carts,
+			COALESCE(SUM(CASE WHEN recovered_at IS NOT NULL THEN value ELSE 0 END), 0) AS recovered_value`).
+		Where("detected_at >= ? AND detected_at < ?", since, until).
+		Scan(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+	report.Carts = totals.Carts
+	report.Value = roundCents(totals.Value)
+	report.RecoveredCarts = totals.RecoveredCarts
+	report.RecoveredValue = roundCents(totals.RecoveredValue)
+	if report.Carts > 0 {
+		report.RecoveryRate = float64(report.RecoveredCarts) / float64(report.Carts)
+	}
+
+	items := db.Table("abandoned_cart_items").
+		Joins("JOIN abandoned_carts ON abandoned_carts.id = abandoned_cart_items.abandoned_cart_id").
+		Where("abandoned_carts.detected_at >= ? AND abandoned_carts.detected_at < ?", since, until)
+
+	err = items.Session(&gorm.Session{}).
+		Select(`abandoned_cart_items.product_id, MAX(abandoned_cart_items.product_name) AS name,
+			MAX(abandoned_cart_items.category) AS category,
+			COUNT(DISTINCT abandoned_cart_items.abandoned_cart_id) AS carts,
+			SUM(abandoned_cart_items.quantity) AS quantity, SUM(abandoned_cart_items.subtotal) AS value`).
+		Group("abandoned_cart_items.product_id").
+		Order("value DESC").
+		Scan(&report.ByProduct).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = items.Session(&gorm.Session{}).
+		Select(`abandoned_cart_items.category,
+			COUNT(DISTINCT abandoned_cart_items.abandoned_cart_id) AS carts,
+			SUM(abandoned_cart_items.quantity) AS quantity, SUM(abandoned_cart_items.subtotal) AS value`).
+		Group("abandoned_cart_items.category").
+		Order("value DESC").
+		Scan(&report.ByCategory).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range report.ByProduct {
+		report.ByProduct[i].Value = roundCents(report.ByProduct[i].Value)
+	}
+	for i := range report.ByCategory {
+		report.ByCategory[i].Value = roundCents(report.ByCategory[i].Value)
+	}
+	return report, nil
+}
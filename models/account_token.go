@@ -0,0 +1,225 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Add valid account token purpose constant
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposePasswordReset = "password_reset"
+)
+
+// EmailVerificationTTL is how long an email verification link works
+var EmailVerificationTTL = 48 * time.Hour
+
+// PasswordResetTTL is how long a password reset link works
+var PasswordResetTTL = time.Hour
+
+// AccountEmailCooldown is the minimum time between two emails of the same kind to one user
+var AccountEmailCooldown = time.Minute
+
+// Links in account emails; the token is appended as ?token=
+var (
+	EmailVerificationURL = "http://localhost:8000/verify-email"
+	PasswordResetURL     = "http://localhost:8000/reset-password"
+)
+
+// AccountToken records a signed email verification or password reset token so it can be used once.
+// The token itself is never stored, only its jti.
+type AccountToken struct {
+	ID        uint       `gorm:"primaryKey" json:"-"`
+	UserID    uint       `gorm:"not null;index" json:"-"`
+	Purpose   string     `gorm:"size:32;not null" json:"-"`
+	JTI       string     `gorm:"column:jti;size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"-"`
+	UsedAt    *time.Time `json:"-"` // Also set when a newer token of the same purpose replaces it
+	CreatedAt time.Time  `json:"-"`
+}
+
+// TableName overrides the table name
+func (AccountToken) TableName() string {
+	return "account_tokens"
+}
+
+// RequestEmailVerification queues an email with a link that confirms the user's address
+func RequestEmailVerification(db *gorm.DB, userID uint) error {
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		return fmt.Errorf("user not found")
+	}
+	if user.EmailVerifiedAt != nil {
+		return fmt.Errorf("email already verified")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		token, err := issueAccountToken(tx, user.UserID, TokenPurposeVerifyEmail, EmailVerificationTTL)
+		if err != nil || token == "" {
+			return err
+		}
+		_, err = QueueEmail(tx, user.Email, "Confirm your email address", fmt.Sprintf(
+			"Welcome!\n\nConfirm your email address by opening this link:\n%s\n\nThe link expires in %s.\n",
+			accountLink(EmailVerificationURL, token), EmailVerificationTTL))
+		return err
+	})
+}
+
+// VerifyEmail confirms the address of the user a verification token was sent to
+func VerifyEmail(db *gorm.DB, token string) (*User, error) {
+	var user User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		userID, err := consumeAccountToken(tx, token, TokenPurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, userID).Error; err != nil {
+			return fmt.Errorf("invalid or expired token")
+		}
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		return tx.Model(&user).Update("email_verified_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RequestPasswordReset queues a password reset link for an email address.
+// Unknown addresses are ignored without an error so accounts cannot be discovered.
+func RequestPasswordReset(db *gorm.DB, email string) error {
+	var user User
+	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		token, err := issueAccountToken(tx, user.UserID, TokenPurposePasswordReset, PasswordResetTTL)
+		if err != nil || token == "" {
+			return err
+		}
+		_, err = QueueEmail(tx, user.Email, "Reset your password", fmt.Sprintf(
+			"We received a request to reset your password. Choose a new one here:\n%s\n\n"+
+				"The link expires in %s. If you did not ask for this, you can ignore this email.\n",
+			accountLink(PasswordResetURL, token), PasswordResetTTL))
+		return err
+	})
+}
+
+// ResetPassword sets a new password with a reset token and signs the user out everywhere.
+// Following the link proves the user owns the address, so it is marked verified and any login lockout is lifted.
+func ResetPassword(db *gorm.DB, token, password string) error {
+	if err := isValidPassword(password); err != nil {
+		return err
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		userID, err := consumeAccountToken(tx, token, TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		var user User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return fmt.Errorf("invalid or expired token")
+		}
+
+		updates := map[string]interface{}{"password": hashedPassword}
+		if user.EmailVerifiedAt == nil {
+			updates["email_verified_at"] = time.Now()
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if _, err := RevokeUserSessions(tx, user.UserID, 0); err != nil {
+			return err
+		}
+		if err := RecordLoginSuccess(tx, user.Email); err != nil {
+			return err
+		}
+		_, err = QueueEmail(tx, user.Email, "Your password was changed",
+			"Your password was just reset and every device was signed out.\n"+
+				"If this was not you, reset your password again and contact support.\n")
+		return err
+	})
+}
+
+// Helper function to sign a new account token, replacing unused tokens of the same purpose.
+// Returns an empty token when one was issued less than AccountEmailCooldown ago.
+func issueAccountToken(tx *gorm.DB, userID uint, purpose string, ttl time.Duration) (string, error) {
+	var recent int64
+	err := tx.Model(&AccountToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", userID, purpose, time.Now().Add(-AccountEmailCooldown)).
+		Count(&recent).Error
+	if err != nil {
+		return "", err
+	}
+	if recent > 0 {
+		return "", nil
+	}
+
+	err = tx.Model(&AccountToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+	if err != nil {
+		return "", err
+	}
+
+	token, jti, err := utils.GenerateActionToken(userID, purpose, ttl)
+	if err != nil {
+		return "", err
+	}
+	record := AccountToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		JTI:       jti,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Helper function to check an account token's signature and mark it used, returning its user ID
+func consumeAccountToken(tx *gorm.DB, token, purpose string) (uint, error) {
+	userID, jti, err := utils.ValidateActionToken(token, purpose)
+	if err != nil {
+		return 0, fmt.Errorf("invalid or expired token")
+	}
+
+	var record AccountToken
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("jti = ? AND purpose = ? AND user_id = ?", jti, purpose, userID).
+		First(&record).Error
+	if err != nil || time.Now().After(record.ExpiresAt) {
+		return 0, fmt.Errorf("invalid or expired token")
+	}
+	if record.UsedAt != nil {
+		return 0, fmt.Errorf("token already used")
+	}
+
+	if err := tx.Model(&record).Update("used_at", time.Now()).Error; err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// Helper function to build an email link carrying a token
+func accountLink(base, token string) string {
+	return base + "?token=" + url.QueryEscape(token)
+}
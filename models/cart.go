@@ -6,25 +6,35 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // CartItem represents an item in the cart
 type CartItem struct {
-	ID        uint      `gorm:"primaryKey" json:"-"` // Hide internal ID
-	UserID    uint      `gorm:"not null" json:"-"`   // Hide UserID
-	ProductID uint      `gorm:"not null" json:"-"`   // Hide ProductID
+	ID        uint      `gorm:"primaryKey" json:"-"`               // Hide internal ID
+	UserID    uint      `gorm:"not null;default:0;index" json:"-"` // Hide UserID, 0 for guest carts
+	GuestID   string    `gorm:"type:varchar(100);index" json:"-"`  // Set for guest carts
+	ProductID uint      `gorm:"not null" json:"-"`                 // Hide ProductID
 	Quantity  int       `gorm:"not null" json:"quantity"`
 	CreatedAt time.Time `json:"-"` // Hide timestamps
 	UpdatedAt time.Time `json:"-"`
-	Product   Product   `gorm:"foreignKey:ProductID" json:"-"` // Hide full product
+
+	// Snapshot taken when the item was added, compared against the live product
+	PriceAtAdd  float64 `gorm:"not null;default:0" json:"-"`
+	ProductName string  `gorm:"type:varchar(255)" json:"-"`
+
+	// No foreign key: items outlive a deleted product so the cart can warn about it
+	Product Product `gorm:"foreignKey:ProductID;constraint:-" json:"-"` // Hide full product
 }
 
 // CartItemResponse is the JSON response structure for cart items
 type CartItemResponse struct {
 	ID          uint    `json:"id"`
+	ItemID      uint    `json:"item_id"`
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
+	PriceAtAdd  float64 `json:"price_at_add"`
 	Category    string  `json:"category"`
 	Quantity    int     `json:"quantity"`
 	Subtotal    float64 `json:"subtotal"`
@@ -32,9 +42,35 @@ type CartItemResponse struct {
 
 // CartSummary represents the cart summary with organized items
 type CartSummary struct {
-	Items      []CartItemResponse `json:"items"`
-	TotalItems int                `json:"total_items"`
-	TotalPrice float64            `json:"total_price"`
+	Items         []CartItemResponse `json:"items"`
+	TotalItems    int                `json:"total_items"`
+	Subtotal      float64            `json:"subtotal"`
+	Discounts     []DiscountLine     `json:"discounts"`
+	DiscountTotal float64            `json:"discount_total"`
+	TotalPrice    float64            `json:"total_price"` // Subtotal minus discounts
+	Warnings      []CartWarning      `json:"warnings"`
+}
+
+// Cart warning types for items that no longer match the catalog
+const (
+	CartWarningPriceIncreased    = "price_increased"
+	CartWarningPriceDecreased    = "price_decreased"
+	CartWarningInsufficientStock = "insufficient_stock"
+	CartWarningProductDeleted    = "product_deleted"
+	CartWarningCouponNotApplied  = "coupon_not_applied"
+)
+
+// CartWarning describes a change to a cart item since it was added
+type CartWarning struct {
+	ItemID    uint    `json:"item_id,omitempty"`
+	ProductID uint    `json:"product_id,omitempty"`
+	Code      string  `json:"code,omitempty"` // Coupon code for coupon warnings
+	Type      string  `json:"type"`
+	Message   string  `json:"message"`
+	OldPrice  float64 `json:"old_price,omitempty"`
+	NewPrice  float64 `json:"new_price,omitempty"`
+	Requested int     `json:"requested_quantity,omitempty"`
+	Available *int    `json:"available_quantity,omitempty"`
 }
 
 // Add TotalPrice as a computed field
@@ -54,70 +90,246 @@ func (ci CartItem) MarshalJSON() ([]byte, error) {
 	})
 }
 
-// AddToCart adds or updates an item in the cart
-func AddToCart(db *gorm.DB, userID, productID uint, quantity int) error {
-	// Verify product exists and has enough stock
-	var product Product
-	if err := db.First(&product, productID).Error; err != nil {
-		return fmt.Errorf("product not found")
+// CartOwner identifies whose cart is being used: a user, or a guest by cookie ID
+type CartOwner struct {
+	UserID  uint
+	GuestID string
+}
+
+// Cart merge rules applied when a guest cart and a user cart hold the same product
+const (
+	CartMergeSum = "sum" // Add the quantities, warning when stock runs short
+	CartMergeMax = "max" // Keep the larger quantity, warning when stock runs short
+	CartMergeCap = "cap" // Add the quantities, quietly capped at available stock
+)
+
+// Add valid cart merge rules constant
+var ValidCartMergeRules = map[string]bool{
+	CartMergeSum: true,
+	CartMergeMax: true,
+	CartMergeCap: true,
+}
+
+// CartMergeRule is the rule used when merging a guest cart at login
+var CartMergeRule = CartMergeSum
+
+// Helper function to restrict a query to the owner's cart
+func (o CartOwner) scope(db *gorm.DB) *gorm.DB {
+	if o.UserID != 0 {
+		return db.Where("user_id = ?", o.UserID)
+	}
+	return db.Where("user_id = 0 AND guest_id = ?", o.GuestID)
+}
+
+// Helper function to reject an owner with neither a user nor a guest ID
+func (o CartOwner) validate() error {
+	if o.UserID == 0 && o.GuestID == "" {
+		return fmt.Errorf("cart owner required")
 	}
+	return nil
+}
 
-	if product.Stock < quantity {
-		return fmt.Errorf("insufficient stock")
+// AddToCart adds or updates an item in a user's cart and reserves its stock
+func AddToCart(db *gorm.DB, userID, productID uint, quantity int) error {
+	return AddToCartFor(db, CartOwner{UserID: userID}, productID, quantity)
+}
+
+// AddToCartFor adds or updates an item in a user or guest cart and reserves its stock
+func AddToCartFor(db *gorm.DB, owner CartOwner, productID uint, quantity int) error {
+	if err := owner.validate(); err != nil {
+		return err
 	}
 
-	// Check if item already exists in cart
-	var existingItem CartItem
-	result := db.Where("user_id = ? AND product_id = ?", userID, productID).First(&existingItem)
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Lock the product so concurrent carts reserve its stock one at a time
+		var product Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productID).Error; err != nil {
+			return fmt.Errorf("product not found")
+		}
+
+		// Check if item already exists in cart
+		var existingItem CartItem
+		result := owner.scope(tx).Where("product_id = ?", productID).First(&existingItem)
+
+		// The item's own reservation does not count against it
+		available, err := GetAvailableStock(tx, productID, existingItem.ID)
+		if err != nil {
+			return err
+		}
+		if available < quantity {
+			return fmt.Errorf("insufficient stock")
+		}
+
+		if result.Error == nil {
+			// Update existing item quantity
+			existingItem.Quantity = quantity // Replace old quantity with new
+			existingItem.PriceAtAdd = product.Price
+			existingItem.ProductName = product.Name
+			if err := tx.Save(&existingItem).Error; err != nil {
+				return err
+			}
+			return reserveCartItem(tx, &existingItem)
+		}
+
+		// Create new item if it doesn't exist
+		cartItem := CartItem{
+			UserID:      owner.UserID,
+			ProductID:   productID,
+			Quantity:    quantity,
+			PriceAtAdd:  product.Price,
+			ProductName: product.Name,
+		}
+		if owner.UserID == 0 {
+			cartItem.GuestID = owner.GuestID
+		}
+		if err := tx.Create(&cartItem).Error; err != nil {
+			return err
+		}
+		return reserveCartItem(tx, &cartItem)
+	})
+}
 
-	if result.Error == nil {
-		// Update existing item quantity
-		existingItem.Quantity = quantity // Replace old quantity with new
-		return db.Save(&existingItem).Error
+// GetCartItem returns one item of the owner's cart
+func GetCartItem(db *gorm.DB, owner CartOwner, itemID uint) (*CartItem, error) {
+	if err := owner.validate(); err != nil {
+		return nil, err
+	}
+	var item CartItem
+	if err := owner.scope(db).Where("id = ?", itemID).First(&item).Error; err != nil {
+		return nil, fmt.Errorf("item not found in cart")
 	}
+	return &item, nil
+}
+
+// SetCartItemQuantity changes a user's cart item quantity after re-checking available stock
+func SetCartItemQuantity(db *gorm.DB, userID, itemID uint, quantity int) (*CartItem, error) {
+	return SetCartItemQuantityFor(db, CartOwner{UserID: userID}, itemID, quantity)
+}
 
-	// Create new item if it doesn't exist
-	cartItem := CartItem{
-		UserID:    userID,
-		ProductID: productID,
-		Quantity:  quantity,
+// SetCartItemQuantityFor changes a cart item's quantity after re-checking available stock
+func SetCartItemQuantityFor(db *gorm.DB, owner CartOwner, itemID uint, quantity int) (*CartItem, error) {
+	if err := owner.validate(); err != nil {
+		return nil, err
+	}
+	if quantity < 1 {
+		return nil, fmt.Errorf("quantity must be at least 1")
 	}
 
-	return db.Create(&cartItem).Error
+	var item CartItem
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := owner.scope(tx).Where("id = ?", itemID).First(&item).Error; err != nil {
+			return fmt.Errorf("item not found in cart")
+		}
+
+		var product Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, item.ProductID).Error; err != nil {
+			return fmt.Errorf("product not found")
+		}
+
+		// Only increases need stock; decreases always succeed
+		if quantity > item.Quantity {
+			available, err := GetAvailableStock(tx, item.ProductID, item.ID)
+			if err != nil {
+				return err
+			}
+			if available < quantity {
+				return fmt.Errorf("insufficient stock")
+			}
+		}
+
+		item.Quantity = quantity
+		if err := tx.Save(&item).Error; err != nil {
+			return err
+		}
+		return reserveCartItem(tx, &item)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
 }
 
-// RemoveFromCart removes an item from the cart
+// RemoveFromCart removes an item from a user's cart and releases its reservation
 func RemoveFromCart(db *gorm.DB, userID, itemID uint) error {
-	result := db.Where("id = ? AND user_id = ?", itemID, userID).Delete(&CartItem{})
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("item not found in cart")
+	return RemoveFromCartFor(db, CartOwner{UserID: userID}, itemID)
+}
+
+// RemoveFromCartFor removes an item from a user or guest cart and releases its reservation
+func RemoveFromCartFor(db *gorm.DB, owner CartOwner, itemID uint) error {
+	if err := owner.validate(); err != nil {
+		return err
 	}
-	return result.Error
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := owner.scope(tx).Where("id = ?", itemID).Delete(&CartItem{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("item not found in cart")
+		}
+		return releaseCartItems(tx, itemID)
+	})
 }
 
 // GetCart retrieves the cart items for a user with organized response
 func GetCart(db *gorm.DB, userID uint) (*CartSummary, error) {
-	var items []CartItem
+	return GetCartFor(db, CartOwner{UserID: userID})
+}
 
-	err := db.Preload("Product").
-		Where("user_id = ?", userID).
+// GetCartFor retrieves a user or guest cart with organized response
+func GetCartFor(db *gorm.DB, owner CartOwner) (*CartSummary, error) {
+	summary, _, err := buildCart(db, owner)
+	return summary, err
+}
+
+// Helper function to build the cart summary along with its discounted lines
+func buildCart(db *gorm.DB, owner CartOwner) (*CartSummary, []QuoteLine, error) {
+	if err := owner.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	var items []CartItem
+	err := owner.scope(db.Preload("Product")).
 		Find(&items).Error
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Create organized response
 	summary := &CartSummary{
-		Items: make([]CartItemResponse, 0, len(items)),
+		Items:    make([]CartItemResponse, 0, len(items)),
+		Warnings: make([]CartWarning, 0),
 	}
+	lines := make([]QuoteLine, 0, len(items))
 
 	for _, item := range items {
+		// Product was deleted after the item was added
+		if item.Product.ID == 0 {
+			summary.Items = append(summary.Items, CartItemResponse{
+				ID:         item.ProductID,
+				ItemID:     item.ID,
+				Name:       item.ProductName,
+				PriceAtAdd: item.PriceAtAdd,
+				Quantity:   item.Quantity,
+			})
+			summary.Warnings = append(summary.Warnings, CartWarning{
+				ItemID:    item.ID,
+				ProductID: item.ProductID,
+				Type:      CartWarningProductDeleted,
+				Message:   fmt.Sprintf("%s is no longer available", item.ProductName),
+			})
+			continue
+		}
+
 		// Create response item
 		responseItem := CartItemResponse{
 			ID:          item.Product.ID,
+			ItemID:      item.ID,
 			Name:        item.Product.Name,
 			Description: item.Product.Description,
 			Price:       item.Product.Price,
+			PriceAtAdd:  item.PriceAtAdd,
 			Category:    item.Product.Category,
 			Quantity:    item.Quantity,
 			Subtotal:    float64(item.Quantity) * item.Product.Price,
@@ -125,10 +337,290 @@ func GetCart(db *gorm.DB, userID uint) (*CartSummary, error) {
 
 		summary.Items = append(summary.Items, responseItem)
 		summary.TotalItems += item.Quantity
-		summary.TotalPrice += responseItem.Subtotal
+		summary.Subtotal += responseItem.Subtotal
+		lines = append(lines, QuoteLine{
+			ProductID: item.ProductID,
+			Category:  item.Product.Category,
+			Quantity:  item.Quantity,
+			Weight:    item.Product.Weight,
+			Subtotal:  responseItem.Subtotal,
+		})
+
+		warnings, err := checkCartItem(db, item)
+		if err != nil {
+			return nil, nil, err
+		}
+		summary.Warnings = append(summary.Warnings, warnings...)
+	}
+
+	// Apply promotions and the cart's coupon
+	discounts, couponWarning, err := calculateDiscounts(db, owner, lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	summary.Discounts = discounts
+	for _, d := range discounts {
+		summary.DiscountTotal += d.Amount
+	}
+	if couponWarning != nil {
+		summary.Warnings = append(summary.Warnings, *couponWarning)
+	}
+	summary.TotalPrice = roundCents(summary.Subtotal - summary.DiscountTotal)
+
+	return summary, lines, nil
+}
+
+// Helper function to compare a cart item with its live product
+func checkCartItem(db *gorm.DB, item CartItem) ([]CartWarning, error) {
+	var warnings []CartWarning
+
+	// Items added before price snapshots have nothing to compare against
+	if item.PriceAtAdd != 0 && item.PriceAtAdd != item.Product.Price {
+		warning := CartWarning{
+			ItemID:    item.ID,
+			ProductID: item.ProductID,
+			Type:      CartWarningPriceIncreased,
+			Message:   fmt.Sprintf("The price of %s went up from %.2f to %.2f", item.Product.Name, item.PriceAtAdd, item.Product.Price),
+			OldPrice:  item.PriceAtAdd,
+			NewPrice:  item.Product.Price,
+		}
+		if item.Product.Price < item.PriceAtAdd {
+			warning.Type = CartWarningPriceDecreased
+			warning.Message = fmt.Sprintf("The price of %s went down from %.2f to %.2f", item.Product.Name, item.PriceAtAdd, item.Product.Price)
+		}
+		warnings = append(warnings, warning)
+	}
+
+	// The item's own reservation counts toward it
+	available, err := GetAvailableStock(db, item.ProductID, item.ID)
+	if err != nil {
+		return nil, err
+	}
+	if available < item.Quantity {
+		warnings = append(warnings, CartWarning{
+			ItemID:    item.ID,
+			ProductID: item.ProductID,
+			Type:      CartWarningInsufficientStock,
+			Message:   fmt.Sprintf("Only %d of %s available", available, item.Product.Name),
+			Requested: item.Quantity,
+			Available: &available,
+		})
+	}
+
+	return warnings, nil
+}
+
+// QuoteCart prices the owner's cart shipped to an address, with discounts, shipping and tax
+func QuoteCart(db *gorm.DB, owner CartOwner, address Address) (*CartQuote, error) {
+	summary, lines, err := buildCart(db, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	address = normalizeAddress(address)
+	shipping, tax, err := quoteCharges(address, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CartQuote{
+		Address:       address,
+		Items:         summary.Items,
+		Subtotal:      roundCents(summary.Subtotal),
+		Discounts:     summary.Discounts,
+		DiscountTotal: roundCents(summary.DiscountTotal),
+		Shipping:      shipping,
+		Tax:           tax,
+		Total:         roundCents(summary.TotalPrice + shipping.Amount + tax.Total),
+		Warnings:      summary.Warnings,
+	}, nil
+}
+
+// RevalidateCart accepts the changes to the given cart items, or to every item when none are given.
+// Price changes update the snapshot, short stock lowers the quantity and deleted products are removed.
+func RevalidateCart(db *gorm.DB, owner CartOwner, itemIDs []uint) (*CartSummary, error) {
+	if err := owner.validate(); err != nil {
+		return nil, err
 	}
 
-	return summary, nil
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := owner.scope(tx)
+		if len(itemIDs) > 0 {
+			query = query.Where("id IN ?", itemIDs)
+		}
+		var items []CartItem
+		if err := query.Order("product_id").Find(&items).Error; err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			var product Product
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, item.ProductID).Error; err != nil {
+				// Product is gone; drop the item
+				if err := tx.Delete(&item).Error; err != nil {
+					return err
+				}
+				if err := releaseCartItems(tx, item.ID); err != nil {
+					return err
+				}
+				continue
+			}
+
+			available, err := GetAvailableStock(tx, item.ProductID, item.ID)
+			if err != nil {
+				return err
+			}
+			if available < 1 {
+				if err := tx.Delete(&item).Error; err != nil {
+					return err
+				}
+				if err := releaseCartItems(tx, item.ID); err != nil {
+					return err
+				}
+				continue
+			}
+
+			updates := map[string]interface{}{
+				"price_at_add": product.Price,
+				"product_name": product.Name,
+			}
+			if available < item.Quantity {
+				item.Quantity = available
+				updates["quantity"] = available
+			}
+			if err := tx.Model(&item).Updates(updates).Error; err != nil {
+				return err
+			}
+			if err := reserveCartItem(tx, &item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetCartFor(db, owner)
+}
+
+// MergeGuestCart moves a guest cart into a user's cart, resolving shared products with rule.
+// Every rule is clamped to available stock; the warnings list the lines that did not fully fit.
+func MergeGuestCart(db *gorm.DB, guestID string, userID uint, rule string) ([]CartWarning, error) {
+	if !ValidCartMergeRules[rule] {
+		return nil, fmt.Errorf("invalid cart merge rule: %s", rule)
+	}
+	if guestID == "" || userID == 0 {
+		return nil, nil
+	}
+
+	var warnings []CartWarning
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var guestItems []CartItem
+		if err := (CartOwner{GuestID: guestID}).scope(tx).Order("product_id").Find(&guestItems).Error; err != nil {
+			return err
+		}
+
+		for _, guestItem := range guestItems {
+			var product Product
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, guestItem.ProductID).Error; err != nil {
+				// Product is gone; drop the guest item
+				if err := tx.Delete(&guestItem).Error; err != nil {
+					return err
+				}
+				if err := releaseCartItems(tx, guestItem.ID); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var userItem CartItem
+			hasUserItem := tx.Where("user_id = ? AND product_id = ?", userID, guestItem.ProductID).First(&userItem).Error == nil
+
+			// Stock the two items may hold between them, including their own holds
+			exclude := []uint{guestItem.ID}
+			if hasUserItem {
+				exclude = append(exclude, userItem.ID)
+			}
+			available, err := GetAvailableStock(tx, guestItem.ProductID, exclude...)
+			if err != nil {
+				return err
+			}
+
+			quantity := mergeQuantity(rule, userItem.Quantity, guestItem.Quantity)
+			if quantity > available {
+				// Capping is the point of the cap rule; only warn there when none of the guest line fits
+				if rule != CartMergeCap || available <= userItem.Quantity {
+					warnings = append(warnings, CartWarning{
+						ProductID: product.ID,
+						Type:      CartWarningInsufficientStock,
+						Message:   fmt.Sprintf("Only %d of %s available", available, product.Name),
+						Requested: quantity,
+						Available: &available,
+					})
+				}
+				quantity = available
+			}
+
+			if !hasUserItem {
+				// Hand the guest item over to the user and reserve it
+				guestItem.UserID = userID
+				guestItem.GuestID = ""
+				guestItem.Quantity = quantity
+				if quantity < 1 {
+					if err := tx.Delete(&guestItem).Error; err != nil {
+						return err
+					}
+					if err := releaseCartItems(tx, guestItem.ID); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := tx.Save(&guestItem).Error; err != nil {
+					return err
+				}
+				if err := reserveCartItem(tx, &guestItem); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := tx.Delete(&guestItem).Error; err != nil {
+				return err
+			}
+			if err := releaseCartItems(tx, guestItem.ID); err != nil {
+				return err
+			}
+
+			// Never take anything away from the user's own line
+			if quantity <= userItem.Quantity {
+				continue
+			}
+			userItem.Quantity = quantity
+			if err := tx.Save(&userItem).Error; err != nil {
+				return err
+			}
+			if err := reserveCartItem(tx, &userItem); err != nil {
+				return err
+			}
+		}
+		return mergeCartCoupon(tx, guestID, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return warnings, nil
+}
+
+// Helper function to combine user and guest quantities under a merge rule, before clamping to stock
+func mergeQuantity(rule string, userQuantity, guestQuantity int) int {
+	if rule == CartMergeMax {
+		if userQuantity > guestQuantity {
+			return userQuantity
+		}
+		return guestQuantity
+	}
+	return userQuantity + guestQuantity
 }
 
 // TableName overrides the table name
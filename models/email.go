@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Add valid email status constant
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	EmailStatusFailed  = "failed"
+)
+
+// EmailMaxAttempts is how many times an email is tried before it is marked failed
+var EmailMaxAttempts = 5
+
+// EmailMessage is an email waiting in the outbox. Emails are queued in the same transaction
+// as the change that triggers them and delivered by a background job.
+type EmailMessage struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	To            string     `gorm:"column:to_address;size:255;not null;index" json:"to"`
+	Subject       string     `gorm:"size:255;not null" json:"subject"`
+	Body          string     `gorm:"type:text;not null" json:"body"`
+	Status        string     `gorm:"size:20;not null;default:pending;index" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	LastError     string     `gorm:"size:255" json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `gorm:"not null;index" json:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// TableName overrides the table name
+func (EmailMessage) TableName() string {
+	return "email_outbox"
+}
+
+// QueueEmail adds an email to the outbox for the next delivery run
+func QueueEmail(db *gorm.DB, to, subject, body string) (*EmailMessage, error) {
+	message := EmailMessage{
+		To:            to,
+		Subject:       subject,
+		Body:          body,
+		Status:        EmailStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := db.Create(&message).Error; err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// DeliverEmails sends up to limit pending emails that are due and returns how many went out.
+// A failed send is retried with a growing delay until EmailMaxAttempts, then marked failed.
+func DeliverEmails(db *gorm.DB, mailer Mailer, limit int) (int, error) {
+	var messages []EmailMessage
+	err := db.Where("status = ? AND next_attempt_at <= ?", EmailStatusPending, time.Now()).
+		Order("id").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range messages {
+		message := &messages[i]
+		if sendErr := mailer.Send(message); sendErr != nil {
+			if err := recordEmailFailure(db, message, sendErr); err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		err := db.Model(message).Updates(map[string]interface{}{
+			"status":   EmailStatusSent,
+			"attempts": gorm.Expr("attempts + 1"),
+			"sent_at":  time.Now(),
+		}).Error
+		if err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// Helper function to schedule a retry of a failed email, or give up after EmailMaxAttempts
+func recordEmailFailure(db *gorm.DB, message *EmailMessage, sendErr error) error {
+	errMsg := sendErr.Error()
+	if len(errMsg) > 255 {
+		errMsg = errMsg[:255]
+	}
+
+	attempts := message.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":   attempts,
+		"last_error": errMsg,
+		// Back off 1, 4, 9, ... minutes
+		"next_attempt_at": time.Now().Add(time.Duration(attempts*attempts) * time.Minute),
+	}
+	if attempts >= EmailMaxAttempts {
+		updates["status"] = EmailStatusFailed
+	}
+	return db.Model(message).Updates(updates).Error
+}
@@ -0,0 +1,425 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// InteractionRecord is one historical view used for offline evaluation
+type InteractionRecord struct {
+	Visitor   string
+	ProductID uint
+	ViewedAt  time.Time
+}
+
+// EvalMetrics are the averaged scores of one strategy
+type EvalMetrics struct {
+	Strategy  string  `json:"strategy"`
+	Visitors  int     `json:"visitors"`
+	Precision float64 `json:"precision_at_k"`
+	Recall    float64 `json:"recall_at_k"`
+	MAP       float64 `json:"map_at_k"`
+	NDCG      float64 `json:"ndcg_at_k"`
+	Coverage  float64 `json:"coverage"`
+	Novelty   float64 `json:"novelty"`
+}
+
+// EvalReport is the result of an offline evaluation run
+type EvalReport struct {
+	K           int           `json:"k"`
+	Cutoff      time.Time     `json:"cutoff"`
+	TrainViews  int           `json:"train_views"`
+	TestViews   int           `json:"test_views"`
+	CatalogSize int           `json:"catalog_size"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Strategies  []EvalMetrics `json:"strategies"`
+}
+
+// LoadInteractionLog reads user and guest views, oldest first
+func LoadInteractionLog(db *gorm.DB) ([]InteractionRecord, error) {
+	var records []InteractionRecord
+	err := db.Raw(`
+		SELECT CONCAT('u:', user_id) as visitor, product_id, viewed_at
+		FROM user_interactions
+		UNION ALL
+		SELECT
+			CASE WHEN l.user_id IS NULL THEN CONCAT('g:', gi.guest_id) ELSE CONCAT('u:', l.user_id) END,
+			gi.product_id,
+			gi.viewed_at
+		FROM guest_interactions gi
+		LEFT JOIN guest_user_links l ON gi.guest_id = l.guest_id
+		ORDER BY viewed_at ASC
+	`).Scan(&records).Error
+	return records, err
+}
+
+// LoadCatalog returns all products keyed by ID
+func LoadCatalog(db *gorm.DB) (map[uint]Product, error) {
+	var products []Product
+	if err := db.Find(&products).Error; err != nil {
+		return nil, err
+	}
+	catalog := make(map[uint]Product, len(products))
+	for _, p := range products {
+		catalog[p.ID] = p
+	}
+	return catalog, nil
+}
+
+// SplitCutoff returns the time before which trainFraction of the (sorted) records fall
+func SplitCutoff(records []InteractionRecord, trainFraction float64) time.Time {
+	if len(records) == 0 {
+		return time.Now()
+	}
+	i := int(float64(len(records)) * trainFraction)
+	if i >= len(records) {
+		i = len(records) - 1
+	}
+	return records[i].ViewedAt
+}
+
+// trainSnapshotTables are copied into an evaluation snapshot, keeping only rows that existed
+// at the cutoff (the placeholder). Buckets are kept only when they closed before the cutoff.
+var trainSnapshotTables = []struct {
+	Table  string
+	Filter string
+}{
+	{"products", "created_at < ?"},
+	{"user_interactions", "viewed_at < ?"},
+	{"guest_interactions", "viewed_at < ?"},
+	{"guest_user_links", "created_at < ?"},
+	{"wishlists", "created_at < ?"},
+	{"wishlist_items", "created_at < ?"},
+	{"product_view_buckets", fmt.Sprintf("bucket_start + INTERVAL %d SECOND <= ?", int(TrendingBucketSize.Seconds()))},
+	{"trending_products", ""},    // Rebuilt from the copied views
+	{"product_similarities", ""}, // Rebuilt from the copied views
+}
+
+var schemaNameRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// WithTrainSnapshot copies what was known at the cutoff into a scratch schema, rebuilds the
+// derived tables there and runs fn against it. The source schema is only read; the scratch
+// schema must not exist yet and is dropped afterwards. Stock levels are the current ones.
+func WithTrainSnapshot(db *gorm.DB, cutoff time.Time, schema string, fn func(snapshot *gorm.DB) error) error {
+	var source string
+	if err := db.Raw("SELECT DATABASE()").Scan(&source).Error; err != nil {
+		return err
+	}
+	if schema == "" {
+		schema = source + "_eval"
+	}
+	if !schemaNameRegex.MatchString(schema) || schema == source {
+		return fmt.Errorf("invalid snapshot schema: %s", schema)
+	}
+
+	if err := db.Exec("CREATE DATABASE `" + schema + "`").Error; err != nil {
+		return fmt.Errorf("failed to create snapshot schema: %v", err)
+	}
+	defer db.Exec("DROP DATABASE IF EXISTS `" + schema + "`")
+
+	for _, t := range trainSnapshotTables {
+		if err := db.Exec(fmt.Sprintf("CREATE TABLE `%s`.`%s` LIKE `%s`.`%s`", schema, t.Table, source, t.Table)).Error; err != nil {
+			return fmt.Errorf("failed to copy %s: %v", t.Table, err)
+		}
+		if t.Filter == "" {
+			continue
+		}
+		if err := db.Exec(fmt.Sprintf("INSERT INTO `%s`.`%s` SELECT * FROM `%s`.`%s` WHERE %s",
+			schema, t.Table, source, t.Table, t.Filter), cutoff).Error; err != nil {
+			return fmt.Errorf("failed to copy %s: %v", t.Table, err)
+		}
+	}
+
+	// Pin one connection to the snapshot so the strategies' unqualified queries read it
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "USE `"+schema+"`"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "USE `"+source+"`")
+
+	snapshot, err := gorm.Open(mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true}), &gorm.Config{Logger: db.Logger})
+	if err != nil {
+		return err
+	}
+
+	if err := snapshot.Exec(`
+		INSERT INTO trending_products (product_id, title, total_views)
+		SELECT p.id, p.name, COUNT(*)
+		FROM (
+			SELECT product_id FROM user_interactions
+			UNION ALL
+			SELECT product_id FROM guest_interactions
+		) v
+		JOIN products p ON p.id = v.product_id
+		GROUP BY p.id, p.name
+	`).Error; err != nil {
+		return fmt.Errorf("failed to rebuild trending products: %v", err)
+	}
+	if err := ComputeProductSimilarities(snapshot, SimilaritySettings); err != nil {
+		return fmt.Errorf("failed to rebuild similarities: %v", err)
+	}
+
+	return fn(snapshot)
+}
+
+// Evaluate runs registered strategies against a train snapshot and scores them on views after the cutoff
+func Evaluate(snapshot *gorm.DB, records []InteractionRecord, catalog map[uint]Product, cutoff time.Time, k int, strategies []string) (EvalReport, error) {
+	var train, test []InteractionRecord
+	for _, r := range records {
+		if _, ok := catalog[r.ProductID]; !ok {
+			continue
+		}
+		if r.ViewedAt.Before(cutoff) {
+			train = append(train, r)
+		} else {
+			test = append(test, r)
+		}
+	}
+
+	// Train histories in view order, most recent last
+	histories := make(map[string][]uint)
+	seen := make(map[string]map[uint]bool)
+	for _, r := range train {
+		if seen[r.Visitor] == nil {
+			seen[r.Visitor] = make(map[uint]bool)
+		}
+		if !seen[r.Visitor][r.ProductID] {
+			seen[r.Visitor][r.ProductID] = true
+			histories[r.Visitor] = append(histories[r.Visitor], r.ProductID)
+		}
+	}
+
+	// Relevant items are new products a returning visitor viewed after the cutoff
+	relevant := make(map[string]map[uint]bool)
+	for _, r := range test {
+		if len(histories[r.Visitor]) == 0 || seen[r.Visitor][r.ProductID] {
+			continue
+		}
+		if relevant[r.Visitor] == nil {
+			relevant[r.Visitor] = make(map[uint]bool)
+		}
+		relevant[r.Visitor][r.ProductID] = true
+	}
+
+	// Popularity for novelty: share of train visitors who viewed each product
+	popularity := make(map[uint]float64)
+	for _, history := range histories {
+		for _, id := range history {
+			popularity[id]++
+		}
+	}
+	for id := range popularity {
+		popularity[id] /= float64(len(histories))
+	}
+
+	// Visit visitors in a stable order so runs are reproducible
+	visitors := make([]string, 0, len(relevant))
+	for v := range relevant {
+		visitors = append(visitors, v)
+	}
+	sort.Strings(visitors)
+
+	report := EvalReport{
+		K:           k,
+		Cutoff:      cutoff,
+		TrainViews:  len(train),
+		TestViews:   len(test),
+		CatalogSize: len(catalog),
+		GeneratedAt: time.Now(),
+	}
+
+	for _, name := range strategies {
+		strategy, ok := GetRecommender(name)
+		if !ok {
+			return report, fmt.Errorf("unknown recommendation strategy: %s", name)
+		}
+
+		metrics := EvalMetrics{Strategy: name, Visitors: len(visitors)}
+		recommended := make(map[uint]bool)
+		var noveltySum float64
+		var noveltyCount int
+
+		for _, visitor := range visitors {
+			// Ask as the visitor would have at the cutoff, looking at their last viewed product
+			history := histories[visitor]
+			ctx := visitorContext(visitor)
+			ctx.ProductID = history[len(history)-1]
+			ctx.Limit = k + len(history)
+			ctx.At = cutoff
+
+			items, err := strategy.Recommend(snapshot, ctx)
+			if err != nil {
+				return report, fmt.Errorf("strategy %s failed: %v", name, err)
+			}
+
+			recs := excludeSeen(getScoredIDs(items), seen[visitor], k)
+			metrics.Precision += PrecisionAtK(recs, relevant[visitor], k)
+			metrics.Recall += RecallAtK(recs, relevant[visitor], k)
+			metrics.MAP += AveragePrecisionAtK(recs, relevant[visitor], k)
+			metrics.NDCG += NDCGAtK(recs, relevant[visitor], k)
+
+			for _, id := range recs {
+				recommended[id] = true
+				p := popularity[id]
+				if p == 0 {
+					p = 1 / float64(len(histories)+1)
+				}
+				noveltySum += -math.Log2(p)
+				noveltyCount++
+			}
+		}
+
+		if n := float64(len(visitors)); n > 0 {
+			metrics.Precision /= n
+			metrics.Recall /= n
+			metrics.MAP /= n
+			metrics.NDCG /= n
+		}
+		if len(catalog) > 0 {
+			metrics.Coverage = float64(len(recommended)) / float64(len(catalog))
+		}
+		if noveltyCount > 0 {
+			metrics.Novelty = noveltySum / float64(noveltyCount)
+		}
+		report.Strategies = append(report.Strategies, metrics)
+	}
+
+	return report, nil
+}
+
+// Markdown renders the report as a markdown table
+func (r EvalReport) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Recommendation evaluation\n\n")
+	fmt.Fprintf(&b, "- Cutoff: %s\n", r.Cutoff.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Train views: %d, test views: %d, catalog size: %d\n\n", r.TrainViews, r.TestViews, r.CatalogSize)
+	fmt.Fprintf(&b, "| Strategy | Visitors | P@%d | R@%d | MAP@%d | NDCG@%d | Coverage | Novelty |\n", r.K, r.K, r.K, r.K)
+	fmt.Fprintf(&b, "|---|---|---|---|---|---|---|---|\n")
+	for _, m := range r.Strategies {
+		fmt.Fprintf(&b, "| %s | %d | %.4f | %.4f | %.4f | %.4f | %.4f | %.4f |\n",
+			m.Strategy, m.Visitors, m.Precision, m.Recall, m.MAP, m.NDCG, m.Coverage, m.Novelty)
+	}
+	return b.String()
+}
+
+// PrecisionAtK is the share of the top k recommendations that are relevant
+func PrecisionAtK(recs []uint, relevant map[uint]bool, k int) float64 {
+	if k <= 0 {
+		return 0
+	}
+	hits := 0
+	for i, id := range recs {
+		if i >= k {
+			break
+		}
+		if relevant[id] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(k)
+}
+
+// RecallAtK is the share of relevant items found in the top k recommendations
+func RecallAtK(recs []uint, relevant map[uint]bool, k int) float64 {
+	if len(relevant) == 0 {
+		return 0
+	}
+	hits := 0
+	for i, id := range recs {
+		if i >= k {
+			break
+		}
+		if relevant[id] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(relevant))
+}
+
+// AveragePrecisionAtK averages precision at each relevant position in the top k
+func AveragePrecisionAtK(recs []uint, relevant map[uint]bool, k int) float64 {
+	if len(relevant) == 0 {
+		return 0
+	}
+	hits := 0
+	var sum float64
+	for i, id := range recs {
+		if i >= k {
+			break
+		}
+		if relevant[id] {
+			hits++
+			sum += float64(hits) / float64(i+1)
+		}
+	}
+	denominator := len(relevant)
+	if k < denominator {
+		denominator = k
+	}
+	return sum / float64(denominator)
+}
+
+// NDCGAtK is the discounted cumulative gain of the top k normalized by the ideal ranking
+func NDCGAtK(recs []uint, relevant map[uint]bool, k int) float64 {
+	var dcg float64
+	for i, id := range recs {
+		if i >= k {
+			break
+		}
+		if relevant[id] {
+			dcg += 1 / math.Log2(float64(i+2))
+		}
+	}
+
+	var ideal float64
+	for i := 0; i < len(relevant) && i < k; i++ {
+		ideal += 1 / math.Log2(float64(i+2))
+	}
+	if ideal == 0 {
+		return 0
+	}
+	return dcg / ideal
+}
+
+// Helper function to drop already seen products and cut the list to k
+func excludeSeen(recs []uint, seen map[uint]bool, k int) []uint {
+	out := make([]uint, 0, k)
+	for _, id := range recs {
+		if seen[id] {
+			continue
+		}
+		out = append(out, id)
+		if len(out) == k {
+			break
+		}
+	}
+	return out
+}
+
+// Helper function to build the recommendation context of an evaluation visitor ("u:<id>" or "g:<id>")
+func visitorContext(visitor string) RecommendationContext {
+	if id, ok := strings.CutPrefix(visitor, "u:"); ok {
+		if userID, err := strconv.ParseUint(id, 10, 64); err == nil {
+			return RecommendationContext{UserID: uint(userID)}
+		}
+	}
+	return RecommendationContext{GuestID: strings.TrimPrefix(visitor, "g:")}
+}
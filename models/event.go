@@ -0,0 +1,193 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EventType identifies the kind of tracked event
+type EventType string
+
+const (
+	EventView           EventType = "view"
+	EventClick          EventType = "click"
+	EventAddToCart      EventType = "add_to_cart"
+	EventRemoveFromCart EventType = "remove_from_cart"
+	EventAddToWishlist  EventType = "add_to_wishlist"
+	EventSearch         EventType = "search"
+	EventPurchase       EventType = "purchase"
+	EventCustom         EventType = "custom"
+	EventCartAbandoned  EventType = "cart_abandoned"
+	EventCartRecovered  EventType = "cart_recovered"
+)
+
+// Add valid event types constant
+var ValidEventTypes = map[EventType]bool{
+	EventView:           true,
+	EventClick:          true,
+	EventAddToCart:      true,
+	EventRemoveFromCart: true,
+	EventAddToWishlist:  true,
+	EventSearch:         true,
+	EventPurchase:       true,
+	EventCustom:         true,
+	EventCartAbandoned:  true,
+	EventCartRecovered:  true,
+}
+
+// Event types only recorded by the server, never accepted from clients
+var ServerEventTypes = map[EventType]bool{
+	EventCartAbandoned: true,
+	EventCartRecovered: true,
+}
+
+// EventProperties holds free-form event data stored as JSON
+type EventProperties map[string]interface{}
+
+// Value implements driver.Valuer so properties are stored as JSON text
+func (p EventProperties) Value() (driver.Value, error) {
+	if p == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner so properties are read back from JSON text
+func (p *EventProperties) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*p = EventProperties{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for event properties: %T", value)
+	}
+	return json.Unmarshal(data, p)
+}
+
+// Event is a single tracked action by a user or guest
+type Event struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	UserID     *uint           `gorm:"index" json:"user_id,omitempty"`
+	GuestID    string          `gorm:"size:255;index" json:"guest_id,omitempty"`
+	Type       EventType       `gorm:"size:50;not null;index" json:"type"`
+	Name       string          `gorm:"size:100" json:"name,omitempty"` // Only used by custom events
+	ProductID  *uint           `gorm:"index" json:"product_id,omitempty"`
+	Properties EventProperties `gorm:"type:text" json:"properties"`
+	CreatedAt  time.Time       `gorm:"index" json:"created_at"`
+}
+
+// TableName overrides the table name
+func (Event) TableName() string {
+	return "events"
+}
+
+// EventCount is the number of events recorded for a type
+type EventCount struct {
+	Type  EventType `json:"type"`
+	Count int64     `json:"count"`
+}
+
+// ValidateEvent checks that an event matches the schema for its type
+func ValidateEvent(e *Event) error {
+	if !ValidEventTypes[e.Type] {
+		return fmt.Errorf("invalid event type: %s", e.Type)
+	}
+
+	if e.UserID == nil && e.GuestID == "" {
+		return fmt.Errorf("event must belong to a user or guest")
+	}
+
+	switch e.Type {
+	case EventView, EventClick:
+		if e.ProductID == nil {
+			return fmt.Errorf("%s event requires product_id", e.Type)
+		}
+	case EventAddToCart, EventRemoveFromCart:
+		if e.ProductID == nil {
+			return fmt.Errorf("%s event requires product_id", e.Type)
+		}
+		quantity, ok := numberProperty(e.Properties, "quantity")
+		if !ok || quantity < 1 {
+			return fmt.Errorf("%s event requires a positive quantity property", e.Type)
+		}
+	case EventSearch:
+		if _, ok := e.Properties["query"].(string); !ok {
+			return fmt.Errorf("search event requires a query property")
+		}
+	case EventPurchase:
+		amount, ok := numberProperty(e.Properties, "amount")
+		if !ok || amount < 0 {
+			return fmt.Errorf("purchase event requires a non-negative amount property")
+		}
+	case EventCustom:
+		if e.Name == "" {
+			return fmt.Errorf("custom event requires a name")
+		}
+	case EventCartAbandoned, EventCartRecovered:
+		if _, ok := numberProperty(e.Properties, "abandoned_cart_id"); !ok {
+			return fmt.Errorf("%s event requires an abandoned_cart_id property", e.Type)
+		}
+	}
+
+	return nil
+}
+
+// RecordEvent validates and stores an event
+func RecordEvent(db *gorm.DB, e *Event) error {
+	if err := ValidateEvent(e); err != nil {
+		return err
+	}
+
+	// Verify product exists when one is referenced
+	if e.ProductID != nil {
+		var count int64
+		db.Model(&Product{}).Where("id = ?", *e.ProductID).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("product not found")
+		}
+	}
+
+	if e.Properties == nil {
+		e.Properties = EventProperties{}
+	}
+
+	return db.Create(e).Error
+}
+
+// GetEventCounts returns the number of events recorded per type
+func GetEventCounts(db *gorm.DB) ([]EventCount, error) {
+	var counts []EventCount
+	err := db.Model(&Event{}).
+		Select("type, COUNT(*) as count").
+		Group("type").
+		Order("count DESC").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// Helper function to read a numeric property decoded from JSON or set in Go
+func numberProperty(props EventProperties, key string) (float64, bool) {
+	switch v := props[key].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
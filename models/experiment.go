@@ -0,0 +1,411 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Add valid experiment statuses constant
+var ValidExperimentStatuses = map[string]bool{
+	"draft":   true,
+	"running": true,
+	"stopped": true,
+}
+
+// experimentAttributionWindow is how long after an exposure a tagged click or cart add counts
+const experimentAttributionWindow = 24 * time.Hour
+
+// StrategyList is a list of weighted strategies stored as JSON
+type StrategyList []WeightedStrategy
+
+// Value implements driver.Valuer so strategies are stored as JSON text
+func (l StrategyList) Value() (driver.Value, error) {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner so strategies are read back from JSON text
+func (l *StrategyList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return fmt.Errorf("unsupported type for strategy list: %T", value)
+	}
+}
+
+// Experiment compares recommendation strategies on a placement
+type Experiment struct {
+	ID        uint                `gorm:"primaryKey" json:"id"`
+	Name      string              `gorm:"size:100;unique;not null" json:"name" binding:"required"`
+	Placement string              `gorm:"size:100;not null;index" json:"placement" binding:"required"`
+	Status    string              `gorm:"size:20;not null;default:draft" json:"status"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+	Variants  []ExperimentVariant `gorm:"foreignKey:ExperimentID" json:"variants" binding:"required"`
+}
+
+// TableName overrides the table name
+func (Experiment) TableName() string {
+	return "experiments"
+}
+
+// ExperimentVariant maps one arm of an experiment to recommendation strategies
+type ExperimentVariant struct {
+	ID           uint         `gorm:"primaryKey" json:"id"`
+	ExperimentID uint         `gorm:"not null;index" json:"-"`
+	Name         string       `gorm:"size:100;not null" json:"name"`
+	Strategies   StrategyList `gorm:"type:text" json:"strategies"`
+	Weight       int          `gorm:"not null;default:1" json:"weight"` // Share of traffic
+}
+
+// TableName overrides the table name
+func (ExperimentVariant) TableName() string {
+	return "experiment_variants"
+}
+
+// ExperimentExposure records that a visitor was shown a variant's recommendations
+type ExperimentExposure struct {
+	ID           uint      `gorm:"primaryKey"`
+	ExperimentID uint      `gorm:"not null;index"`
+	VariantID    uint      `gorm:"not null;index"`
+	UserID       *uint     `gorm:"index"`
+	GuestID      string    `gorm:"size:255;index"`
+	ProductID    uint      // Anchor product, 0 when there is none
+	CreatedAt    time.Time `gorm:"index"`
+}
+
+// TableName overrides the table name
+func (ExperimentExposure) TableName() string {
+	return "experiment_exposures"
+}
+
+// ExperimentExposureItem is one product shown in an exposure
+type ExperimentExposureItem struct {
+	ExposureID uint `gorm:"primaryKey"`
+	ProductID  uint `gorm:"primaryKey;index"`
+	Position   int  `gorm:"not null"`
+}
+
+// TableName overrides the table name
+func (ExperimentExposureItem) TableName() string {
+	return "experiment_exposure_items"
+}
+
+// VariantReport compares a variant's engagement with the control (first) variant
+type VariantReport struct {
+	VariantID       uint    `json:"variant_id"`
+	Variant         string  `json:"variant"`
+	Exposures       int64   `json:"exposures"`    // Distinct visitors shown the variant
+	Clicks          int64   `json:"clicks"`       // Distinct visitors who clicked a shown product
+	AddToCarts      int64   `json:"add_to_carts"` // Distinct visitors who carted a shown product
+	CTR             float64 `json:"ctr"`
+	AddToCartRate   float64 `json:"add_to_cart_rate"`
+	CTRPValue       float64 `json:"ctr_p_value"`
+	CartPValue      float64 `json:"add_to_cart_p_value"`
+	CTRSignificant  bool    `json:"ctr_significant"`
+	CartSignificant bool    `json:"add_to_cart_significant"`
+}
+
+// CreateExperiment validates and stores an experiment with its variants
+func CreateExperiment(db *gorm.DB, e *Experiment) error {
+	if len(e.Variants) < 2 {
+		return fmt.Errorf("experiment needs at least two variants")
+	}
+	if e.Status == "" {
+		e.Status = "draft"
+	}
+	if !ValidExperimentStatuses[e.Status] {
+		return fmt.Errorf("invalid experiment status: %s", e.Status)
+	}
+
+	for _, v := range e.Variants {
+		if v.Name == "" {
+			return fmt.Errorf("variant name cannot be empty")
+		}
+		if v.Weight <= 0 {
+			return fmt.Errorf("variant %s must have a positive weight", v.Name)
+		}
+		if len(v.Strategies) == 0 {
+			return fmt.Errorf("variant %s has no strategies", v.Name)
+		}
+		for _, s := range v.Strategies {
+			if _, ok := GetRecommender(s.Strategy); !ok {
+				return fmt.Errorf("unknown recommendation strategy: %s", s.Strategy)
+			}
+		}
+	}
+
+	var count int64
+	db.Model(&Experiment{}).Where("name = ?", e.Name).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("experiment with name '%s' already exists", e.Name)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if e.Status == "running" {
+			if err := ensureNoRunningExperiment(tx, e.Placement, 0); err != nil {
+				return err
+			}
+		}
+		return tx.Create(e).Error
+	})
+}
+
+// SetExperimentStatus starts or stops an experiment
+func SetExperimentStatus(db *gorm.DB, id uint, status string) error {
+	if !ValidExperimentStatuses[status] {
+		return fmt.Errorf("invalid experiment status: %s", status)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var experiment Experiment
+		if err := tx.First(&experiment, id).Error; err != nil {
+			return fmt.Errorf("experiment not found")
+		}
+		if status == "running" {
+			if err := ensureNoRunningExperiment(tx, experiment.Placement, id); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&experiment).Update("status", status).Error
+	})
+}
+
+// Helper function to allow only one running experiment per placement
+func ensureNoRunningExperiment(tx *gorm.DB, placement string, exceptID uint) error {
+	// Lock the placement's experiments (or the index gap when it has none) so concurrent starts wait for each other
+	var experiments []Experiment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id, status").
+		Where("placement = ?", placement).
+		Find(&experiments).Error
+	if err != nil {
+		return err
+	}
+
+	for _, e := range experiments {
+		if e.ID != exceptID && e.Status == "running" {
+			return fmt.Errorf("another experiment is already running on %s", placement)
+		}
+	}
+	return nil
+}
+
+// GetExperiments returns all experiments with their variants
+func GetExperiments(db *gorm.DB) ([]Experiment, error) {
+	var experiments []Experiment
+	err := db.Preload("Variants").Order("id DESC").Find(&experiments).Error
+	return experiments, err
+}
+
+// AssignVariant deterministically buckets a visitor into a variant by weight
+func AssignVariant(e *Experiment, visitorKey string) *ExperimentVariant {
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(e.Name + ":" + visitorKey))
+	bucket := int(h.Sum32() % uint32(total))
+
+	for i := range e.Variants {
+		bucket -= e.Variants[i].Weight
+		if bucket < 0 {
+			return &e.Variants[i]
+		}
+	}
+	return &e.Variants[len(e.Variants)-1]
+}
+
+// ServeRecommendations returns placement recommendations, routing visitors through a running experiment
+func ServeRecommendations(db *gorm.DB, placement string, ctx RecommendationContext) ([]ScoredItem, error) {
+	var experiment Experiment
+	err := db.Preload("Variants", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+		Where("placement = ? AND status = ?", placement, "running").
+		First(&experiment).Error
+
+	key := visitorKey(ctx)
+	if err != nil || key == "" {
+		return RecommendForPlacement(db, placement, ctx)
+	}
+
+	variant := AssignVariant(&experiment, key)
+	if variant == nil {
+		return RecommendForPlacement(db, placement, ctx)
+	}
+
+	if ctx.Limit <= 0 {
+		config, _ := GetPlacement(placement)
+		ctx.Limit = config.Limit
+	}
+	items, err := BlendRecommendations(db, variant.Strategies, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := logExposure(db, &experiment, variant, ctx, items); err != nil {
+		fmt.Printf("Failed to log experiment exposure: %v\n", err)
+	}
+
+	// Tag the items so clients can attribute clicks and cart adds to the experiment
+	for i := range items {
+		items[i].ExperimentID = experiment.ID
+	}
+	return items, nil
+}
+
+// Helper function to identify a visitor for bucketing
+func visitorKey(ctx RecommendationContext) string {
+	if ctx.UserID != 0 {
+		return fmt.Sprintf("u:%d", ctx.UserID)
+	}
+	if ctx.GuestID != "" {
+		return "g:" + ctx.GuestID
+	}
+	return ""
+}
+
+// Helper function to record an exposure and the products shown
+func logExposure(db *gorm.DB, e *Experiment, v *ExperimentVariant, ctx RecommendationContext, items []ScoredItem) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		exposure := ExperimentExposure{
+			ExperimentID: e.ID,
+			VariantID:    v.ID,
+			GuestID:      ctx.GuestID,
+			ProductID:    ctx.ProductID,
+		}
+		if ctx.UserID != 0 {
+			userID := ctx.UserID
+			exposure.UserID = &userID
+			exposure.GuestID = ""
+		}
+		if err := tx.Create(&exposure).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		shown := make([]ExperimentExposureItem, len(items))
+		for i, item := range items {
+			shown[i] = ExperimentExposureItem{ExposureID: exposure.ID, ProductID: item.ID, Position: i + 1}
+		}
+		return tx.Create(&shown).Error
+	})
+}
+
+// GetExperimentReport compares CTR and add-to-cart rate per variant against the control
+func GetExperimentReport(db *gorm.DB, id uint) ([]VariantReport, error) {
+	var experiment Experiment
+	if err := db.Preload("Variants", func(tx *gorm.DB) *gorm.DB { return tx.Order("id ASC") }).
+		First(&experiment, id).Error; err != nil {
+		return nil, fmt.Errorf("experiment not found")
+	}
+
+	// Each visitor counts once per variant. A visitor converts when they send a click or
+	// add_to_cart event tagged with the experiment for a product they were shown
+	var rows []struct {
+		VariantID  uint
+		Exposures  int64
+		Clicks     int64
+		AddToCarts int64
+	}
+	err := db.Raw(`
+		WITH Engagements AS (
+			SELECT user_id, guest_id, product_id, created_at as engaged_at,
+				CASE WHEN type = 'add_to_cart' THEN 'cart' ELSE 'click' END as kind
+			FROM events
+			WHERE type IN ('click', 'add_to_cart')
+			AND JSON_UNQUOTE(JSON_EXTRACT(properties, '$.experiment_id')) = ?
+		)
+		SELECT
+			e.variant_id,
+			COUNT(DISTINCT COALESCE(CONCAT('u:', e.user_id), CONCAT('g:', e.guest_id))) as exposures,
+			COUNT(DISTINCT CASE WHEN g.kind = 'click' THEN COALESCE(CONCAT('u:', e.user_id), CONCAT('g:', e.guest_id)) END) as clicks,
+			COUNT(DISTINCT CASE WHEN g.kind = 'cart' THEN COALESCE(CONCAT('u:', e.user_id), CONCAT('g:', e.guest_id)) END) as add_to_carts
+		FROM experiment_exposures e
+		LEFT JOIN experiment_exposure_items i ON i.exposure_id = e.id
+		LEFT JOIN Engagements g ON g.product_id = i.product_id
+			AND g.engaged_at >= e.created_at
+			AND g.engaged_at < DATE_ADD(e.created_at, INTERVAL ? SECOND)
+			AND (
+				(e.user_id IS NOT NULL AND g.user_id = e.user_id)
+				OR (e.user_id IS NULL AND g.guest_id = e.guest_id)
+			)
+		WHERE e.experiment_id = ?
+		GROUP BY e.variant_id
+	`, fmt.Sprintf("%d", id), int(experimentAttributionWindow.Seconds()), id).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byVariant := make(map[uint]int)
+	for i, row := range rows {
+		byVariant[row.VariantID] = i
+	}
+
+	reports := make([]VariantReport, 0, len(experiment.Variants))
+	for _, v := range experiment.Variants {
+		report := VariantReport{VariantID: v.ID, Variant: v.Name}
+		if i, ok := byVariant[v.ID]; ok {
+			report.Exposures = rows[i].Exposures
+			report.Clicks = rows[i].Clicks
+			report.AddToCarts = rows[i].AddToCarts
+		}
+		if report.Exposures > 0 {
+			report.CTR = float64(report.Clicks) / float64(report.Exposures)
+			report.AddToCartRate = float64(report.AddToCarts) / float64(report.Exposures)
+		}
+		reports = append(reports, report)
+	}
+
+	// Compare every other variant with the control
+	if len(reports) > 0 {
+		control := reports[0]
+		reports[0].CTRPValue, reports[0].CartPValue = 1, 1
+		for i := 1; i < len(reports); i++ {
+			_, reports[i].CTRPValue = TwoProportionZTest(control.Clicks, control.Exposures, reports[i].Clicks, reports[i].Exposures)
+			_, reports[i].CartPValue = TwoProportionZTest(control.AddToCarts, control.Exposures, reports[i].AddToCarts, reports[i].Exposures)
+			reports[i].CTRSignificant = reports[i].CTRPValue < 0.05
+			reports[i].CartSignificant = reports[i].CartPValue < 0.05
+		}
+	}
+
+	return reports, nil
+}
+
+// TwoProportionZTest returns the z statistic and two-sided p-value for x1/n1 vs x2/n2
+func TwoProportionZTest(x1, n1, x2, n2 int64) (float64, float64) {
+	if n1 == 0 || n2 == 0 {
+		return 0, 1
+	}
+	p1 := float64(x1) / float64(n1)
+	p2 := float64(x2) / float64(n2)
+	pooled := float64(x1+x2) / float64(n1+n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return 0, 1
+	}
+	z := (p2 - p1) / se
+	return z, math.Erfc(math.Abs(z) / math.Sqrt2)
+}
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Test tokens understood by the fake gateway
+const (
+	FakeTokenApprove           = "tok_approve"            // Authorized and captured immediately
+	FakeTokenDecline           = "tok_decline"            // Declined at authorization
+	FakeTokenInsufficientFunds = "tok_insufficient_funds" // Declined at authorization
+	FakeTokenDelayed           = "tok_delayed"            // Capture confirmed later by webhook
+	FakeTokenCaptureFails      = "tok_capture_fails"      // Capture pending, then a payment.failed webhook
+	FakeTokenGatewayError      = "tok_gateway_error"      // Gateway unavailable
+)
+
+// FakeWebhook is a signed delivery produced by the fake gateway
+type FakeWebhook struct {
+	Payload   []byte
+	Signature string
+}
+
+type fakePayment struct {
+	amount   float64
+	token    string
+	captured bool
+	refunded bool
+}
+
+// FakeGateway is a deterministic in-process PaymentProvider for development and tests.
+// Outcomes are chosen by the payment token; references and event IDs are sequential.
+type FakeGateway struct {
+	Secret     string        // Webhook signing secret
+	Delay      time.Duration // How long delayed captures wait before their webhook
+	Duplicates int           // Extra copies sent of every webhook
+
+	// Deliver receives webhooks as they are sent. When nil they are queued for DrainWebhooks.
+	Deliver func(FakeWebhook)
+
+	mu       sync.Mutex
+	seq      int
+	eventSeq int
+	payments map[string]*fakePayment
+	queue    []FakeWebhook
+}
+
+// NewFakeGateway creates a fake gateway signing webhooks with secret
+func NewFakeGateway(secret string) *FakeGateway {
+	return &FakeGateway{
+		Secret:   secret,
+		payments: make(map[string]*fakePayment),
+	}
+}
+
+func (g *FakeGateway) Name() string { return "fake" }
+
+// Authorize approves or declines based on the token
+func (g *FakeGateway) Authorize(amount float64, reference, token string) (AuthorizationResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if token == FakeTokenGatewayError {
+		return AuthorizationResult{}, fmt.Errorf("payment gateway unavailable")
+	}
+	if amount <= 0 {
+		return AuthorizationResult{}, fmt.Errorf("amount must be positive")
+	}
+
+	g.seq++
+	ref := fmt.Sprintf("fake_%06d", g.seq)
+
+	switch token {
+	case FakeTokenDecline:
+		return AuthorizationResult{ProviderRef: ref, DeclineReason: "card_declined"}, nil
+	case FakeTokenInsufficientFunds:
+		return AuthorizationResult{ProviderRef: ref, DeclineReason: "insufficient_funds"}, nil
+	}
+
+	g.payments[ref] = &fakePayment{amount: amount, token: token}
+	return AuthorizationResult{ProviderRef: ref, Approved: true}, nil
+}
+
+// Capture settles an authorization, immediately or later by webhook depending on its token
+func (g *FakeGateway) Capture(providerRef string, amount float64) (CaptureResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	payment, ok := g.payments[providerRef]
+	if !ok {
+		return CaptureResult{}, fmt.Errorf("unknown payment: %s", providerRef)
+	}
+	if amount > payment.amount {
+		return CaptureResult{}, fmt.Errorf("capture exceeds authorized amount")
+	}
+
+	switch payment.token {
+	case FakeTokenDelayed:
+		payment.captured = true
+		g.sendLater(WebhookEvent{Type: WebhookPaymentCaptured, ProviderRef: providerRef, Amount: amount})
+		return CaptureResult{Status: PaymentPending}, nil
+	case FakeTokenCaptureFails:
+		g.sendLater(WebhookEvent{Type: WebhookPaymentFailed, ProviderRef: providerRef, Amount: amount, Reason: "processing_error"})
+		return CaptureResult{Status: PaymentPending}, nil
+	}
+
+	payment.captured = true
+	g.send(WebhookEvent{Type: WebhookPaymentCaptured, ProviderRef: providerRef, Amount: amount})
+	return CaptureResult{Status: PaymentCaptured}, nil
+}
+
+// Refund returns a captured payment
+func (g *FakeGateway) Refund(providerRef string, amount float64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	payment, ok := g.payments[providerRef]
+	if !ok || !payment.captured {
+		return fmt.Errorf("payment not captured: %s", providerRef)
+	}
+	if payment.refunded {
+		return fmt.Errorf("payment already refunded")
+	}
+
+	payment.refunded = true
+	g.send(WebhookEvent{Type: WebhookPaymentRefunded, ProviderRef: providerRef, Amount: amount})
+	return nil
+}
+
+// VerifyWebhook checks the HMAC-SHA256 signature and decodes the event
+func (g *FakeGateway) VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	if !hmac.Equal([]byte(g.sign(payload)), []byte(signature)) {
+		return nil, fmt.Errorf("invalid webhook signature")
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload")
+	}
+	return &event, nil
+}
+
+// DrainWebhooks returns and clears the queued webhooks
+func (g *FakeGateway) DrainWebhooks() []FakeWebhook {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	queued := g.queue
+	g.queue = nil
+	return queued
+}
+
+// Helper function to sign a webhook payload
+func (g *FakeGateway) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(g.Secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Helper function to build a signed webhook (and its duplicates); callers hold g.mu
+func (g *FakeGateway) build(event WebhookEvent) []FakeWebhook {
+	g.eventSeq++
+	event.ID = fmt.Sprintf("evt_fake_%06d", g.eventSeq)
+
+	payload, _ := json.Marshal(event)
+	webhook := FakeWebhook{Payload: payload, Signature: g.sign(payload)}
+
+	deliveries := make([]FakeWebhook, 0, g.Duplicates+1)
+	for i := 0; i <= g.Duplicates; i++ {
+		deliveries = append(deliveries, webhook)
+	}
+	return deliveries
+}
+
+// Helper function to send a webhook right away; callers hold g.mu
+func (g *FakeGateway) send(event WebhookEvent) {
+	deliveries := g.build(event)
+	if g.Deliver == nil {
+		g.queue = append(g.queue, deliveries...)
+		return
+	}
+	deliver := g.Deliver
+	go func() {
+		for _, d := range deliveries {
+			deliver(d)
+		}
+	}()
+}
+
+// Helper function to send a webhook after the configured delay; callers hold g.mu
+func (g *FakeGateway) sendLater(event WebhookEvent) {
+	if g.Delay <= 0 || g.Deliver == nil {
+		g.send(event)
+		return
+	}
+	deliveries := g.build(event)
+	deliver := g.Deliver
+	time.AfterFunc(g.Delay, func() {
+		for _, d := range deliveries {
+			deliver(d)
+		}
+	})
+}
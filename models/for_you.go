@@ -0,0 +1,231 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// PlacementForYou is the personalized feed placement
+const PlacementForYou = "for_you"
+
+// recentHistorySize is how many recently viewed products seed the feed
+const recentHistorySize = 20
+
+func init() {
+	RegisterRecommender(recentCategoriesRecommender{})
+	RegisterRecommender(coViewedRecommender{})
+
+	placements[PlacementForYou] = Placement{
+		Strategies: []WeightedStrategy{
+			{Strategy: "recent_categories", Weight: 0.4},
+			{Strategy: "co_viewed", Weight: 0.4},
+			{Strategy: "trending", Weight: 0.2},
+		},
+		Limit: 10,
+	}
+}
+
+// GetForYouRecommendations builds a personalized feed, falling back to trending for new visitors
+func GetForYouRecommendations(db *gorm.DB, ctx RecommendationContext) ([]ScoredItem, bool, error) {
+	config, _ := GetPlacement(PlacementForYou)
+	if ctx.Limit <= 0 {
+		ctx.Limit = config.Limit
+	}
+
+	history, err := getRecentlyViewedProductIDs(db, ctx, recentHistorySize)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Never recommend what the visitor has already seen or put in the cart
+	cart, err := getCartProductIDs(db, ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	ctx.Exclude = append(append(ctx.Exclude, history...), cart...)
+
+	// Cold start: nothing to personalize on yet
+	if len(history) == 0 {
+		items, err := BlendRecommendations(db, []WeightedStrategy{{Strategy: "trending", Weight: 1.0}}, ctx)
+		return items, true, err
+	}
+
+	items, err := BlendRecommendations(db, config.Strategies, ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Top up with trending when personalized strategies run short
+	if len(items) < ctx.Limit {
+		fillCtx := ctx
+		fillCtx.Limit = ctx.Limit - len(items)
+		fillCtx.Exclude = append(append([]uint{}, ctx.Exclude...), getScoredIDs(items)...)
+
+		trending, err := BlendRecommendations(db, []WeightedStrategy{{Strategy: "trending", Weight: 0.1}}, fillCtx)
+		if err == nil {
+			items = append(items, trending...)
+		}
+	}
+
+	return items, false, nil
+}
+
+// Helper function to list the visitor's most recently viewed distinct products
+func getRecentlyViewedProductIDs(db *gorm.DB, ctx RecommendationContext, limit int) ([]uint, error) {
+	var ids []uint
+	switch {
+	case ctx.UserID != 0:
+		err := db.Table("user_interactions").
+			Select("product_id").
+			Where("user_id = ?", ctx.UserID).
+			Group("product_id").
+			Order("MAX(viewed_at) DESC").
+			Limit(limit).
+			Pluck("product_id", &ids).Error
+		return ids, err
+	case ctx.GuestID != "":
+		err := db.Table("guest_interactions").
+			Select("product_id").
+			Where("guest_id = ?", ctx.GuestID).
+			Group("product_id").
+			Order("MAX(viewed_at) DESC").
+			Limit(limit).
+			Pluck("product_id", &ids).Error
+		return ids, err
+	}
+	return nil, nil
+}
+
+// Helper function to list the products in the visitor's cart
+func getCartProductIDs(db *gorm.DB, ctx RecommendationContext) ([]uint, error) {
+	owner := CartOwner{UserID: ctx.UserID, GuestID: ctx.GuestID}
+	if owner.validate() != nil {
+		return nil, nil
+	}
+	var ids []uint
+	err := owner.scope(db.Model(&CartItem{})).
+		Pluck("product_id", &ids).Error
+	return ids, err
+}
+
+// Helper function to extract product IDs from scored items
+func getScoredIDs(items []ScoredItem) []uint {
+	ids := make([]uint, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return ids
+}
+
+// recentCategoriesRecommender recommends popular products from recently viewed categories
+type recentCategoriesRecommender struct{}
+
+func (recentCategoriesRecommender) Name() string { return "recent_categories" }
+
+func (recentCategoriesRecommender) Recommend(db *gorm.DB, ctx RecommendationContext) ([]ScoredItem, error) {
+	history, err := getRecentlyViewedProductIDs(db, ctx, recentHistorySize)
+	if err != nil || len(history) == 0 {
+		return nil, err
+	}
+
+	// Rank categories by how recently they were viewed
+	var viewed []Product
+	if err := db.Select("id, category").Where("id IN ?", history).Find(&viewed).Error; err != nil {
+		return nil, err
+	}
+	categoryOf := make(map[uint]string, len(viewed))
+	for _, p := range viewed {
+		categoryOf[p.ID] = p.Category
+	}
+	categoryWeight := make(map[string]float64)
+	var categories []string
+	for i, id := range history {
+		category, ok := categoryOf[id]
+		if !ok {
+			continue
+		}
+		if _, seen := categoryWeight[category]; !seen {
+			categories = append(categories, category)
+		}
+		categoryWeight[category] += float64(len(history)-i) / float64(len(history))
+	}
+	if len(categories) == 0 {
+		return nil, nil
+	}
+
+	var recs []ProductRecommendation
+	err = db.Raw(`
+		SELECT
+			p.id, p.name, p.description, p.price, p.category, p.stock,
+			COALESCE(t.total_views, 0) as view_count
+		FROM products p
+		LEFT JOIN trending_products t ON p.id = t.product_id
+		WHERE p.category IN ?
+		AND p.id NOT IN ?
+		AND p.stock > 0
+		ORDER BY view_count DESC, id ASC
+		LIMIT ?
+	`, categories, history, ctx.Limit*len(categories)).Scan(&recs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Combine category recency with popularity rank inside the category
+	items := rankScored(recs, "Because you viewed similar products")
+	var maxWeight float64
+	for _, w := range categoryWeight {
+		if w > maxWeight {
+			maxWeight = w
+		}
+	}
+	for i := range items {
+		items[i].Score *= categoryWeight[items[i].Category] / maxWeight
+	}
+	return items, nil
+}
+
+// coViewedRecommender recommends products co-viewed with the visitor's recent history
+type coViewedRecommender struct{}
+
+func (coViewedRecommender) Name() string { return "co_viewed" }
+
+func (coViewedRecommender) Recommend(db *gorm.DB, ctx RecommendationContext) ([]ScoredItem, error) {
+	history, err := getRecentlyViewedProductIDs(db, ctx, 5)
+	if err != nil || len(history) == 0 {
+		return nil, err
+	}
+
+	// More recent anchors count for more
+	merged := make(map[uint]*ScoredItem)
+	var order []uint
+	for i, productID := range history {
+		recs, err := GetCollaborativeRecommendations(db, productID, ctx.Limit)
+		if err != nil {
+			return nil, err
+		}
+		anchorWeight := float64(len(history)-i) / float64(len(history))
+		for _, item := range rankScored(recs, "Customers who viewed your items also viewed") {
+			existing, ok := merged[item.ID]
+			if !ok {
+				copied := item
+				copied.Score = 0
+				existing = &copied
+				merged[item.ID] = existing
+				order = append(order, item.ID)
+			}
+			existing.Score += anchorWeight * item.Score
+		}
+	}
+
+	items := make([]ScoredItem, 0, len(merged))
+	var maxScore float64
+	for _, id := range order {
+		items = append(items, *merged[id])
+		if merged[id].Score > maxScore {
+			maxScore = merged[id].Score
+		}
+	}
+	for i := range items {
+		items[i].Score /= maxScore
+	}
+	return items, nil
+}
@@ -15,6 +15,15 @@ type GuestInteraction struct {
 
 // Track guest view
 func TrackGuestView(db *gorm.DB, guestID string, productID uint) error {
+	// Hand off to the ingestion pipeline when it is running
+	if queued, err := enqueueView(viewRecord{
+		GuestID:   guestID,
+		ProductID: productID,
+		ViewedAt:  time.Now(),
+	}); queued {
+		return err
+	}
+
 	// First get product title
 	var product Product
 	if err := db.Select("name").First(&product, productID).Error; err != nil {
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GuestUserLink records that a guest ID belongs to a registered user
+type GuestUserLink struct {
+	GuestID   string    `gorm:"primaryKey;size:255;column:guest_id" json:"guest_id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName overrides the table name
+func (GuestUserLink) TableName() string {
+	return "guest_user_links"
+}
+
+// LinkGuestToUser links a guest ID to a user and moves the guest's history onto the user
+func LinkGuestToUser(db *gorm.DB, guestID string, userID uint) error {
+	if guestID == "" {
+		return fmt.Errorf("guest ID cannot be empty")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// A guest ID stays with the first user it was linked to
+		var existing GuestUserLink
+		if err := tx.Where("guest_id = ?", guestID).First(&existing).Error; err == nil {
+			if existing.UserID != userID {
+				return fmt.Errorf("guest ID already linked to another user")
+			}
+		} else {
+			link := GuestUserLink{GuestID: guestID, UserID: userID}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&link).Error; err != nil {
+				return err
+			}
+		}
+
+		// Move guest views into the user's history, keeping the latest time of a clashing view
+		if err := tx.Exec(`
+			INSERT INTO user_interactions (user_id, product_id, viewed_at)
+			SELECT ?, product_id, viewed_at
+			FROM guest_interactions
+			WHERE guest_id = ?
+			ON DUPLICATE KEY UPDATE viewed_at = GREATEST(user_interactions.viewed_at, VALUES(viewed_at))
+		`, userID, guestID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("guest_id = ?", guestID).Delete(&GuestInteraction{}).Error; err != nil {
+			return err
+		}
+
+		// Attribute the guest's events to the user
+		return tx.Model(&Event{}).
+			Where("guest_id = ? AND user_id IS NULL", guestID).
+			Update("user_id", userID).Error
+	})
+}
+
+// Helper function to write views of linked guests as their users' views. The locking read makes a
+// concurrent LinkGuestToUser either commit first or wait until these views are written, so queued
+// views cannot land in guest_interactions after the guest's history was moved.
+func attributeLinkedGuestViews(tx *gorm.DB, userViews []UserInteraction, guestViews []GuestInteraction) ([]UserInteraction, []GuestInteraction, error) {
+	if len(guestViews) == 0 {
+		return userViews, guestViews, nil
+	}
+
+	guestIDs := make([]string, 0, len(guestViews))
+	for _, view := range guestViews {
+		guestIDs = append(guestIDs, view.GuestID)
+	}
+	var links []GuestUserLink
+	if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Where("guest_id IN ?", guestIDs).Find(&links).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(links) == 0 {
+		return userViews, guestViews, nil
+	}
+
+	linked := make(map[string]uint, len(links))
+	for _, link := range links {
+		linked[link.GuestID] = link.UserID
+	}
+
+	// Build new slices so a retried batch starts again from its original views
+	users := append([]UserInteraction{}, userViews...)
+	var guests []GuestInteraction
+	for _, view := range guestViews {
+		if userID, ok := linked[view.GuestID]; ok {
+			users = append(users, UserInteraction{UserID: userID, ProductID: view.ProductID, ViewedAt: view.ViewedAt})
+			continue
+		}
+		guests = append(guests, view)
+	}
+	return users, guests, nil
+}
@@ -0,0 +1,361 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrIngestionQueueFull is returned when a view is dropped because the queue is full
+var ErrIngestionQueueFull = fmt.Errorf("ingestion queue full")
+
+// IngestionConfig controls the buffered view ingestion pipeline
+type IngestionConfig struct {
+	QueueSize     int           // Maximum number of buffered views
+	Workers       int           // Number of goroutines writing batches
+	BatchSize     int           // Maximum views written per batch
+	FlushInterval time.Duration // Flush partial batches at least this often
+	BlockTimeout  time.Duration // How long Enqueue waits on a full queue before dropping (0 drops immediately)
+}
+
+// DefaultIngestionConfig returns the settings used when none are configured
+func DefaultIngestionConfig() IngestionConfig {
+	return IngestionConfig{
+		QueueSize:     10000,
+		Workers:       2,
+		BatchSize:     200,
+		FlushInterval: time.Second,
+		BlockTimeout:  0,
+	}
+}
+
+// IngestionStats exposes pipeline counters for tuning
+type IngestionStats struct {
+	QueueDepth    int    `json:"queue_depth"`
+	QueueCapacity int    `json:"queue_capacity"`
+	Enqueued      uint64 `json:"enqueued"`
+	Dropped       uint64 `json:"dropped"`
+	Written       uint64 `json:"written"`
+	Failed        uint64 `json:"failed"`
+	Batches       uint64 `json:"batches"`
+}
+
+// viewBatchAttempts is how many times a batch is written when it hits a deadlock
+const viewBatchAttempts = 3
+
+// viewRecord is a single product view waiting to be written
+type viewRecord struct {
+	UserID    uint
+	GuestID   string
+	ProductID uint
+	ViewedAt  time.Time
+}
+
+// viewBucket is a product's trending bucket that a batch adds views to
+type viewBucket struct {
+	productID uint
+	start     time.Time
+}
+
+// IngestionPipeline buffers product views and writes them in batches
+type IngestionPipeline struct {
+	db     *gorm.DB
+	config IngestionConfig
+	queue  chan viewRecord
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+
+	enqueued atomic.Uint64
+	dropped  atomic.Uint64
+	written  atomic.Uint64
+	failed   atomic.Uint64
+	batches  atomic.Uint64
+}
+
+// NewIngestionPipeline creates a pipeline and starts its workers
+func NewIngestionPipeline(db *gorm.DB, config IngestionConfig) *IngestionPipeline {
+	defaults := DefaultIngestionConfig()
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+
+	p := &IngestionPipeline{
+		db:     db,
+		config: config,
+		queue:  make(chan viewRecord, config.QueueSize),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+
+	return p
+}
+
+// Enqueue buffers a view, dropping it if the queue stays full past BlockTimeout
+func (p *IngestionPipeline) Enqueue(rec viewRecord) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.dropped.Add(1)
+		return fmt.Errorf("ingestion pipeline closed")
+	}
+
+	select {
+	case p.queue <- rec:
+		p.enqueued.Add(1)
+		return nil
+	default:
+	}
+
+	if p.config.BlockTimeout > 0 {
+		timer := time.NewTimer(p.config.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case p.queue <- rec:
+			p.enqueued.Add(1)
+			return nil
+		case <-timer.C:
+		}
+	}
+
+	p.dropped.Add(1)
+	return ErrIngestionQueueFull
+}
+
+// Close stops accepting views and waits for buffered views to be written
+func (p *IngestionPipeline) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// Stats returns a snapshot of the pipeline counters
+func (p *IngestionPipeline) Stats() IngestionStats {
+	return IngestionStats{
+		QueueDepth:    len(p.queue),
+		QueueCapacity: cap(p.queue),
+		Enqueued:      p.enqueued.Load(),
+		Dropped:       p.dropped.Load(),
+		Written:       p.written.Load(),
+		Failed:        p.failed.Load(),
+		Batches:       p.batches.Load(),
+	}
+}
+
+func (p *IngestionPipeline) worker() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]viewRecord, 0, p.config.BatchSize)
+	for {
+		select {
+		case rec, ok := <-p.queue:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			batch = append(batch, rec)
+			if len(batch) >= p.config.BatchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+func (p *IngestionPipeline) flush(batch []viewRecord) {
+	if len(batch) == 0 {
+		return
+	}
+	p.batches.Add(1)
+
+	if err := writeViewBatch(p.db, batch); err != nil {
+		fmt.Printf("Failed to write view batch of %d: %v\n", len(batch), err)
+		p.failed.Add(uint64(len(batch)))
+		return
+	}
+	p.written.Add(uint64(len(batch)))
+}
+
+// writeViewBatch inserts a batch of views and applies one trending update per product and bucket
+func writeViewBatch(db *gorm.DB, batch []viewRecord) error {
+	// Look up product names once for the whole batch, skipping deleted products
+	productIDs := make([]uint, 0, len(batch))
+	for _, rec := range batch {
+		productIDs = append(productIDs, rec.ProductID)
+	}
+
+	var products []Product
+	if err := db.Select("id, name").Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return err
+	}
+	names := make(map[uint]string, len(products))
+	for _, product := range products {
+		names[product.ID] = product.Name
+	}
+
+	var userViews []UserInteraction
+	var guestViews []GuestInteraction
+	viewCounts := make(map[viewBucket]int)
+	for _, rec := range batch {
+		if _, ok := names[rec.ProductID]; !ok {
+			continue
+		}
+		if rec.GuestID != "" {
+			guestViews = append(guestViews, GuestInteraction{
+				GuestID:   rec.GuestID,
+				ProductID: rec.ProductID,
+				ViewedAt:  rec.ViewedAt,
+			})
+		} else {
+			userViews = append(userViews, UserInteraction{
+				UserID:    rec.UserID,
+				ProductID: rec.ProductID,
+				ViewedAt:  rec.ViewedAt,
+			})
+		}
+		// Views count towards the bucket they happened in, not the one they were flushed in
+		viewCounts[viewBucket{rec.ProductID, rec.ViewedAt.Truncate(TrendingBucketSize)}]++
+	}
+
+	// Update counters in a fixed order so concurrent batches lock rows the same way
+	buckets := make([]viewBucket, 0, len(viewCounts))
+	for bucket := range viewCounts {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].productID != buckets[j].productID {
+			return buckets[i].productID < buckets[j].productID
+		}
+		return buckets[i].start.Before(buckets[j].start)
+	})
+
+	var err error
+	for attempt := 1; attempt <= viewBatchAttempts; attempt++ {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// Guests who signed in while their views were queued already had their history moved
+			userRows, guestRows, err := attributeLinkedGuestViews(tx, userViews, guestViews)
+			if err != nil {
+				return err
+			}
+
+			// Rows are keyed by visitor, product and view time, so only duplicate views within the same second collapse
+			if len(userRows) > 0 {
+				err := tx.Clauses(clause.Insert{Modifier: "IGNORE"}).Omit(clause.Associations).Create(&userRows).Error
+				if err != nil {
+					return err
+				}
+			}
+			if len(guestRows) > 0 {
+				err := tx.Clauses(clause.Insert{Modifier: "IGNORE"}).Omit(clause.Associations).Create(&guestRows).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			for _, bucket := range buckets {
+				if err := incrementTrendingViews(tx, bucket.productID, names[bucket.productID], viewCounts[bucket], bucket.start); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if !isDeadlock(err) {
+			return err
+		}
+		// The whole transaction was rolled back, so the batch can be replayed as is
+		time.Sleep(time.Duration(attempt) * 50 * time.Millisecond)
+	}
+	return err
+}
+
+// Package-level pipeline used by TrackUserView and TrackGuestView when started
+var (
+	ingestionMu sync.RWMutex
+	ingestion   *IngestionPipeline
+)
+
+// StartIngestion starts the shared pipeline so views are written asynchronously
+func StartIngestion(db *gorm.DB, config IngestionConfig) *IngestionPipeline {
+	ingestionMu.Lock()
+	defer ingestionMu.Unlock()
+
+	if ingestion == nil {
+		ingestion = NewIngestionPipeline(db, config)
+	}
+	return ingestion
+}
+
+// StopIngestion flushes the shared pipeline and falls back to synchronous writes
+func StopIngestion() {
+	ingestionMu.Lock()
+	p := ingestion
+	ingestion = nil
+	ingestionMu.Unlock()
+
+	if p != nil {
+		p.Close()
+	}
+}
+
+// GetIngestionStats returns the shared pipeline counters, if it is running
+func GetIngestionStats() (IngestionStats, bool) {
+	ingestionMu.RLock()
+	defer ingestionMu.RUnlock()
+
+	if ingestion == nil {
+		return IngestionStats{}, false
+	}
+	return ingestion.Stats(), true
+}
+
+// Helper function to hand a view to the shared pipeline, reporting false when it is not running
+func enqueueView(rec viewRecord) (bool, error) {
+	ingestionMu.RLock()
+	defer ingestionMu.RUnlock()
+
+	if ingestion == nil {
+		return false, nil
+	}
+	return true, ingestion.Enqueue(rec)
+}
+
+// Helper function to detect a MySQL deadlock, after which the transaction can be retried
+func isDeadlock(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1213
+}
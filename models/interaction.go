@@ -27,6 +27,15 @@ type ProductView struct {
 
 // Track product view for authenticated user
 func TrackUserView(db *gorm.DB, userID uint, productID uint) error {
+	// Hand off to the ingestion pipeline when it is running
+	if queued, err := enqueueView(viewRecord{
+		UserID:    userID,
+		ProductID: productID,
+		ViewedAt:  time.Now(),
+	}); queued {
+		return err
+	}
+
 	// First get product title
 	var product Product
 	if err := db.Select("name").First(&product, productID).Error; err != nil {
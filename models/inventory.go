@@ -0,0 +1,274 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Inventory movement types
+const (
+	MovementReceipt    = "receipt"    // Stock received from a supplier
+	MovementAdjustment = "adjustment" // Manual change, e.g. from a product edit
+	MovementSale       = "sale"       // Sold through checkout
+	MovementReturn     = "return"     // Returned by a customer
+	MovementCorrection = "correction" // Fix after a stock count or reconciliation
+)
+
+// Add valid movement types constant
+var ValidMovementTypes = map[string]bool{
+	MovementReceipt:    true,
+	MovementAdjustment: true,
+	MovementSale:       true,
+	MovementReturn:     true,
+	MovementCorrection: true,
+}
+
+// stockActorKey is the gorm setting that carries the user making stock changes
+const stockActorKey = "inventory:actor_id"
+
+// InventoryMovement is an append-only ledger entry; Quantity is the signed change in stock
+type InventoryMovement struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ProductID    uint      `gorm:"not null;index" json:"product_id"`
+	Type         string    `gorm:"type:varchar(20);not null" json:"type"`
+	Quantity     int       `gorm:"not null" json:"quantity"`
+	BalanceAfter int       `gorm:"not null" json:"balance_after"`
+	Reason       string    `json:"reason"`
+	ActorID      *uint     `gorm:"index" json:"actor_id"` // Nil for system changes
+	OrderID      *uint     `gorm:"index" json:"order_id,omitempty"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName overrides the table name
+func (InventoryMovement) TableName() string {
+	return "inventory_movements"
+}
+
+// StockMovementInput describes a movement to post to the ledger
+type StockMovementInput struct {
+	ProductID uint
+	Type      string
+	Quantity  int
+	Reason    string
+	ActorID   *uint
+	OrderID   *uint
+}
+
+// StockReconciliation compares a product's stock with its ledger balance
+type StockReconciliation struct {
+	ProductID     uint   `json:"product_id"`
+	Name          string `json:"name"`
+	Stock         int    `json:"stock"`
+	LedgerBalance int    `json:"ledger_balance"`
+	Drift         int    `json:"drift"`
+}
+
+// WithStockActor attributes stock changes made through the returned session to a user
+func WithStockActor(db *gorm.DB, actorID uint) *gorm.DB {
+	// A new session so later chained calls clone the setting instead of sharing one statement
+	return db.Set(stockActorKey, actorID).Session(&gorm.Session{})
+}
+
+// Helper function to read the actor set by WithStockActor
+func stockActor(db *gorm.DB) *uint {
+	if value, ok := db.Get(stockActorKey); ok {
+		if id, ok := value.(uint); ok && id != 0 {
+			return &id
+		}
+	}
+	return nil
+}
+
+// ValidateMovement checks the type and the sign of the quantity
+func ValidateMovement(input StockMovementInput) error {
+	if !ValidMovementTypes[input.Type] {
+		return fmt.Errorf("invalid movement type")
+	}
+	if input.Quantity == 0 {
+		return fmt.Errorf("quantity cannot be zero")
+	}
+
+	switch input.Type {
+	case MovementReceipt, MovementReturn:
+		if input.Quantity < 0 {
+			return fmt.Errorf("%s quantity must be positive", input.Type)
+		}
+	case MovementSale:
+		if input.Quantity > 0 {
+			return fmt.Errorf("sale quantity must be negative")
+		}
+	}
+	return nil
+}
+
+// RecordStockMovement posts a movement and applies it to Product.Stock
+func RecordStockMovement(db *gorm.DB, input StockMovementInput) (*InventoryMovement, error) {
+	if err := ValidateMovement(input); err != nil {
+		return nil, err
+	}
+	if input.ActorID == nil {
+		input.ActorID = stockActor(db)
+	}
+
+	var movement *InventoryMovement
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var product Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, input.ProductID).Error; err != nil {
+			return fmt.Errorf("product not found")
+		}
+
+		balance := product.Stock + input.Quantity
+		if balance < 0 {
+			return fmt.Errorf("insufficient stock")
+		}
+		if err := tx.Model(&product).Update("stock", balance).Error; err != nil {
+			return err
+		}
+
+		var err error
+		movement, err = appendMovement(tx, input, balance)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+// SetStockLevel sets a product's stock to an absolute level, recording the difference in the ledger
+func SetStockLevel(db *gorm.DB, productID uint, stock int, reason string) error {
+	if stock < 0 {
+		return fmt.Errorf("stock cannot be negative")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var product Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productID).Error; err != nil {
+			return fmt.Errorf("product not found")
+		}
+		if product.Stock == stock {
+			return nil
+		}
+
+		_, err := RecordStockMovement(tx, StockMovementInput{
+			ProductID: productID,
+			Type:      MovementAdjustment,
+			Quantity:  stock - product.Stock,
+			Reason:    reason,
+		})
+		return err
+	})
+}
+
+// Helper function to insert a ledger row for a stock change that has already been applied
+func appendMovement(db *gorm.DB, input StockMovementInput, balance int) (*InventoryMovement, error) {
+	if input.ActorID == nil {
+		input.ActorID = stockActor(db)
+	}
+
+	movement := InventoryMovement{
+		ProductID:    input.ProductID,
+		Type:         input.Type,
+		Quantity:     input.Quantity,
+		BalanceAfter: balance,
+		Reason:       input.Reason,
+		ActorID:      input.ActorID,
+		OrderID:      input.OrderID,
+	}
+	if err := db.Create(&movement).Error; err != nil {
+		return nil, err
+	}
+	return &movement, nil
+}
+
+// GetStockHistory returns a product's movements, newest first
+func GetStockHistory(db *gorm.DB, productID uint, limit int) ([]InventoryMovement, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	var movements []InventoryMovement
+	err := db.Where("product_id = ?", productID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&movements).Error
+	return movements, err
+}
+
+// ReconcileStock lists products whose stock differs from the sum of their ledger movements
+func ReconcileStock(db *gorm.DB) ([]StockReconciliation, error) {
+	var results []StockReconciliation
+	err := db.Raw(`
+		SELECT
+			p.id as product_id,
+			p.name,
+			p.stock,
+			COALESCE(SUM(m.quantity), 0) as ledger_balance,
+			p.stock - COALESCE(SUM(m.quantity), 0) as drift
+		FROM products p
+		LEFT JOIN inventory_movements m ON m.product_id = p.id
+		GROUP BY p.id, p.name, p.stock
+		HAVING drift != 0
+		ORDER BY p.id
+	`).Scan(&results).Error
+	return results, err
+}
+
+// FixStockDrift posts a correction for each drifted product so its ledger matches the counted stock.
+// Products created before the ledger existed get their opening balance this way.
+func FixStockDrift(db *gorm.DB) ([]StockReconciliation, error) {
+	drifted, err := ReconcileStock(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var corrected []StockReconciliation
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, r := range drifted {
+			// Stock may have moved since the report, so recompute the drift under the product lock
+			var product Product
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, r.ProductID).Error; err != nil {
+				continue
+			}
+
+			var balance int
+			err := tx.Model(&InventoryMovement{}).
+				Where("product_id = ?", product.ID).
+				Select("COALESCE(SUM(quantity), 0)").
+				Scan(&balance).Error
+			if err != nil {
+				return err
+			}
+
+			drift := product.Stock - balance
+			if drift == 0 {
+				continue
+			}
+
+			_, err = appendMovement(tx, StockMovementInput{
+				ProductID: product.ID,
+				Type:      MovementCorrection,
+				Quantity:  drift,
+				Reason:    "reconciliation",
+			}, product.Stock)
+			if err != nil {
+				return err
+			}
+
+			corrected = append(corrected, StockReconciliation{
+				ProductID:     product.ID,
+				Name:          product.Name,
+				Stock:         product.Stock,
+				LedgerBalance: balance,
+				Drift:         drift,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return corrected, nil
+}
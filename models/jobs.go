@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StartJob runs fn every interval in the background until the returned stop function is called.
+// A job with a non-positive interval is not started.
+func StartJob(name string, interval time.Duration, fn func() error) func() {
+	if interval <= 0 {
+		fmt.Printf("Job %s not started: interval must be positive, got %v\n", name, interval)
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := fn(); err != nil {
+					fmt.Printf("Job %s failed: %v\n", name, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
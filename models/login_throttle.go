@@ -0,0 +1,334 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Add valid login throttle scope constant
+const (
+	LoginScopeAccount = "account" // Keyed by the email address tried, whether or not it has an account
+	LoginScopeIP      = "ip"
+)
+
+// Add valid login audit event type constant
+const (
+	LoginAuditLocked   = "locked"
+	LoginAuditUnlocked = "unlocked"
+)
+
+// LoginProtectionConfig tunes brute-force protection on login
+type LoginProtectionConfig struct {
+	AccountMaxFailures int           // Failures on one email that lock it
+	IPMaxFailures      int           // Failures from one IP that lock it
+	FailureWindow      time.Duration // Failures older than this are forgotten
+	BaseDelay          time.Duration // Wait after the first failure, doubled by each further failure
+	MaxDelay           time.Duration
+	LockoutDuration    time.Duration // First lockout, doubled by each further lockout
+	MaxLockoutDuration time.Duration
+}
+
+// DefaultLoginProtectionConfig returns the protection used unless main overrides it
+func DefaultLoginProtectionConfig() LoginProtectionConfig {
+	return LoginProtectionConfig{
+		AccountMaxFailures: 5,
+		IPMaxFailures:      50,
+		FailureWindow:      15 * time.Minute,
+		BaseDelay:          time.Second,
+		MaxDelay:           30 * time.Second,
+		LockoutDuration:    15 * time.Minute,
+		MaxLockoutDuration: 24 * time.Hour,
+	}
+}
+
+// LoginProtection is the brute-force protection applied by the login route
+var LoginProtection = DefaultLoginProtectionConfig()
+
+// LoginThrottle counts recent failed logins for an email or IP. It lives in the database
+// so every server instance sees the same counts and lockouts.
+type LoginThrottle struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Scope         string     `gorm:"size:16;not null;uniqueIndex:idx_login_throttle_key" json:"scope"`
+	Key           string     `gorm:"column:throttle_key;size:255;not null;uniqueIndex:idx_login_throttle_key" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+	LockedUntil   *time.Time `gorm:"index" json:"locked_until,omitempty"`
+	Lockouts      int        `gorm:"not null;default:0" json:"lockouts"` // Each further lockout lasts longer
+	UpdatedAt     time.Time  `gorm:"index" json:"updated_at"`
+}
+
+// LoginAuditEvent records a lockout or an admin unlock
+type LoginAuditEvent struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Type        string     `gorm:"size:20;not null;index" json:"type"`
+	Scope       string     `gorm:"size:16;not null" json:"scope"`
+	Key         string     `gorm:"column:throttle_key;size:255;not null;index" json:"key"`
+	UserID      *uint      `gorm:"index" json:"user_id,omitempty"` // Account that was locked, if it exists
+	ActorID     *uint      `json:"actor_id,omitempty"`             // Admin who unlocked
+	IP          string     `gorm:"size:45" json:"ip,omitempty"`    // Client whose failure caused the lockout
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+}
+
+// TableName overrides the table name
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
+
+// TableName overrides the table name
+func (LoginAuditEvent) TableName() string {
+	return "login_audit_events"
+}
+
+// LoginBlock tells a client to stop trying for a while
+type LoginBlock struct {
+	Scope      string
+	Locked     bool // Locked out, rather than asked to slow down
+	RetryAfter time.Duration
+}
+
+// CheckLogin returns a block when the email or IP is locked out or must wait after a failure, or nil
+func CheckLogin(db *gorm.DB, email, ip string, now time.Time) (*LoginBlock, error) {
+	var throttles []LoginThrottle
+	err := db.Where("(scope = ? AND throttle_key = ?) OR (scope = ? AND throttle_key = ?)",
+		LoginScopeAccount, normalizeLoginEmail(email), LoginScopeIP, ip).
+		Find(&throttles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var block *LoginBlock
+	for i := range throttles {
+		block = longerBlock(block, throttles[i].block(now))
+	}
+	return block, nil
+}
+
+// RecordLoginFailure counts a failed login against the email and IP, locking either once it
+// reaches its limit. It returns the block the client now faces.
+func RecordLoginFailure(db *gorm.DB, email, ip string, now time.Time) (*LoginBlock, error) {
+	var block *LoginBlock
+	err := db.Transaction(func(tx *gorm.DB) error {
+		keys := []struct {
+			scope, key  string
+			maxFailures int
+		}{
+			{LoginScopeAccount, normalizeLoginEmail(email), LoginProtection.AccountMaxFailures},
+			{LoginScopeIP, ip, LoginProtection.IPMaxFailures},
+		}
+		for _, k := range keys {
+			if k.key == "" {
+				continue
+			}
+			throttle, err := lockLoginThrottle(tx, k.scope, k.key)
+			if err != nil {
+				return err
+			}
+			if err := throttle.fail(tx, k.maxFailures, ip, now); err != nil {
+				return err
+			}
+			block = longerBlock(block, throttle.block(now))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// RecordLoginSuccess forgets the failures and lockout history of an email after a successful login.
+// IP counts are kept, so one valid account cannot be used to reset them.
+func RecordLoginSuccess(db *gorm.DB, email string) error {
+	return db.Where("scope = ? AND throttle_key = ?", LoginScopeAccount, normalizeLoginEmail(email)).
+		Delete(&LoginThrottle{}).Error
+}
+
+// GetLoginLockouts lists the emails and IPs that are locked out now
+func GetLoginLockouts(db *gorm.DB, now time.Time) ([]LoginThrottle, error) {
+	var throttles []LoginThrottle
+	err := db.Where("locked_until > ?", now).Order("locked_until DESC").Find(&throttles).Error
+	return throttles, err
+}
+
+// UnlockLogin lifts a lockout and clears its history on behalf of an admin
+func UnlockLogin(db *gorm.DB, throttleID, actorID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var throttle LoginThrottle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&throttle, throttleID).Error; err != nil {
+			return fmt.Errorf("lockout not found")
+		}
+		return throttle.unlock(tx, actorID)
+	})
+}
+
+// UnlockAccount lifts the lockout of a user's email on behalf of an admin.
+// An account that is not locked is left as it is.
+func UnlockAccount(db *gorm.DB, userID, actorID uint) error {
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var throttle LoginThrottle
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("scope = ? AND throttle_key = ?", LoginScopeAccount, normalizeLoginEmail(user.Email)).
+			First(&throttle).Error
+		if err != nil {
+			return nil
+		}
+		return throttle.unlock(tx, actorID)
+	})
+}
+
+// GetLoginAuditEvents lists the latest lockouts and unlocks, newest first
+func GetLoginAuditEvents(db *gorm.DB, limit int) ([]LoginAuditEvent, error) {
+	var events []LoginAuditEvent
+	err := db.Order("id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// PruneLoginThrottles deletes throttles that are not locked and were not touched for MaxLockoutDuration,
+// which is also how long lockout escalation is remembered
+func PruneLoginThrottles(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Where("(locked_until IS NULL OR locked_until < ?) AND updated_at < ?",
+		now, now.Add(-LoginProtection.MaxLockoutDuration)).
+		Delete(&LoginThrottle{})
+	return result.RowsAffected, result.Error
+}
+
+// Helper function to count a failure, locking the throttle once it reaches maxFailures
+func (t *LoginThrottle) fail(tx *gorm.DB, maxFailures int, ip string, now time.Time) error {
+	if t.LastFailureAt != nil && now.Sub(*t.LastFailureAt) > LoginProtection.FailureWindow {
+		t.Failures = 0
+	}
+	t.Failures++
+	t.LastFailureAt = &now
+
+	if t.Failures >= maxFailures {
+		t.Lockouts++
+		lockedUntil := now.Add(scaledDuration(LoginProtection.LockoutDuration, t.Lockouts, LoginProtection.MaxLockoutDuration))
+		t.LockedUntil = &lockedUntil
+		t.Failures = 0
+
+		event := LoginAuditEvent{
+			Type:        LoginAuditLocked,
+			Scope:       t.Scope,
+			Key:         t.Key,
+			IP:          ip,
+			LockedUntil: &lockedUntil,
+		}
+		if t.Scope == LoginScopeAccount {
+			var user User
+			if tx.Select("user_id").Where("email = ?", t.Key).First(&user).Error == nil {
+				event.UserID = &user.UserID
+			}
+		}
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(t).Updates(map[string]interface{}{
+		"failures":        t.Failures,
+		"last_failure_at": t.LastFailureAt,
+		"locked_until":    t.LockedUntil,
+		"lockouts":        t.Lockouts,
+	}).Error
+}
+
+// Helper function to clear a throttle and record who unlocked it
+func (t *LoginThrottle) unlock(tx *gorm.DB, actorID uint) error {
+	err := tx.Model(t).Updates(map[string]interface{}{
+		"failures":        0,
+		"last_failure_at": nil,
+		"locked_until":    nil,
+		"lockouts":        0,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	event := LoginAuditEvent{
+		Type:    LoginAuditUnlocked,
+		Scope:   t.Scope,
+		Key:     t.Key,
+		ActorID: &actorID,
+	}
+	if t.Scope == LoginScopeAccount {
+		var user User
+		if tx.Select("user_id").Where("email = ?", t.Key).First(&user).Error == nil {
+			event.UserID = &user.UserID
+		}
+	}
+	return tx.Create(&event).Error
+}
+
+// Helper function to work out how long a throttle blocks logins from now, or nil
+func (t *LoginThrottle) block(now time.Time) *LoginBlock {
+	if t.LockedUntil != nil && t.LockedUntil.After(now) {
+		return &LoginBlock{Scope: t.Scope, Locked: true, RetryAfter: t.LockedUntil.Sub(now)}
+	}
+	if t.Failures == 0 || t.LastFailureAt == nil || now.Sub(*t.LastFailureAt) > LoginProtection.FailureWindow {
+		return nil
+	}
+
+	// Progressive delay: each failure doubles the wait before the next attempt
+	delay := scaledDuration(LoginProtection.BaseDelay, t.Failures, LoginProtection.MaxDelay)
+	if wait := t.LastFailureAt.Add(delay).Sub(now); wait > 0 {
+		return &LoginBlock{Scope: t.Scope, RetryAfter: wait}
+	}
+	return nil
+}
+
+// Helper function to create a throttle row if needed and lock it for this transaction
+func lockLoginThrottle(tx *gorm.DB, scope, key string) (*LoginThrottle, error) {
+	// Concurrent instances may race to create the row; the unique index keeps one
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&LoginThrottle{Scope: scope, Key: key}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var throttle LoginThrottle
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("scope = ? AND throttle_key = ?", scope, key).
+		First(&throttle).Error
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// Helper function to keep whichever block lasts longer
+func longerBlock(a, b *LoginBlock) *LoginBlock {
+	if a == nil {
+		return b
+	}
+	if b != nil && b.RetryAfter > a.RetryAfter {
+		return b
+	}
+	return a
+}
+
+// Helper function to double base for each step after the first, up to max
+func scaledDuration(base time.Duration, step int, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < step && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// Helper function to key account throttles the same way however the email was typed
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
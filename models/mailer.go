@@ -0,0 +1,109 @@
+package models
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer delivers an email from the outbox
+type Mailer interface {
+	Send(message *EmailMessage) error
+}
+
+// DefaultMailer delivers queued emails; nil leaves them in the outbox
+var DefaultMailer Mailer
+
+// SMTPMailer sends emails through an SMTP server
+type SMTPMailer struct {
+	Addr     string // host:port
+	Username string // Empty to send without authentication
+	Password string
+	From     string
+}
+
+// NewSMTPMailer creates an SMTP mailer
+func NewSMTPMailer(addr, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		Addr:     addr,
+		Username: username,
+		Password: password,
+		From:     from,
+	}
+}
+
+// Send implements Mailer
+func (m *SMTPMailer) Send(message *EmailMessage) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host := m.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{message.To}, formatEmail(m.From, message))
+}
+
+// FileMailer writes each email to a .eml file in a directory, for local development
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// NewFileMailer creates a file mailer, creating its directory if needed
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %v", err)
+	}
+	return &FileMailer{Dir: dir, From: from}, nil
+}
+
+// Send implements Mailer
+func (m *FileMailer) Send(message *EmailMessage) error {
+	name := fmt.Sprintf("%d-%d.eml", message.ID, time.Now().UnixNano())
+	return os.WriteFile(filepath.Join(m.Dir, name), formatEmail(m.From, message), 0644)
+}
+
+// MemoryMailer keeps sent emails in memory, for tests
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []EmailMessage
+	Fail     error // Returned by Send when set
+}
+
+// Send implements Mailer
+func (m *MemoryMailer) Send(message *EmailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Fail != nil {
+		return m.Fail
+	}
+	m.messages = append(m.messages, *message)
+	return nil
+}
+
+// Messages returns the emails sent so far
+func (m *MemoryMailer) Messages() []EmailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]EmailMessage(nil), m.messages...)
+}
+
+// Helper function to render a plain text email with its headers
+func formatEmail(from string, message *EmailMessage) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,400 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Order statuses
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderShipped   = "shipped"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+	OrderRefunded  = "refunded"
+)
+
+// Add valid order statuses constant
+var ValidOrderStatuses = map[string]bool{
+	OrderPending:   true,
+	OrderPaid:      true,
+	OrderShipped:   true,
+	OrderDelivered: true,
+	OrderCancelled: true,
+	OrderRefunded:  true,
+}
+
+// Order is a checked out cart
+type Order struct {
+	ID         uint        `gorm:"primaryKey" json:"id"`
+	UserID     uint        `gorm:"not null;index" json:"user_id"`
+	Status     string      `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	TotalItems int         `gorm:"not null" json:"total_items"`
+	Subtotal   float64     `gorm:"not null;default:0" json:"subtotal"`
+	Discount   float64     `gorm:"not null;default:0" json:"discount_total"`
+	Shipping   float64     `gorm:"not null;default:0" json:"shipping_total"`
+	Tax        float64     `gorm:"not null;default:0" json:"tax_total"`
+	TotalPrice float64     `gorm:"not null" json:"total_price"` // Subtotal minus discounts, plus shipping and tax
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	Items      []OrderItem `gorm:"foreignKey:OrderID" json:"items,omitempty"`
+	Payments   []Payment   `gorm:"foreignKey:OrderID" json:"payments,omitempty"`
+
+	Discounts       []PromotionRedemption `gorm:"foreignKey:OrderID" json:"discounts,omitempty"`
+	ShippingAddress Address               `gorm:"embedded;embeddedPrefix:shipping_" json:"shipping_address"`
+}
+
+// OrderItem is a product line of an order, with name and price captured at checkout
+type OrderItem struct {
+	ID          uint    `gorm:"primaryKey" json:"-"`
+	OrderID     uint    `gorm:"not null;index" json:"-"`
+	ProductID   uint    `gorm:"not null;index" json:"product_id"` // Kept even if the product is deleted later
+	ProductName string  `gorm:"not null" json:"name"`
+	Category    string  `json:"category"`
+	UnitPrice   float64 `gorm:"not null" json:"unit_price"`
+	Quantity    int     `gorm:"not null" json:"quantity"`
+	Subtotal    float64 `gorm:"not null" json:"subtotal"`
+}
+
+// TableName overrides the table name
+func (Order) TableName() string {
+	return "orders"
+}
+
+// TableName overrides the table name
+func (OrderItem) TableName() string {
+	return "order_items"
+}
+
+// OrderFilter narrows the admin order list
+type OrderFilter struct {
+	UserID uint
+	Status string
+	Limit  int
+	Offset int
+}
+
+// PendingOrderTTL is how long an unpaid order holds its stock before it is cancelled
+var PendingOrderTTL = 30 * time.Minute
+
+// Checkout turns the user's cart into an order, decrementing stock and clearing the cart atomically
+func Checkout(db *gorm.DB, userID uint) (*Order, error) {
+	return CheckoutTo(db, userID, Address{})
+}
+
+// CheckoutTo checks out the user's cart, charging shipping and tax for the address
+func CheckoutTo(db *gorm.DB, userID uint, address Address) (*Order, error) {
+	var order Order
+	address = normalizeAddress(address)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the cart rows so a concurrent checkout of the same cart waits and then finds it empty
+		var items []CartItem
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", userID).
+			Order("product_id").
+			Find(&items).Error
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return fmt.Errorf("cart is empty")
+		}
+
+		order = Order{UserID: userID, Status: OrderPending, ShippingAddress: address}
+		balances := make([]int, 0, len(items))
+		lines := make([]QuoteLine, 0, len(items))
+
+		for _, item := range items {
+			// Lock the product row so concurrent checkouts cannot oversell.
+			// Items are ordered by product ID so locks are always taken in the same order.
+			var product Product
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, item.ProductID).Error
+			if err != nil {
+				return fmt.Errorf("product not found")
+			}
+			// Price changes must be accepted through cart revalidation first
+			if item.PriceAtAdd != 0 && item.PriceAtAdd != product.Price {
+				return fmt.Errorf("price changed for %s", product.Name)
+			}
+			// The cart's own reservation counts toward it; other carts' holds do not
+			available, err := GetAvailableStock(tx, item.ProductID, item.ID)
+			if err != nil {
+				return err
+			}
+			if available < item.Quantity {
+				return fmt.Errorf("insufficient stock for %s", product.Name)
+			}
+
+			if err := tx.Model(&product).Update("stock", product.Stock-item.Quantity).Error; err != nil {
+				return err
+			}
+			balances = append(balances, product.Stock-item.Quantity)
+
+			subtotal := float64(item.Quantity) * product.Price
+			order.Items = append(order.Items, OrderItem{
+				ProductID:   product.ID,
+				ProductName: product.Name,
+				Category:    product.Category,
+				UnitPrice:   product.Price,
+				Quantity:    item.Quantity,
+				Subtotal:    subtotal,
+			})
+			order.TotalItems += item.Quantity
+			order.Subtotal += subtotal
+			lines = append(lines, QuoteLine{
+				ProductID: product.ID,
+				Category:  product.Category,
+				Quantity:  item.Quantity,
+				Weight:    product.Weight,
+				Subtotal:  subtotal,
+			})
+		}
+
+		// Price the order with the same discount engine as the cart
+		discounts, couponWarning, err := calculateDiscounts(tx, CartOwner{UserID: userID}, lines)
+		if err != nil {
+			return err
+		}
+		if couponWarning != nil {
+			return fmt.Errorf("coupon %s cannot be applied", couponWarning.Code)
+		}
+		for _, d := range discounts {
+			order.Discount += d.Amount
+		}
+
+		// Shipping and tax are charged the same way the cart quote shows them
+		shipping, tax, err := quoteCharges(address, lines)
+		if err != nil {
+			return err
+		}
+		order.Shipping = shipping.Amount
+		order.Tax = tax.Total
+		order.Subtotal = roundCents(order.Subtotal)
+		order.Discount = roundCents(order.Discount)
+		order.TotalPrice = roundCents(order.Subtotal - order.Discount + order.Shipping + order.Tax)
+
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		if err := redeemDiscounts(tx, &order, discounts); err != nil {
+			return err
+		}
+		if err := markCartsRecovered(tx, userID, order.ID); err != nil {
+			return err
+		}
+
+		// Record each stock decrement as a sale in the inventory ledger
+		for i, item := range order.Items {
+			_, err := appendMovement(tx, StockMovementInput{
+				ProductID: item.ProductID,
+				Type:      MovementSale,
+				Quantity:  -item.Quantity,
+				Reason:    fmt.Sprintf("order %d", order.ID),
+				ActorID:   &userID,
+				OrderID:   &order.ID,
+			}, balances[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		// The stock is sold now, so the cart's reservations go with the cart
+		cartItemIDs := make([]uint, len(items))
+		for i, item := range items {
+			cartItemIDs[i] = item.ID
+		}
+		if err := releaseCartItems(tx, cartItemIDs...); err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", cartItemIDs).Delete(&CartItem{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// CancelOrder cancels one of the user's pending orders and returns its items to stock
+func CancelOrder(db *gorm.DB, userID, orderID uint) (*Order, error) {
+	var order Order
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").
+			Where("id = ? AND user_id = ?", orderID, userID).
+			First(&order).Error
+		if err != nil {
+			return fmt.Errorf("order not found")
+		}
+		if order.Status != OrderPending {
+			return fmt.Errorf("only pending orders can be cancelled")
+		}
+		// The provider may still capture it, so the order has to wait for the outcome
+		busy, err := paymentInProgress(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		if busy {
+			return fmt.Errorf("order has a payment in progress")
+		}
+		return cancelOrder(tx, &order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// ExpirePendingOrders cancels orders left unpaid since before the cutoff and returns how many were cancelled
+func ExpirePendingOrders(db *gorm.DB, before time.Time) (int, error) {
+	var ids []uint
+	err := db.Model(&Order{}).
+		Where("status = ? AND created_at < ?", OrderPending, before).
+		Order("id").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+	for _, id := range ids {
+		expired := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Re-read under lock: the order may have been paid or cancelled since
+			var order Order
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").
+				Where("id = ? AND status = ?", id, OrderPending).
+				Limit(1).Find(&order).Error
+			if err != nil || order.ID == 0 {
+				return err
+			}
+			// A payment still being settled decides the order's fate instead
+			busy, err := paymentInProgress(tx, order.ID)
+			if err != nil || busy {
+				return err
+			}
+			expired = true
+			return cancelOrder(tx, &order)
+		})
+		if err != nil {
+			return cancelled, err
+		}
+		if expired {
+			cancelled++
+		}
+	}
+	return cancelled, nil
+}
+
+// RecordPurchaseEvents records a purchase event for each line of an order, valued at the line subtotal
+func RecordPurchaseEvents(db *gorm.DB, order *Order) error {
+	userID := order.UserID
+	for _, item := range order.Items {
+		productID := item.ProductID
+		event := Event{
+			Type:      EventPurchase,
+			UserID:    &userID,
+			ProductID: &productID,
+			Properties: EventProperties{
+				"order_id": order.ID,
+				"quantity": item.Quantity,
+				"price":    item.UnitPrice,
+				"amount":   item.Subtotal,
+			},
+		}
+		if err := RecordEvent(db, &event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUserOrders returns a user's orders, newest first
+func GetUserOrders(db *gorm.DB, userID uint) ([]Order, error) {
+	var orders []Order
+	err := db.Preload("Items").
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Find(&orders).Error
+	return orders, err
+}
+
+// GetUserOrder returns one of the user's orders
+func GetUserOrder(db *gorm.DB, userID, orderID uint) (*Order, error) {
+	var order Order
+	err := db.Preload("Items").Preload("Payments").Preload("Discounts").
+		Where("id = ? AND user_id = ?", orderID, userID).
+		First(&order).Error
+	if err != nil {
+		return nil, fmt.Errorf("order not found")
+	}
+	return &order, nil
+}
+
+// GetOrder returns any order by ID
+func GetOrder(db *gorm.DB, orderID uint) (*Order, error) {
+	var order Order
+	if err := db.Preload("Items").Preload("Payments").Preload("Discounts").First(&order, orderID).Error; err != nil {
+		return nil, fmt.Errorf("order not found")
+	}
+	return &order, nil
+}
+
+// GetOrders lists orders for admins, newest first
+func GetOrders(db *gorm.DB, filter OrderFilter) ([]Order, int64, error) {
+	if filter.Status != "" && !ValidOrderStatuses[filter.Status] {
+		return nil, 0, fmt.Errorf("invalid order status")
+	}
+
+	query := db.Model(&Order{})
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	var orders []Order
+	err := query.Preload("Items").
+		Order("created_at DESC, id DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&orders).Error
+	return orders, total, err
+}
+
+// Helper function to cancel an unpaid order and return its items to stock
+func cancelOrder(tx *gorm.DB, order *Order) error {
+	if err := tx.Model(order).Update("status", OrderCancelled).Error; err != nil {
+		return err
+	}
+
+	for _, item := range order.Items {
+		_, err := RecordStockMovement(tx, StockMovementInput{
+			ProductID: item.ProductID,
+			Type:      MovementReturn,
+			Quantity:  item.Quantity,
+			Reason:    fmt.Sprintf("order %d cancelled", order.ID),
+			OrderID:   &order.ID,
+		})
+		// The product may have been deleted since the order was placed
+		if err != nil && err.Error() != "product not found" {
+			return err
+		}
+	}
+	// A cancelled order does not use up its promotions
+	return releaseRedemptions(tx, order.ID)
+}
@@ -0,0 +1,398 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Payment statuses
+const (
+	PaymentInitiated  = "initiated" // Claimed for the order before the provider is asked
+	PaymentPending    = "pending"   // Capture submitted, waiting for the provider to confirm
+	PaymentAuthorized = "authorized"
+	PaymentCaptured   = "captured"
+	PaymentDeclined   = "declined"
+	PaymentFailed     = "failed"
+	PaymentRefunding  = "refunding" // Claimed for a refund before the provider is asked
+	PaymentRefunded   = "refunded"
+)
+
+// Webhook event types sent by payment providers
+const (
+	WebhookPaymentCaptured = "payment.captured"
+	WebhookPaymentFailed   = "payment.failed"
+	WebhookPaymentRefunded = "payment.refunded"
+)
+
+// paymentTransitions lists the statuses a payment may move to from each status.
+// Anything else (e.g. a late "captured" after a refund) is ignored.
+var paymentTransitions = map[string]map[string]bool{
+	PaymentPending:    {PaymentCaptured: true, PaymentFailed: true},
+	PaymentAuthorized: {PaymentPending: true, PaymentCaptured: true, PaymentFailed: true},
+	PaymentCaptured:   {PaymentRefunding: true, PaymentRefunded: true},
+	PaymentRefunding:  {PaymentRefunded: true},
+}
+
+// Payment is an attempt to pay for an order through a provider
+type Payment struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	OrderID       uint      `gorm:"not null;index" json:"order_id"`
+	Provider      string    `gorm:"type:varchar(50);not null" json:"provider"`
+	ProviderRef   string    `gorm:"type:varchar(100);uniqueIndex" json:"provider_ref"`
+	Amount        float64   `gorm:"not null" json:"amount"`
+	Status        string    `gorm:"type:varchar(20);not null" json:"status"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName overrides the table name
+func (Payment) TableName() string {
+	return "payments"
+}
+
+// PaymentWebhookEvent records processed webhook deliveries so duplicates are ignored
+type PaymentWebhookEvent struct {
+	EventID     string    `gorm:"primaryKey;type:varchar(100)" json:"event_id"`
+	Provider    string    `gorm:"type:varchar(50);not null" json:"provider"`
+	Type        string    `gorm:"type:varchar(50);not null" json:"type"`
+	ProviderRef string    `gorm:"type:varchar(100);index" json:"provider_ref"`
+	ReceivedAt  time.Time `gorm:"autoCreateTime" json:"received_at"`
+}
+
+// TableName overrides the table name
+func (PaymentWebhookEvent) TableName() string {
+	return "payment_webhook_events"
+}
+
+// AuthorizationResult is the provider's answer to an authorization request
+type AuthorizationResult struct {
+	ProviderRef   string
+	Approved      bool
+	DeclineReason string
+}
+
+// CaptureResult is the provider's answer to a capture request
+type CaptureResult struct {
+	Status string // PaymentCaptured, or PaymentPending when confirmation arrives by webhook
+}
+
+// WebhookEvent is a verified notification from a provider
+type WebhookEvent struct {
+	ID          string  `json:"id"`
+	Type        string  `json:"type"`
+	ProviderRef string  `json:"provider_ref"`
+	Amount      float64 `json:"amount"`
+	Reason      string  `json:"reason,omitempty"`
+}
+
+// PaymentProvider is a payment processor
+type PaymentProvider interface {
+	Name() string
+	Authorize(amount float64, reference, token string) (AuthorizationResult, error)
+	Capture(providerRef string, amount float64) (CaptureResult, error)
+	Refund(providerRef string, amount float64) error
+	VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
+
+var (
+	paymentMu        sync.RWMutex
+	paymentProviders = make(map[string]PaymentProvider)
+)
+
+// DefaultPaymentProvider is the provider used for checkout payments
+var DefaultPaymentProvider = "fake"
+
+// Add valid payment providers constant
+var ValidPaymentProviders = map[string]bool{
+	"fake": true, // Development and tests only
+}
+
+// ValidatePaymentConfig checks a configured provider; an empty provider disables checkout payments
+func ValidatePaymentConfig(provider, webhookSecret string) error {
+	if provider == "" {
+		return nil
+	}
+	if !ValidPaymentProviders[provider] {
+		return fmt.Errorf("invalid payment provider: %s", provider)
+	}
+	if webhookSecret == "" {
+		return fmt.Errorf("payment webhook secret required for %s", provider)
+	}
+	return nil
+}
+
+// RegisterPaymentProvider adds a provider, replacing any with the same name
+func RegisterPaymentProvider(p PaymentProvider) {
+	paymentMu.Lock()
+	defer paymentMu.Unlock()
+	paymentProviders[p.Name()] = p
+}
+
+// GetPaymentProvider looks up a registered provider by name
+func GetPaymentProvider(name string) (PaymentProvider, bool) {
+	paymentMu.RLock()
+	defer paymentMu.RUnlock()
+	p, ok := paymentProviders[name]
+	return p, ok
+}
+
+// PayForCart checks out the user's cart and pays for the order
+func PayForCart(db *gorm.DB, provider PaymentProvider, userID uint, token string) (*Order, *Payment, error) {
+	return PayForCartTo(db, provider, userID, token, Address{})
+}
+
+// PayForCartTo checks out the cart shipped to an address and pays for the order.
+// A declined or failed payment cancels the order and puts the stock back.
+func PayForCartTo(db *gorm.DB, provider PaymentProvider, userID uint, token string, address Address) (*Order, *Payment, error) {
+	order, err := CheckoutTo(db, userID, address)
+	if err != nil {
+		return nil, nil, err
+	}
+	return payOrder(db, provider, order, token)
+}
+
+// PayOrder pays for one of the user's pending orders, e.g. one placed with Checkout.
+// A declined or failed payment cancels the order and puts the stock back.
+func PayOrder(db *gorm.DB, provider PaymentProvider, userID, orderID uint, token string) (*Order, *Payment, error) {
+	order, err := GetUserOrder(db, userID, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return payOrder(db, provider, order, token)
+}
+
+// RefundPayment refunds a captured payment in full
+func RefundPayment(db *gorm.DB, paymentID uint) (*Payment, error) {
+	var payment Payment
+	var provider PaymentProvider
+
+	// Claim the payment under lock so concurrent refunds reach the provider only once
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, paymentID).Error; err != nil {
+			return fmt.Errorf("payment not found")
+		}
+		if payment.Status != PaymentCaptured {
+			return fmt.Errorf("only captured payments can be refunded")
+		}
+
+		var ok bool
+		if provider, ok = GetPaymentProvider(payment.Provider); !ok {
+			return fmt.Errorf("unknown payment provider: %s", payment.Provider)
+		}
+		return tx.Model(&payment).Update("status", PaymentRefunding).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := provider.Refund(payment.ProviderRef, payment.Amount); err != nil {
+		// Hand the payment back so the refund can be tried again
+		releaseErr := db.Model(&Payment{}).
+			Where("id = ? AND status = ?", payment.ID, PaymentRefunding).
+			Update("status", PaymentCaptured).Error
+		if releaseErr != nil {
+			fmt.Printf("Failed to release refund claim on payment %d: %v\n", payment.ID, releaseErr)
+		}
+		return nil, err
+	}
+
+	if err := applyPaymentStatus(db, &payment, PaymentRefunded); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// ProcessPaymentWebhook applies a verified webhook once; it reports false for duplicate deliveries
+func ProcessPaymentWebhook(db *gorm.DB, provider string, event *WebhookEvent) (bool, error) {
+	status := map[string]string{
+		WebhookPaymentCaptured: PaymentCaptured,
+		WebhookPaymentFailed:   PaymentFailed,
+		WebhookPaymentRefunded: PaymentRefunded,
+	}[event.Type]
+	if status == "" {
+		return false, fmt.Errorf("unsupported webhook event: %s", event.Type)
+	}
+
+	processed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Claim the event ID first; a duplicate delivery finds it already there
+		result := tx.Exec(
+			"INSERT IGNORE INTO payment_webhook_events (event_id, provider, type, provider_ref, received_at) VALUES (?, ?, ?, ?, ?)",
+			event.ID, provider, event.Type, event.ProviderRef, time.Now(),
+		)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		var payment Payment
+		if err := tx.Where("provider = ? AND provider_ref = ?", provider, event.ProviderRef).First(&payment).Error; err != nil {
+			return fmt.Errorf("payment not found")
+		}
+		if event.Reason != "" {
+			payment.FailureReason = event.Reason
+		}
+
+		processed = true
+		return applyPaymentStatus(tx, &payment, status)
+	})
+	return processed, err
+}
+
+// Helper function to move a payment to a new status and update its order to match
+func applyPaymentStatus(db *gorm.DB, payment *Payment, status string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Re-read under lock: a webhook may have moved the payment on already
+		var current Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, payment.ID).Error; err != nil {
+			return fmt.Errorf("payment not found")
+		}
+		if current.Status == status || !paymentTransitions[current.Status][status] {
+			*payment = current
+			return nil
+		}
+
+		updates := map[string]interface{}{"status": status}
+		if payment.FailureReason != "" {
+			updates["failure_reason"] = payment.FailureReason
+		}
+		if err := tx.Model(&current).Updates(updates).Error; err != nil {
+			return err
+		}
+		*payment = current
+
+		var order Order
+		if err := tx.Preload("Items").First(&order, payment.OrderID).Error; err != nil {
+			return fmt.Errorf("order not found")
+		}
+
+		switch status {
+		case PaymentCaptured:
+			if order.Status == OrderPending {
+				if err := tx.Model(&order).Update("status", OrderPaid).Error; err != nil {
+					return err
+				}
+				// The sale only counts once the money is in
+				if err := RecordPurchaseEvents(tx, &order); err != nil {
+					fmt.Printf("Failed to record purchase events for order %d: %v\n", order.ID, err)
+				}
+			}
+		case PaymentFailed:
+			if order.Status == OrderPending {
+				return cancelOrder(tx, &order)
+			}
+		case PaymentRefunded:
+			return tx.Model(&order).Update("status", OrderRefunded).Error
+		}
+		return nil
+	})
+}
+
+// Helper function to charge a pending order, cancelling it when the payment does not go through
+func payOrder(db *gorm.DB, provider PaymentProvider, order *Order, token string) (*Order, *Payment, error) {
+	payment := Payment{
+		OrderID:  order.ID,
+		Provider: provider.Name(),
+		Amount:   order.TotalPrice,
+		Status:   PaymentInitiated,
+		// Replaced by the provider's reference; keeps the unique index happy until then
+		ProviderRef: fmt.Sprintf("%s-order-%d-%d", provider.Name(), order.ID, time.Now().UnixNano()),
+	}
+
+	// Claim the order under lock so it is only charged once
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, order.ID).Error; err != nil {
+			return fmt.Errorf("order not found")
+		}
+		if current.Status != OrderPending {
+			return fmt.Errorf("only pending orders can be paid")
+		}
+		busy, err := paymentInProgress(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		if busy {
+			return fmt.Errorf("order has a payment in progress")
+		}
+		return tx.Create(&payment).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	auth, err := provider.Authorize(order.TotalPrice, fmt.Sprintf("order-%d", order.ID), token)
+	if auth.ProviderRef != "" {
+		payment.ProviderRef = auth.ProviderRef
+	}
+	if err != nil || !auth.Approved {
+		payment.Status = PaymentDeclined
+		payment.FailureReason = auth.DeclineReason
+		if err != nil {
+			payment.Status = PaymentFailed
+			payment.FailureReason = err.Error()
+		}
+		if err := failOrderPayment(db, order, &payment); err != nil {
+			return nil, nil, err
+		}
+		return order, &payment, fmt.Errorf("payment declined")
+	}
+
+	payment.Status = PaymentAuthorized
+	err = db.Model(&payment).Updates(map[string]interface{}{
+		"status":       payment.Status,
+		"provider_ref": payment.ProviderRef,
+	}).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	capture, err := provider.Capture(auth.ProviderRef, order.TotalPrice)
+	if err != nil {
+		payment.FailureReason = err.Error()
+		if err := applyPaymentStatus(db, &payment, PaymentFailed); err != nil {
+			return nil, nil, err
+		}
+		order.Status = OrderCancelled
+		return order, &payment, fmt.Errorf("payment failed")
+	}
+
+	if err := applyPaymentStatus(db, &payment, capture.Status); err != nil {
+		return nil, nil, err
+	}
+	if payment.Status == PaymentCaptured {
+		order.Status = OrderPaid
+	}
+	return order, &payment, nil
+}
+
+// Helper function to record a payment that never got authorized and cancel its order
+func failOrderPayment(db *gorm.DB, order *Order, payment *Payment) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(payment).Updates(map[string]interface{}{
+			"status":         payment.Status,
+			"provider_ref":   payment.ProviderRef,
+			"failure_reason": payment.FailureReason,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return cancelOrder(tx, order)
+	})
+}
+
+// Helper function to check whether an order has a payment the provider has not settled yet
+func paymentInProgress(tx *gorm.DB, orderID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&Payment{}).
+		Where("order_id = ? AND status IN ?", orderID, []string{PaymentInitiated, PaymentAuthorized, PaymentPending}).
+		Count(&count).Error
+	return count > 0, err
+}
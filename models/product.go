@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Product struct {
@@ -14,32 +15,38 @@ type Product struct {
 	Price       float64   `gorm:"not null" json:"price"`
 	Category    string    `gorm:"not null" json:"category"`
 	Stock       int       `gorm:"not null" json:"stock"`
+	Weight      float64   `gorm:"not null;default:0" json:"weight"` // Kilograms, used for shipping
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Stock minus active cart reservations, set by FillAvailableToSell
+	AvailableToSell *int `gorm:"-" json:"available_to_sell,omitempty"`
 }
 
 // Add this struct for API responses
 type ProductResponse struct {
-	ID          uint    `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Category    string  `json:"category"`
-	Stock       int     `json:"stock"`
+	ID              uint    `json:"id"`
+	Name            string  `json:"name"`
+	Description     string  `json:"description"`
+	Price           float64 `json:"price"`
+	Category        string  `json:"category"`
+	Stock           int     `json:"stock"`
+	AvailableToSell int     `json:"available_to_sell"`
 }
 
 type ProductWithRecommendations struct {
 	Product struct {
-		ID          uint    `json:"id"`
-		Name        string  `json:"name"`
-		Description string  `json:"description"`
-		Price       float64 `json:"price"`
-		Category    string  `json:"category"`
-		Stock       int     `json:"stock"`
+		ID              uint    `json:"id"`
+		Name            string  `json:"name"`
+		Description     string  `json:"description"`
+		Price           float64 `json:"price"`
+		Category        string  `json:"category"`
+		Stock           int     `json:"stock"`
+		AvailableToSell int     `json:"available_to_sell"`
 	} `json:"product"`
-	CustomersAlsoViewed  []ProductRecommendation `json:"customers_also_viewed"`
-	OtherRecommendations []ProductRecommendation `json:"other_recommendations"`
-	TrendingProducts     []TrendingProduct       `json:"trending_products"`
+	CustomersAlsoViewed  []ScoredItem      `json:"customers_also_viewed"`
+	OtherRecommendations []ScoredItem      `json:"other_recommendations"`
+	TrendingProducts     []TrendingProduct `json:"trending_products"`
 }
 
 // CreateProduct creates a new product with validation
@@ -54,6 +61,11 @@ func CreateProduct(db *gorm.DB, product *Product) error {
 		return fmt.Errorf("stock cannot be negative")
 	}
 
+	// Validate weight
+	if product.Weight < 0 {
+		return fmt.Errorf("weight cannot be negative")
+	}
+
 	// Check for duplicate name
 	var count int64
 	db.Model(&Product{}).Where("name = ?", product.Name).Count(&count)
@@ -61,7 +73,23 @@ func CreateProduct(db *gorm.DB, product *Product) error {
 		return fmt.Errorf("product with name '%s' already exists", product.Name)
 	}
 
-	return db.Create(product).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		if product.Stock == 0 {
+			return nil
+		}
+
+		// Opening balance for the inventory ledger
+		_, err := appendMovement(tx, StockMovementInput{
+			ProductID: product.ID,
+			Type:      MovementReceipt,
+			Quantity:  product.Stock,
+			Reason:    "initial stock",
+		}, product.Stock)
+		return err
+	})
 }
 
 func GetAllProducts(db *gorm.DB) []Product {
@@ -87,6 +115,12 @@ func GetProductByID(db *gorm.DB, id int) (*ProductResponse, error) {
 		Category:    product.Category,
 		Stock:       product.Stock,
 	}
+
+	available, err := GetAvailableStock(db, product.ID)
+	if err != nil {
+		return nil, err
+	}
+	response.AvailableToSell = available
 	return response, nil
 }
 
@@ -97,8 +131,28 @@ func UpdateProduct(db *gorm.DB, p *Product) error {
 		return fmt.Errorf("product with name '%s' already exists", p.Name)
 	}
 
-	result := db.Save(p)
-	return result.Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&existing, p.ID).Error; err != nil {
+			return fmt.Errorf("product not found")
+		}
+
+		if err := tx.Save(p).Error; err != nil {
+			return err
+		}
+
+		// Record stock overwrites in the inventory ledger
+		if p.Stock == existing.Stock {
+			return nil
+		}
+		_, err := appendMovement(tx, StockMovementInput{
+			ProductID: p.ID,
+			Type:      MovementAdjustment,
+			Quantity:  p.Stock - existing.Stock,
+			Reason:    "product update",
+		}, p.Stock)
+		return err
+	})
 }
 
 func DeleteProduct(db *gorm.DB, id int) error {
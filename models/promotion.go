@@ -0,0 +1,581 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Promotion discount types
+const (
+	DiscountPercentage = "percentage"
+	DiscountFixed      = "fixed"
+)
+
+// Add valid discount types constant
+var ValidDiscountTypes = map[string]bool{
+	DiscountPercentage: true,
+	DiscountFixed:      true,
+}
+
+// Promotion scopes: which cart items a discount applies to
+const (
+	PromotionScopeCart     = "cart"
+	PromotionScopeCategory = "category"
+	PromotionScopeProduct  = "product"
+)
+
+// Add valid promotion scopes constant
+var ValidPromotionScopes = map[string]bool{
+	PromotionScopeCart:     true,
+	PromotionScopeCategory: true,
+	PromotionScopeProduct:  true,
+}
+
+// Promotion statuses
+const (
+	PromotionActive = "active"
+	PromotionPaused = "paused"
+)
+
+// Add valid promotion statuses constant
+var ValidPromotionStatuses = map[string]bool{
+	PromotionActive: true,
+	PromotionPaused: true,
+}
+
+// Promotion is an admin-managed discount, applied automatically or through a coupon code
+type Promotion struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Name         string     `gorm:"size:100;not null" json:"name" binding:"required"`
+	Code         *string    `gorm:"size:50;uniqueIndex" json:"code,omitempty"` // Nil for automatic promotions
+	DiscountType string     `gorm:"size:20;not null" json:"discount_type" binding:"required"`
+	Value        float64    `gorm:"not null" json:"value"`
+	Scope        string     `gorm:"size:20;not null;default:cart" json:"scope"`
+	Category     string     `gorm:"size:100" json:"category,omitempty"`
+	ProductID    *uint      `json:"product_id,omitempty"`
+	MinCartValue float64    `gorm:"not null;default:0" json:"min_cart_value"`
+	UsageLimit   int        `gorm:"not null;default:0" json:"usage_limit"`    // 0 for unlimited
+	PerUserLimit int        `gorm:"not null;default:0" json:"per_user_limit"` // 0 for unlimited
+	UsageCount   int        `gorm:"not null;default:0" json:"usage_count"`
+	StartsAt     *time.Time `json:"starts_at,omitempty"`
+	EndsAt       *time.Time `json:"ends_at,omitempty"`
+	Status       string     `gorm:"size:20;not null;default:active;index" json:"status"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// PromotionRedemption records a promotion used on an order
+type PromotionRedemption struct {
+	ID          uint      `gorm:"primaryKey" json:"-"`
+	PromotionID uint      `gorm:"not null;index" json:"promotion_id"`
+	OrderID     uint      `gorm:"not null;index" json:"-"`
+	UserID      uint      `gorm:"not null;index" json:"-"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	Code        string    `gorm:"size:50" json:"code,omitempty"`
+	Amount      float64   `gorm:"not null" json:"amount"`
+	CreatedAt   time.Time `json:"-"`
+}
+
+// CartCoupon is the coupon code applied to a user or guest cart
+type CartCoupon struct {
+	ID          uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"not null;default:0;uniqueIndex:idx_cart_coupon_owner"`
+	GuestID     string `gorm:"type:varchar(100);not null;default:'';uniqueIndex:idx_cart_coupon_owner"`
+	PromotionID uint   `gorm:"not null;index"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// DiscountLine is one promotion applied to a cart
+type DiscountLine struct {
+	PromotionID uint    `json:"promotion_id"`
+	Name        string  `json:"name"`
+	Code        string  `json:"code,omitempty"`
+	Scope       string  `json:"scope"`
+	Amount      float64 `json:"amount"`
+}
+
+// TableName overrides the table name
+func (Promotion) TableName() string {
+	return "promotions"
+}
+
+// TableName overrides the table name
+func (PromotionRedemption) TableName() string {
+	return "promotion_redemptions"
+}
+
+// TableName overrides the table name
+func (CartCoupon) TableName() string {
+	return "cart_coupons"
+}
+
+// CreatePromotion validates and stores a promotion
+func CreatePromotion(db *gorm.DB, p *Promotion) error {
+	if err := validatePromotion(db, p, 0); err != nil {
+		return err
+	}
+	p.UsageCount = 0
+	return db.Create(p).Error
+}
+
+// UpdatePromotion replaces the editable fields of a promotion, keeping its usage count
+func UpdatePromotion(db *gorm.DB, id uint, p *Promotion) (*Promotion, error) {
+	var existing Promotion
+	if err := db.First(&existing, id).Error; err != nil {
+		return nil, fmt.Errorf("promotion not found")
+	}
+	if err := validatePromotion(db, p, id); err != nil {
+		return nil, err
+	}
+
+	p.ID = existing.ID
+	p.UsageCount = existing.UsageCount
+	p.CreatedAt = existing.CreatedAt
+	if err := db.Save(p).Error; err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetPromotionStatus activates or pauses a promotion
+func SetPromotionStatus(db *gorm.DB, id uint, status string) error {
+	if !ValidPromotionStatuses[status] {
+		return fmt.Errorf("invalid promotion status: %s", status)
+	}
+	result := db.Model(&Promotion{}).Where("id = ?", id).Update("status", status)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Setting the current status changes no rows either
+		var count int64
+		db.Model(&Promotion{}).Where("id = ?", id).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("promotion not found")
+		}
+	}
+	return nil
+}
+
+// GetPromotions returns all promotions, newest first
+func GetPromotions(db *gorm.DB) ([]Promotion, error) {
+	var promotions []Promotion
+	err := db.Order("id DESC").Find(&promotions).Error
+	return promotions, err
+}
+
+// GetPromotion returns one promotion
+func GetPromotion(db *gorm.DB, id uint) (*Promotion, error) {
+	var promotion Promotion
+	if err := db.First(&promotion, id).Error; err != nil {
+		return nil, fmt.Errorf("promotion not found")
+	}
+	return &promotion, nil
+}
+
+// Helper function to check and normalise a promotion before it is saved
+func validatePromotion(db *gorm.DB, p *Promotion, exceptID uint) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("promotion name cannot be empty")
+	}
+	if !ValidDiscountTypes[p.DiscountType] {
+		return fmt.Errorf("invalid discount type: %s", p.DiscountType)
+	}
+	if p.Value <= 0 {
+		return fmt.Errorf("discount value must be positive")
+	}
+	if p.DiscountType == DiscountPercentage && p.Value > 100 {
+		return fmt.Errorf("percentage discount cannot exceed 100")
+	}
+
+	if p.Scope == "" {
+		p.Scope = PromotionScopeCart
+	}
+	if !ValidPromotionScopes[p.Scope] {
+		return fmt.Errorf("invalid promotion scope: %s", p.Scope)
+	}
+	switch p.Scope {
+	case PromotionScopeCategory:
+		if p.Category == "" {
+			return fmt.Errorf("category is required for category promotions")
+		}
+		p.ProductID = nil
+	case PromotionScopeProduct:
+		if p.ProductID == nil {
+			return fmt.Errorf("product_id is required for product promotions")
+		}
+		var count int64
+		db.Model(&Product{}).Where("id = ?", *p.ProductID).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("product not found")
+		}
+		p.Category = ""
+	default:
+		p.Category = ""
+		p.ProductID = nil
+	}
+
+	if p.MinCartValue < 0 {
+		return fmt.Errorf("minimum cart value cannot be negative")
+	}
+	if p.UsageLimit < 0 || p.PerUserLimit < 0 {
+		return fmt.Errorf("usage limits cannot be negative")
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return fmt.Errorf("promotion must end after it starts")
+	}
+
+	if p.Status == "" {
+		p.Status = PromotionActive
+	}
+	if !ValidPromotionStatuses[p.Status] {
+		return fmt.Errorf("invalid promotion status: %s", p.Status)
+	}
+
+	// Codes are matched case-insensitively; an empty code makes the promotion automatic
+	if p.Code != nil {
+		code := normalizeCouponCode(*p.Code)
+		if code == "" {
+			p.Code = nil
+		} else {
+			p.Code = &code
+			var count int64
+			db.Model(&Promotion{}).Where("code = ? AND id != ?", code, exceptID).Count(&count)
+			if count > 0 {
+				return fmt.Errorf("coupon code '%s' already exists", code)
+			}
+		}
+	}
+	return nil
+}
+
+// Helper function to normalise a coupon code for storage and lookup
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ApplyCoupon validates a coupon code against the owner's cart and attaches it, replacing any previous coupon
+func ApplyCoupon(db *gorm.DB, owner CartOwner, code string) (*Promotion, error) {
+	if err := owner.validate(); err != nil {
+		return nil, err
+	}
+
+	var promotion Promotion
+	if err := db.Where("code = ?", normalizeCouponCode(code)).First(&promotion).Error; err != nil {
+		return nil, fmt.Errorf("coupon not found")
+	}
+
+	lines, err := cartPricedLines(db, owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPromotion(db, &promotion, owner.UserID, lines, time.Now()); err != nil {
+		return nil, err
+	}
+
+	coupon := CartCoupon{UserID: owner.UserID, PromotionID: promotion.ID}
+	if owner.UserID == 0 {
+		coupon.GuestID = owner.GuestID
+	}
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "guest_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"promotion_id", "updated_at"}),
+	}).Create(&coupon).Error
+	if err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+// RemoveCoupon detaches the coupon from the owner's cart
+func RemoveCoupon(db *gorm.DB, owner CartOwner) error {
+	if err := owner.validate(); err != nil {
+		return err
+	}
+	result := owner.scope(db).Delete(&CartCoupon{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no coupon applied")
+	}
+	return nil
+}
+
+// Helper function to check whether a promotion can apply to cart lines right now
+func checkPromotion(db *gorm.DB, p *Promotion, userID uint, lines []QuoteLine, now time.Time) error {
+	if p.Status != PromotionActive {
+		return fmt.Errorf("coupon is not active")
+	}
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return fmt.Errorf("coupon is not valid yet")
+	}
+	if p.EndsAt != nil && !now.Before(*p.EndsAt) {
+		return fmt.Errorf("coupon has expired")
+	}
+	if p.UsageLimit > 0 && p.UsageCount >= p.UsageLimit {
+		return fmt.Errorf("coupon usage limit reached")
+	}
+
+	// Guests have no redemptions yet; the per-user limit is enforced again at checkout
+	if p.PerUserLimit > 0 && userID != 0 {
+		var used int64
+		if err := db.Model(&PromotionRedemption{}).
+			Where("promotion_id = ? AND user_id = ?", p.ID, userID).
+			Count(&used).Error; err != nil {
+			return err
+		}
+		if used >= int64(p.PerUserLimit) {
+			return fmt.Errorf("coupon already used the maximum number of times")
+		}
+	}
+
+	subtotal := 0.0
+	for _, line := range lines {
+		subtotal += line.Subtotal
+	}
+	if subtotal < p.MinCartValue {
+		return fmt.Errorf("cart total must be at least %.2f", p.MinCartValue)
+	}
+	if eligibleSubtotal(p, lines) == 0 {
+		return fmt.Errorf("coupon does not apply to any item in the cart")
+	}
+	return nil
+}
+
+// Helper function to sum the cart lines a promotion applies to
+func eligibleSubtotal(p *Promotion, lines []QuoteLine) float64 {
+	total := 0.0
+	for _, line := range lines {
+		if promotionApplies(p, line) {
+			total += line.Subtotal
+		}
+	}
+	return total
+}
+
+// Helper function to sum what is left to discount on the lines a promotion applies to
+func eligibleRemaining(p *Promotion, lines []QuoteLine) float64 {
+	total := 0.0
+	for _, line := range lines {
+		if promotionApplies(p, line) {
+			total += line.Subtotal - line.Discount
+		}
+	}
+	return total
+}
+
+// Helper function to check whether a promotion's scope covers a line
+func promotionApplies(p *Promotion, line QuoteLine) bool {
+	switch p.Scope {
+	case PromotionScopeCategory:
+		return line.Category == p.Category
+	case PromotionScopeProduct:
+		return p.ProductID != nil && line.ProductID == *p.ProductID
+	default:
+		return true
+	}
+}
+
+// Helper function to spread a discount over the lines it applies to, in proportion to what is left on each.
+// The last line takes the rounding remainder so the shares add up to the discount.
+func allocateDiscount(p *Promotion, lines []QuoteLine, amount float64) {
+	total := eligibleRemaining(p, lines)
+	last := -1
+	for i := range lines {
+		if promotionApplies(p, lines[i]) && lines[i].Subtotal-lines[i].Discount > 0 {
+			last = i
+		}
+	}
+
+	left := amount
+	for i := range lines {
+		open := lines[i].Subtotal - lines[i].Discount
+		if !promotionApplies(p, lines[i]) || open <= 0 {
+			continue
+		}
+		share := math.Round(amount*open/total*100) / 100
+		if i == last || share > left {
+			share = left
+		}
+		lines[i].Discount += share
+		left -= share
+	}
+}
+
+// Helper function to load the owner's cart as priced lines, skipping deleted products
+func cartPricedLines(db *gorm.DB, owner CartOwner) ([]QuoteLine, error) {
+	var lines []QuoteLine
+	err := owner.scope(db.Table("cart_items")).
+		Select("cart_items.product_id, products.category, cart_items.quantity * products.price AS subtotal").
+		Joins("JOIN products ON products.id = cart_items.product_id").
+		Scan(&lines).Error
+	return lines, err
+}
+
+// Helper function to run the discount engine over cart lines.
+// Automatic promotions apply first, then the cart's coupon; discounts never exceed the subtotal.
+// Each line's Discount is set to its share of the applied discounts.
+// A coupon that cannot apply is reported as a warning instead of a discount line.
+func calculateDiscounts(db *gorm.DB, owner CartOwner, lines []QuoteLine) ([]DiscountLine, *CartWarning, error) {
+	discounts := make([]DiscountLine, 0)
+	if len(lines) == 0 {
+		return discounts, nil, nil
+	}
+
+	now := time.Now()
+	var promotions []Promotion
+	err := db.Where("status = ? AND code IS NULL", PromotionActive).
+		Where("starts_at IS NULL OR starts_at <= ?", now).
+		Where("ends_at IS NULL OR ends_at > ?", now).
+		Order("id").
+		Find(&promotions).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var warning *CartWarning
+	if coupon, err := getCartCoupon(db, owner); err != nil {
+		return nil, nil, err
+	} else if coupon != nil {
+		if err := checkPromotion(db, coupon, owner.UserID, lines, now); err != nil {
+			warning = &CartWarning{
+				Code:    *coupon.Code,
+				Type:    CartWarningCouponNotApplied,
+				Message: fmt.Sprintf("Coupon %s was not applied: %s", *coupon.Code, err.Error()),
+			}
+		} else {
+			promotions = append(promotions, *coupon)
+		}
+	}
+
+	for i := range promotions {
+		p := &promotions[i]
+		// Automatic promotions that do not qualify are skipped silently
+		if p.Code == nil && checkPromotion(db, p, owner.UserID, lines, now) != nil {
+			continue
+		}
+
+		amount := p.Value
+		if p.DiscountType == DiscountPercentage {
+			amount = eligibleSubtotal(p, lines) * p.Value / 100
+		}
+		// Earlier discounts on the same lines leave less to take off
+		amount = math.Min(amount, eligibleRemaining(p, lines))
+		amount = math.Round(amount*100) / 100
+		if amount <= 0 {
+			continue
+		}
+		allocateDiscount(p, lines, amount)
+
+		line := DiscountLine{PromotionID: p.ID, Name: p.Name, Scope: p.Scope, Amount: amount}
+		if p.Code != nil {
+			line.Code = *p.Code
+		}
+		discounts = append(discounts, line)
+	}
+	return discounts, warning, nil
+}
+
+// Helper function to load the promotion behind the owner's coupon, if any
+func getCartCoupon(db *gorm.DB, owner CartOwner) (*Promotion, error) {
+	var coupon CartCoupon
+	result := owner.scope(db).Limit(1).Find(&coupon)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	var promotion Promotion
+	result = db.Limit(1).Find(&promotion, coupon.PromotionID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || promotion.Code == nil {
+		// The promotion was removed or turned automatic
+		return nil, owner.scope(db).Delete(&CartCoupon{}).Error
+	}
+	return &promotion, nil
+}
+
+// Helper function to record the discounts of a new order and consume their usage
+func redeemDiscounts(tx *gorm.DB, order *Order, discounts []DiscountLine) error {
+	for _, d := range discounts {
+		// Lock the promotion so concurrent checkouts cannot exceed the global or per-user limit
+		var promotion Promotion
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&promotion, d.PromotionID).Error; err != nil {
+			return fmt.Errorf("coupon not found")
+		}
+		if promotion.UsageLimit > 0 && promotion.UsageCount >= promotion.UsageLimit {
+			return fmt.Errorf("coupon usage limit reached")
+		}
+		if promotion.PerUserLimit > 0 {
+			var used int64
+			if err := tx.Model(&PromotionRedemption{}).
+				Where("promotion_id = ? AND user_id = ?", promotion.ID, order.UserID).
+				Count(&used).Error; err != nil {
+				return err
+			}
+			if used >= int64(promotion.PerUserLimit) {
+				return fmt.Errorf("coupon already used the maximum number of times")
+			}
+		}
+
+		err := tx.Model(&promotion).Update("usage_count", gorm.Expr("usage_count + 1")).Error
+		if err != nil {
+			return err
+		}
+
+		redemption := PromotionRedemption{
+			PromotionID: d.PromotionID,
+			OrderID:     order.ID,
+			UserID:      order.UserID,
+			Name:        d.Name,
+			Code:        d.Code,
+			Amount:      d.Amount,
+		}
+		if err := tx.Create(&redemption).Error; err != nil {
+			return err
+		}
+		order.Discounts = append(order.Discounts, redemption)
+	}
+	return tx.Where("user_id = ?", order.UserID).Delete(&CartCoupon{}).Error
+}
+
+// Helper function to give back the promotion usage of a cancelled order
+func releaseRedemptions(tx *gorm.DB, orderID uint) error {
+	var redemptions []PromotionRedemption
+	if err := tx.Where("order_id = ?", orderID).Find(&redemptions).Error; err != nil {
+		return err
+	}
+	for _, r := range redemptions {
+		err := tx.Model(&Promotion{}).
+			Where("id = ? AND usage_count > 0", r.PromotionID).
+			Update("usage_count", gorm.Expr("usage_count - 1")).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Where("order_id = ?", orderID).Delete(&PromotionRedemption{}).Error
+}
+
+// Helper function to hand a guest's coupon to the user, unless the user already has one
+func mergeCartCoupon(tx *gorm.DB, guestID string, userID uint) error {
+	var count int64
+	if err := tx.Model(&CartCoupon{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return err
+	}
+	guest := (CartOwner{GuestID: guestID}).scope(tx)
+	if count > 0 {
+		return guest.Delete(&CartCoupon{}).Error
+	}
+	return guest.Model(&CartCoupon{}).Updates(map[string]interface{}{"user_id": userID, "guest_id": ""}).Error
+}
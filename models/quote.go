@@ -0,0 +1,313 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Shipping methods of the default shipping table
+const (
+	ShippingFlat   = "flat"
+	ShippingWeight = "weight"
+)
+
+// Add valid shipping methods constant
+var ValidShippingMethods = map[string]bool{
+	ShippingFlat:   true,
+	ShippingWeight: true,
+}
+
+// Address is where an order ships; tax and shipping rates are looked up by it
+type Address struct {
+	Country    string `gorm:"size:50" json:"country"`
+	Region     string `gorm:"size:50" json:"region"`
+	PostalCode string `gorm:"size:20" json:"postal_code"`
+}
+
+// ValidateShippingAddress checks an address has what checkout needs to charge tax and shipping
+func ValidateShippingAddress(address Address) error {
+	if strings.TrimSpace(address.Country) == "" {
+		return fmt.Errorf("shipping address country is required")
+	}
+	return nil
+}
+
+// QuoteLine is a cart line as seen by the discount engine and the tax and shipping calculators
+type QuoteLine struct {
+	ProductID uint
+	Category  string
+	Quantity  int
+	Weight    float64 // Per unit, in kilograms
+	Subtotal  float64
+	Discount  float64 // Share of the applied discounts
+}
+
+// ShippingQuote is the shipping charge for a cart
+type ShippingQuote struct {
+	Method       string  `json:"method"`
+	Weight       float64 `json:"weight"`
+	Amount       float64 `json:"amount"`
+	FreeShipping bool    `json:"free_shipping"`
+	FreeOver     float64 `json:"free_over,omitempty"`
+}
+
+// TaxLine is the tax charged at one rate
+type TaxLine struct {
+	Category string  `json:"category"`
+	Rate     float64 `json:"rate"`
+	Taxable  float64 `json:"taxable"`
+	Amount   float64 `json:"amount"`
+}
+
+// TaxQuote is the tax charged on a cart
+type TaxQuote struct {
+	Lines []TaxLine `json:"lines"`
+	Total float64   `json:"total"`
+}
+
+// CartQuote is the full price breakdown of a cart shipped to an address
+type CartQuote struct {
+	Address       Address            `json:"address"`
+	Items         []CartItemResponse `json:"items"`
+	Subtotal      float64            `json:"subtotal"`
+	Discounts     []DiscountLine     `json:"discounts"`
+	DiscountTotal float64            `json:"discount_total"`
+	Shipping      ShippingQuote      `json:"shipping"`
+	Tax           TaxQuote           `json:"tax"`
+	Total         float64            `json:"total"`
+	Warnings      []CartWarning      `json:"warnings"`
+}
+
+// TaxCalculator works out the tax on discounted cart lines and the shipping charge
+type TaxCalculator interface {
+	CalculateTax(address Address, lines []QuoteLine, shipping float64) (TaxQuote, error)
+}
+
+// ShippingCalculator works out the shipping charge for discounted cart lines
+type ShippingCalculator interface {
+	CalculateShipping(address Address, lines []QuoteLine) (ShippingQuote, error)
+}
+
+// DefaultTaxCalculator prices tax for cart quotes and checkout
+var DefaultTaxCalculator TaxCalculator = &TaxTable{}
+
+// DefaultShippingCalculator prices shipping for cart quotes and checkout
+var DefaultShippingCalculator ShippingCalculator = &ShippingTable{}
+
+// TaxRate is a row of the tax table; empty fields match anything
+type TaxRate struct {
+	Country  string  `json:"country,omitempty"`
+	Region   string  `json:"region,omitempty"`
+	Category string  `json:"category,omitempty"`
+	Rate     float64 `json:"rate"` // Fraction, e.g. 0.08 for 8%
+}
+
+// TaxTable is the default TaxCalculator: the most specific matching row sets each line's rate
+type TaxTable struct {
+	Rates       []TaxRate `json:"rates"`
+	TaxShipping bool      `json:"tax_shipping"` // Tax shipping at the address's rate with no category
+}
+
+// ShippingRate is a row of the shipping table; empty fields match anything
+type ShippingRate struct {
+	Country  string  `json:"country,omitempty"`
+	Region   string  `json:"region,omitempty"`
+	Method   string  `json:"method"`
+	Amount   float64 `json:"amount"`              // Flat fee, or base fee for weight shipping
+	PerKg    float64 `json:"per_kg,omitempty"`    // Weight shipping only
+	FreeOver float64 `json:"free_over,omitempty"` // Free from this discounted subtotal; 0 never
+}
+
+// ShippingTable is the default ShippingCalculator: the most specific matching row prices the cart.
+// An empty table ships everything for free.
+type ShippingTable struct {
+	Rates []ShippingRate `json:"rates"`
+}
+
+// LoadTaxTable reads a tax table from a JSON file
+func LoadTaxTable(path string) (*TaxTable, error) {
+	var table TaxTable
+	if err := loadJSONFile(path, &table); err != nil {
+		return nil, err
+	}
+	for i, r := range table.Rates {
+		if r.Rate < 0 || r.Rate > 1 {
+			return nil, fmt.Errorf("tax rate %d must be between 0 and 1", i)
+		}
+	}
+	return &table, nil
+}
+
+// LoadShippingTable reads a shipping table from a JSON file
+func LoadShippingTable(path string) (*ShippingTable, error) {
+	var table ShippingTable
+	if err := loadJSONFile(path, &table); err != nil {
+		return nil, err
+	}
+	for i, r := range table.Rates {
+		if !ValidShippingMethods[r.Method] {
+			return nil, fmt.Errorf("shipping rate %d has invalid method: %s", i, r.Method)
+		}
+		if r.Amount < 0 || r.PerKg < 0 || r.FreeOver < 0 {
+			return nil, fmt.Errorf("shipping rate %d cannot have negative amounts", i)
+		}
+	}
+	return &table, nil
+}
+
+// Helper function to decode a JSON config file
+func loadJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid %s: %v", path, err)
+	}
+	return nil
+}
+
+// CalculateTax implements TaxCalculator, grouping lines by category
+func (t *TaxTable) CalculateTax(address Address, lines []QuoteLine, shipping float64) (TaxQuote, error) {
+	quote := TaxQuote{Lines: make([]TaxLine, 0)}
+	byCategory := make(map[string]int)
+
+	add := func(category string, rate, taxable float64) {
+		if rate == 0 || taxable <= 0 {
+			return
+		}
+		i, ok := byCategory[category]
+		if !ok {
+			quote.Lines = append(quote.Lines, TaxLine{Category: category, Rate: rate})
+			i = len(quote.Lines) - 1
+			byCategory[category] = i
+		}
+		quote.Lines[i].Taxable += taxable
+	}
+
+	for _, line := range lines {
+		add(line.Category, t.rateFor(address, line.Category), line.Subtotal-line.Discount)
+	}
+	if t.TaxShipping {
+		add("shipping", t.rateFor(address, ""), shipping)
+	}
+
+	for i := range quote.Lines {
+		quote.Lines[i].Taxable = roundCents(quote.Lines[i].Taxable)
+		quote.Lines[i].Amount = roundCents(quote.Lines[i].Taxable * quote.Lines[i].Rate)
+		quote.Total += quote.Lines[i].Amount
+	}
+	quote.Total = roundCents(quote.Total)
+	return quote, nil
+}
+
+// Helper function to pick the most specific tax rate for an address and category
+func (t *TaxTable) rateFor(address Address, category string) float64 {
+	best, bestScore := 0.0, -1
+	for _, r := range t.Rates {
+		score, ok := matchAddress(r.Country, r.Region, address)
+		if !ok {
+			continue
+		}
+		if r.Category != "" {
+			if r.Category != category {
+				continue
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = r.Rate, score
+		}
+	}
+	return best
+}
+
+// CalculateShipping implements ShippingCalculator
+func (t *ShippingTable) CalculateShipping(address Address, lines []QuoteLine) (ShippingQuote, error) {
+	quote := ShippingQuote{Method: ShippingFlat}
+	subtotal := 0.0
+	for _, line := range lines {
+		quote.Weight += line.Weight * float64(line.Quantity)
+		subtotal += line.Subtotal - line.Discount
+	}
+	quote.Weight = math.Round(quote.Weight*1000) / 1000
+
+	if len(t.Rates) == 0 {
+		quote.FreeShipping = true
+		return quote, nil
+	}
+
+	var rate *ShippingRate
+	bestScore := -1
+	for i := range t.Rates {
+		if score, ok := matchAddress(t.Rates[i].Country, t.Rates[i].Region, address); ok && score > bestScore {
+			rate, bestScore = &t.Rates[i], score
+		}
+	}
+	if rate == nil {
+		return quote, fmt.Errorf("shipping not available to this address")
+	}
+
+	quote.Method = rate.Method
+	quote.FreeOver = rate.FreeOver
+	if rate.FreeOver > 0 && subtotal >= rate.FreeOver {
+		quote.FreeShipping = true
+		return quote, nil
+	}
+
+	quote.Amount = rate.Amount
+	if rate.Method == ShippingWeight {
+		quote.Amount += rate.PerKg * quote.Weight
+	}
+	quote.Amount = roundCents(quote.Amount)
+	return quote, nil
+}
+
+// Helper function to match a table row's country and region against an address.
+// A region only counts together with its country; more specific rows score higher.
+func matchAddress(country, region string, address Address) (int, bool) {
+	score := 0
+	if country != "" {
+		if !strings.EqualFold(country, address.Country) {
+			return 0, false
+		}
+		score += 2
+	}
+	if region != "" {
+		if !strings.EqualFold(region, address.Region) {
+			return 0, false
+		}
+		score += 4
+	}
+	return score, true
+}
+
+// Helper function to round an amount to cents
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// Helper function to tidy an address before rates are looked up
+func normalizeAddress(address Address) Address {
+	return Address{
+		Country:    strings.ToUpper(strings.TrimSpace(address.Country)),
+		Region:     strings.ToUpper(strings.TrimSpace(address.Region)),
+		PostalCode: strings.TrimSpace(address.PostalCode),
+	}
+}
+
+// Helper function to run the shipping and tax calculators over discounted lines
+func quoteCharges(address Address, lines []QuoteLine) (ShippingQuote, TaxQuote, error) {
+	shipping, err := DefaultShippingCalculator.CalculateShipping(address, lines)
+	if err != nil {
+		return ShippingQuote{}, TaxQuote{}, err
+	}
+	tax, err := DefaultTaxCalculator.CalculateTax(address, lines, shipping.Amount)
+	if err != nil {
+		return ShippingQuote{}, TaxQuote{}, err
+	}
+	return shipping, tax, nil
+}
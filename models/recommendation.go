@@ -15,36 +15,92 @@ type ProductRecommendation struct {
 	Relevance   float64 `json:"-"` // Hide from JSON output but keep in struct
 }
 
+// CoViewWeights sets how much each history source contributes to co-view scores
+type CoViewWeights struct {
+	User     float64 // Weight of a registered user's view
+	Guest    float64 // Weight of an unlinked guest's view
+	Wishlist float64 // Weight of a product on a user's wishlist
+}
+
+// CollaborativeWeights is used by GetCollaborativeRecommendations
+var CollaborativeWeights = CoViewWeights{User: 1.0, Guest: 0.5, Wishlist: 1.5}
+
+// visitorViewsSQL lists (visitor, product_id, weight, viewed_at) rows across user and guest histories
+// and wishlists. Guests linked to an account count as that user, so each person is counted once.
+// Placeholders: user weight, guest weight, user weight, wishlist weight.
+const visitorViewsSQL = `
+	SELECT CONCAT('u:', user_id) as visitor, product_id, ? as weight, viewed_at
+	FROM user_interactions
+	UNION ALL
+	SELECT 
+		CASE WHEN l.user_id IS NULL THEN CONCAT('g:', gi.guest_id) ELSE CONCAT('u:', l.user_id) END as visitor,
+		gi.product_id,
+		CASE WHEN l.user_id IS NULL THEN ? ELSE ? END as weight,
+		gi.viewed_at
+	FROM guest_interactions gi
+	LEFT JOIN guest_user_links l ON gi.guest_id = l.guest_id
+	UNION ALL
+	SELECT CONCAT('u:', w.user_id) as visitor, wi.product_id, ? as weight, wi.created_at as viewed_at
+	FROM wishlist_items wi
+	JOIN wishlists w ON wi.wishlist_id = w.id
+`
+
 // GetCollaborativeRecommendations returns exactly 5 most relevant products
 func GetCollaborativeRecommendations(db *gorm.DB, productID uint, limit int) ([]ProductRecommendation, error) {
+	// Read precomputed neighbors first, falling back to the live co-view query
+	similar, err := GetSimilarProducts(db, productID, limit)
+	if err == nil && len(similar) > 0 {
+		return supplementWithCategory(db, productID, limit, similar)
+	}
+
+	return GetWeightedCollaborativeRecommendations(db, productID, limit, CollaborativeWeights)
+}
+
+// GetWeightedCollaborativeRecommendations ranks co-viewed products across user and guest histories
+func GetWeightedCollaborativeRecommendations(db *gorm.DB, productID uint, limit int, weights CoViewWeights) ([]ProductRecommendation, error) {
 	var recommendations []ProductRecommendation
 
-	// Try collaborative filtering first (users who viewed this also viewed)
+	// Try collaborative filtering first (visitors who viewed this also viewed)
 	result := db.Raw(`
-		WITH ProductViews AS (
+		WITH Visits AS (`+visitorViewsSQL+`),
+		TargetVisitors AS (
+			SELECT visitor, MAX(weight) as weight
+			FROM Visits
+			WHERE product_id = ?
+			GROUP BY visitor
+		),
+		CoViews AS (
+			SELECT v.product_id, v.visitor, MAX(v.weight) * MAX(t.weight) as weight
+			FROM Visits v
+			JOIN TargetVisitors t ON v.visitor = t.visitor
+			WHERE v.product_id != ?
+			GROUP BY v.product_id, v.visitor
+		),
+		ProductViews AS (
 			SELECT 
 				p.id, p.name, p.description, p.price, p.category, p.stock,
 				COUNT(*) as view_count,
-				COUNT(*) * 1.0 / (
-					SELECT COUNT(*) FROM user_interactions 
-					WHERE product_id = ui2.product_id
-				) as relevance_score
+				SUM(c.weight) / (SELECT SUM(weight) FROM TargetVisitors) as relevance_score
 			FROM products p
-			JOIN user_interactions ui1 ON p.id = ui1.product_id
-			JOIN user_interactions ui2 ON ui1.user_id = ui2.user_id AND ui2.product_id = ?
-			WHERE p.id != ? AND p.stock > 0
+			JOIN CoViews c ON p.id = c.product_id
+			WHERE p.stock > 0
 			GROUP BY p.id, p.name, p.description, p.price, p.category, p.stock
-			HAVING COUNT(*) >= 1
+			HAVING SUM(c.weight) > 0
 		)
 		SELECT * FROM ProductViews
 		ORDER BY relevance_score DESC, view_count DESC, id ASC
 		LIMIT ?
-	`, productID, productID, limit).Scan(&recommendations)
+	`, weights.User, weights.Guest, weights.User, weights.Wishlist, productID, productID, limit).Scan(&recommendations)
 
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
+	return supplementWithCategory(db, productID, limit, recommendations)
+}
+
+// Helper function to top up recommendations with popular products from the same category
+func supplementWithCategory(db *gorm.DB, productID uint, limit int, recommendations []ProductRecommendation) ([]ProductRecommendation, error) {
 	// If we don't have enough recommendations, supplement with category-based
 	if len(recommendations) < limit {
 		var product Product
@@ -56,7 +112,7 @@ func GetCollaborativeRecommendations(db *gorm.DB, productID uint, limit int) ([]
 		var categoryRecs []ProductRecommendation
 
 		// Get popular products from same category
-		result = db.Raw(`
+		result := db.Raw(`
 			SELECT 
 				p.id, p.name, p.description, p.price, p.category, p.stock,
 				COALESCE(t.total_views, 0) as view_count,
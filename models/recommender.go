@@ -0,0 +1,366 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecommendationContext describes who is asking and what they are looking at
+type RecommendationContext struct {
+	ProductID uint   // Anchor product, 0 when there is none (e.g. homepage)
+	UserID    uint   // Authenticated user, 0 for guests
+	GuestID   string // Guest cookie, empty for users
+	Limit     int
+	Exclude   []uint    // Products that must not be recommended
+	At        time.Time // Time to recommend as of, now when zero (offline evaluation)
+}
+
+// ScoredItem is a recommended product with its score and the reasons it was picked
+type ScoredItem struct {
+	ProductRecommendation
+	Score        float64  `json:"score"`
+	Reasons      []string `json:"reasons"`
+	ExperimentID uint     `json:"experiment_id,omitempty"` // Send back on click and add_to_cart events to attribute them
+}
+
+// Recommender is a single recommendation strategy
+type Recommender interface {
+	Name() string
+	Recommend(db *gorm.DB, ctx RecommendationContext) ([]ScoredItem, error)
+}
+
+// WeightedStrategy selects a registered strategy and its weight in a blend
+type WeightedStrategy struct {
+	Strategy string  `json:"strategy"`
+	Weight   float64 `json:"weight"`
+}
+
+// Placement configures the strategies blended for one spot on the site
+type Placement struct {
+	Strategies []WeightedStrategy `json:"strategies"`
+	Limit      int                `json:"limit"`
+}
+
+// Recommendation placements
+const (
+	PlacementCustomersAlsoViewed  = "customers_also_viewed"
+	PlacementOtherRecommendations = "other_recommendations"
+	PlacementHomepage             = "homepage"
+)
+
+var (
+	registryMu   sync.RWMutex
+	recommenders = make(map[string]Recommender)
+	placements   = DefaultPlacements()
+)
+
+func init() {
+	RegisterRecommender(collaborativeRecommender{})
+	RegisterRecommender(categoryRecommender{})
+	RegisterRecommender(priceRangeRecommender{})
+	RegisterRecommender(trendingRecommender{})
+	RegisterRecommender(popularityRecommender{})
+}
+
+// DefaultPlacements returns the placement settings used when none are configured
+func DefaultPlacements() map[string]Placement {
+	return map[string]Placement{
+		PlacementCustomersAlsoViewed: {
+			Strategies: []WeightedStrategy{{Strategy: "collaborative", Weight: 1.0}},
+			Limit:      5,
+		},
+		PlacementOtherRecommendations: {
+			Strategies: []WeightedStrategy{{Strategy: "category", Weight: 1.0}},
+			Limit:      5,
+		},
+		PlacementHomepage: {
+			Strategies: []WeightedStrategy{
+				{Strategy: "trending", Weight: 0.6},
+				{Strategy: "popularity", Weight: 0.4},
+			},
+			Limit: 10,
+		},
+	}
+}
+
+// RegisterRecommender adds a strategy to the registry, replacing any with the same name
+func RegisterRecommender(r Recommender) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	recommenders[r.Name()] = r
+}
+
+// GetRecommender looks up a registered strategy by name
+func GetRecommender(name string) (Recommender, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := recommenders[name]
+	return r, ok
+}
+
+// SetPlacement configures a placement after checking its strategies are registered
+func SetPlacement(name string, placement Placement) error {
+	if len(placement.Strategies) == 0 {
+		return fmt.Errorf("placement %s has no strategies", name)
+	}
+	for _, s := range placement.Strategies {
+		if _, ok := GetRecommender(s.Strategy); !ok {
+			return fmt.Errorf("unknown recommendation strategy: %s", s.Strategy)
+		}
+		if s.Weight < 0 {
+			return fmt.Errorf("strategy %s has a negative weight", s.Strategy)
+		}
+	}
+	if placement.Limit <= 0 {
+		placement.Limit = 5
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	placements[name] = placement
+	return nil
+}
+
+// GetPlacement returns the configuration of a placement
+func GetPlacement(name string) (Placement, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := placements[name]
+	return p, ok
+}
+
+// LoadPlacements applies a JSON object of placement name to Placement
+func LoadPlacements(data string) error {
+	var configured map[string]Placement
+	if err := json.Unmarshal([]byte(data), &configured); err != nil {
+		return fmt.Errorf("invalid placement config: %v", err)
+	}
+	for name, placement := range configured {
+		if err := SetPlacement(name, placement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecommendForPlacement blends the strategies configured for a placement
+func RecommendForPlacement(db *gorm.DB, placement string, ctx RecommendationContext) ([]ScoredItem, error) {
+	config, ok := GetPlacement(placement)
+	if !ok {
+		return nil, fmt.Errorf("unknown placement: %s", placement)
+	}
+	if ctx.Limit <= 0 {
+		ctx.Limit = config.Limit
+	}
+	return BlendRecommendations(db, config.Strategies, ctx)
+}
+
+// BlendRecommendations merges weighted strategy results into a single ranked list
+func BlendRecommendations(db *gorm.DB, strategies []WeightedStrategy, ctx RecommendationContext) ([]ScoredItem, error) {
+	excluded := make(map[uint]bool, len(ctx.Exclude)+1)
+	for _, id := range ctx.Exclude {
+		excluded[id] = true
+	}
+	if ctx.ProductID != 0 {
+		excluded[ctx.ProductID] = true
+	}
+
+	// Ask each strategy for extra items so exclusions do not leave gaps
+	componentCtx := ctx
+	componentCtx.Limit = ctx.Limit + len(excluded)
+
+	merged := make(map[uint]*ScoredItem)
+	var order []uint
+	var lastErr error
+	for _, s := range strategies {
+		r, ok := GetRecommender(s.Strategy)
+		if !ok {
+			return nil, fmt.Errorf("unknown recommendation strategy: %s", s.Strategy)
+		}
+
+		items, err := r.Recommend(db, componentCtx)
+		if err != nil {
+			// One failing strategy should not blank the whole placement
+			fmt.Printf("Recommendation strategy %s failed: %v\n", s.Strategy, err)
+			lastErr = err
+			continue
+		}
+
+		for _, item := range items {
+			if excluded[item.ID] {
+				continue
+			}
+			existing, ok := merged[item.ID]
+			if !ok {
+				copied := item
+				copied.Score = 0
+				copied.Reasons = nil
+				existing = &copied
+				merged[item.ID] = existing
+				order = append(order, item.ID)
+			}
+			existing.Score += s.Weight * item.Score
+			existing.Reasons = appendUnique(existing.Reasons, item.Reasons...)
+		}
+	}
+
+	if len(merged) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	results := make([]ScoredItem, 0, len(merged))
+	for _, id := range order {
+		results = append(results, *merged[id])
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > ctx.Limit {
+		results = results[:ctx.Limit]
+	}
+	return results, nil
+}
+
+// Helper function to convert a ranked list into scored items (first item scores 1)
+func rankScored(recs []ProductRecommendation, reason string) []ScoredItem {
+	items := make([]ScoredItem, 0, len(recs))
+	for i, rec := range recs {
+		items = append(items, ScoredItem{
+			ProductRecommendation: rec,
+			Score:                 float64(len(recs)-i) / float64(len(recs)),
+			Reasons:               []string{reason},
+		})
+	}
+	return items
+}
+
+// Helper function to append strings that are not already present
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, existing := range list {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// collaborativeRecommender recommends products co-viewed with the anchor product
+type collaborativeRecommender struct{}
+
+func (collaborativeRecommender) Name() string { return "collaborative" }
+
+func (collaborativeRecommender) Recommend(db *gorm.DB, ctx RecommendationContext) ([]ScoredItem, error) {
+	if ctx.ProductID == 0 {
+		return nil, nil
+	}
+	recs, err := GetCollaborativeRecommendations(db, ctx.ProductID, ctx.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return rankScored(recs, "Customers who viewed this also viewed"), nil
+}
+
+// categoryRecommender recommends popular products from the anchor product's category
+type categoryRecommender struct{}
+
+func (categoryRecommender) Name() string { return "category" }
+
+func (categoryRecommender) Recommend(db *gorm.DB, ctx RecommendationContext) ([]ScoredItem, error) {
+	if ctx.ProductID == 0 {
+		return nil, nil
+	}
+	recs, err := GetCategoryRecommendations(db, ctx.ProductID, ctx.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return rankScored(recs, "Similar to this product"), nil
+}
+
+// priceRangeRecommender recommends products priced close to the anchor product
+type priceRangeRecommender struct{}
+
+func (priceRangeRecommender) Name() string { return "price_range" }
+
+func (priceRangeRecommender) Recommend(db *gorm.DB, ctx RecommendationContext) ([]ScoredItem, error) {
+	if ctx.ProductID == 0 {
+		return nil, nil
+	}
+
+	var product Product
+	if err := db.First(&product, ctx.ProductID).Error; err != nil {
+		return nil, err
+	}
+
+	var recs []ProductRecommendation
+	err := db.Raw(`
+		SELECT
+			p.id, p.name, p.description, p.price, p.category, p.stock,
+			COALESCE(t.total_views, 0) as view_count
+		FROM products p
+		LEFT JOIN trending_products t ON p.id = t.product_id
+		WHERE p.id != ?
+		AND p.stock > 0
+		AND ABS(p.price - ?) <= 300
+		ORDER BY ABS(p.price - ?) ASC, view_count DESC, id ASC
+		LIMIT ?
+	`, ctx.ProductID, product.Price, product.Price, ctx.Limit).Scan(&recs).Error
+	if err != nil {
+		return nil, err
+	}
+	return rankScored(recs, "In a similar price range"), nil
+}
+
+// trendingRecommender recommends products trending over the last 24 hours
+type trendingRecommender struct{}
+
+func (trendingRecommender) Name() string { return "trending" }
+
+func (trendingRecommender) Recommend(db *gorm.DB, ctx RecommendationContext) ([]ScoredItem, error) {
+	trending, err := GetWindowedTrendingProducts(db, TrendingOptions{Window: "24h", Limit: ctx.Limit, At: ctx.At})
+	if err != nil {
+		return nil, err
+	}
+	return rankScored(trendingToRecommendations(trending), "Trending now"), nil
+}
+
+// popularityRecommender recommends the most viewed products of all time
+type popularityRecommender struct{}
+
+func (popularityRecommender) Name() string { return "popularity" }
+
+func (popularityRecommender) Recommend(db *gorm.DB, ctx RecommendationContext) ([]ScoredItem, error) {
+	popular, err := GetTrendingProducts(db, ctx.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return rankScored(trendingToRecommendations(popular), "Popular with shoppers"), nil
+}
+
+// Helper function to convert trending rows into recommendation rows
+func trendingToRecommendations(trending []TrendingProduct) []ProductRecommendation {
+	recs := make([]ProductRecommendation, 0, len(trending))
+	for _, t := range trending {
+		recs = append(recs, ProductRecommendation{
+			ID:          t.ID,
+			Name:        t.Name,
+			Description: t.Description,
+			Price:       t.Price,
+			Category:    t.Category,
+			Stock:       t.Stock,
+			ViewCount:   t.ViewCount,
+		})
+	}
+	return recs
+}
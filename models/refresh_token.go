@@ -0,0 +1,250 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefreshTokenTTL is how long a refresh token can be exchanged for a new token pair
+var RefreshTokenTTL = 30 * 24 * time.Hour
+
+// RefreshToken is an opaque, single-use token that renews an access token.
+// Only a SHA-256 hash of the token is stored. Tokens rotated from the same login share a family,
+// so replaying a used token revokes every token and session of that login.
+type RefreshToken struct {
+	ID         uint       `gorm:"primaryKey" json:"-"`
+	UserID     uint       `gorm:"not null;index" json:"-"`
+	FamilyID   string     `gorm:"size:64;not null;index" json:"-"`
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"-"`
+	UsedAt     *time.Time `json:"-"`
+	RevokedAt  *time.Time `json:"-"`
+	ReplacedBy *uint      `json:"-"` // Token issued when this one was used
+	CreatedAt  time.Time  `json:"-"`
+}
+
+// TableName overrides the table name
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// TokenPair is a short-lived access token and the refresh token that renews it
+type TokenPair struct {
+	AccessToken      string `json:"token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`         // Access token lifetime in seconds
+	RefreshExpiresIn int    `json:"refresh_expires_in"` // Refresh token lifetime in seconds
+}
+
+// CreateTokenPair starts a new login: a session for the device and a new refresh token family
+func CreateTokenPair(db *gorm.DB, userID uint, email string, info SessionInfo) (*TokenPair, error) {
+	var pair *TokenPair
+	err := db.Transaction(func(tx *gorm.DB) error {
+		accessToken, err := utils.GenerateToken(userID, email)
+		if err != nil {
+			return err
+		}
+		refresh, record, err := issueRefreshToken(tx, userID, uuid.New().String())
+		if err != nil {
+			return err
+		}
+
+		userAgent := info.UserAgent
+		if len(userAgent) > 255 {
+			userAgent = userAgent[:255]
+		}
+		now := time.Now()
+		session := Session{
+			UserID:     userID,
+			TokenHash:  hashToken(accessToken),
+			FamilyID:   record.FamilyID,
+			Device:     describeDevice(info.UserAgent),
+			UserAgent:  userAgent,
+			IP:         info.IP,
+			IssuedAt:   now,
+			LastSeenAt: now,
+			ExpiresAt:  record.ExpiresAt,
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		pair = newTokenPair(accessToken, refresh)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// RefreshTokenPair exchanges a refresh token for a new token pair in the same family.
+// A token can be used once; presenting a used token again revokes the whole family.
+func RefreshTokenPair(db *gorm.DB, refreshToken string, info SessionInfo) (*TokenPair, error) {
+	var pair *TokenPair
+	reused := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var token RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(refreshToken)).
+			First(&token).Error
+		if err != nil {
+			return fmt.Errorf("invalid refresh token")
+		}
+
+		if token.RevokedAt != nil {
+			return fmt.Errorf("refresh token revoked")
+		}
+		if token.UsedAt != nil {
+			reused = true
+			return revokeRefreshFamily(tx, token.FamilyID)
+		}
+		if time.Now().After(token.ExpiresAt) {
+			return fmt.Errorf("refresh token expired")
+		}
+
+		var user User
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			return fmt.Errorf("invalid refresh token")
+		}
+
+		// The device keeps its session; only its access token changes
+		var session Session
+		if err := tx.Where("family_id = ?", token.FamilyID).First(&session).Error; err != nil {
+			return fmt.Errorf("refresh token revoked")
+		}
+
+		accessToken, err := utils.GenerateToken(user.UserID, user.Email)
+		if err != nil {
+			return err
+		}
+		refresh, next, err := issueRefreshToken(tx, user.UserID, token.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		err = tx.Model(&session).Updates(map[string]interface{}{
+			"token_hash":   hashToken(accessToken),
+			"issued_at":    now,
+			"last_seen_at": now,
+			"ip":           info.IP,
+			"expires_at":   next.ExpiresAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		pair = newTokenPair(accessToken, refresh)
+		return tx.Model(&token).Updates(map[string]interface{}{
+			"used_at":     now,
+			"replaced_by": next.ID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The revocation is committed before the reuse is reported
+	if reused {
+		return nil, fmt.Errorf("refresh token reuse detected")
+	}
+	return pair, nil
+}
+
+// EndSession logs out an access token's session and revokes its refresh token family
+func EndSession(db *gorm.DB, token string) error {
+	var session Session
+	if err := db.Where("token_hash = ?", hashToken(token)).First(&session).Error; err != nil {
+		return nil // Already signed out
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return endSession(tx, &session)
+	})
+}
+
+// RevokeRefreshToken revokes the family of a refresh token, e.g. on logout without an access token
+func RevokeRefreshToken(db *gorm.DB, refreshToken string) error {
+	var token RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(refreshToken)).First(&token).Error; err != nil {
+		return fmt.Errorf("invalid refresh token")
+	}
+	return revokeRefreshFamily(db, token.FamilyID)
+}
+
+// PruneAuthTokens deletes expired refresh tokens, sessions and account tokens, returning how many rows went
+func PruneAuthTokens(db *gorm.DB) (int64, error) {
+	var pruned int64
+	for _, model := range []interface{}{&RefreshToken{}, &Session{}, &AccountToken{}} {
+		result := db.Where("expires_at < ?", time.Now()).Delete(model)
+		if result.Error != nil {
+			return pruned, result.Error
+		}
+		pruned += result.RowsAffected
+	}
+	return pruned, nil
+}
+
+// Helper function to store a new refresh token in a family, returning the raw token
+func issueRefreshToken(tx *gorm.DB, userID uint, familyID string) (string, *RefreshToken, error) {
+	raw, err := newRefreshToken()
+	if err != nil {
+		return "", nil, err
+	}
+	record := RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, &record, nil
+}
+
+// Helper function to describe a new token pair to the client
+func newTokenPair(accessToken, refreshToken string) *TokenPair {
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        int(utils.AccessTokenTTL.Seconds()),
+		RefreshExpiresIn: int(RefreshTokenTTL.Seconds()),
+	}
+}
+
+// Helper function to revoke every refresh token and end every session of a family
+func revokeRefreshFamily(db *gorm.DB, familyID string) error {
+	err := db.Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return err
+	}
+	return db.Where("family_id = ?", familyID).Delete(&Session{}).Error
+}
+
+// Helper function to generate a random opaque refresh token
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Helper function to hash an access or refresh token for storage and lookup
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
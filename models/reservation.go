@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReservationTTL is how long a cart item holds its stock
+var ReservationTTL = 15 * time.Minute
+
+// StockReservation holds stock for a cart item until it expires or the item leaves the cart
+type StockReservation struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CartItemID uint      `gorm:"not null;uniqueIndex" json:"cart_item_id"`
+	ProductID  uint      `gorm:"not null;index" json:"product_id"`
+	Quantity   int       `gorm:"not null" json:"quantity"`
+	ExpiresAt  time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName overrides the table name
+func (StockReservation) TableName() string {
+	return "stock_reservations"
+}
+
+// GetAvailableStock returns stock minus active reservations, ignoring the given cart items' own holds
+func GetAvailableStock(db *gorm.DB, productID uint, excludeCartItemIDs ...uint) (int, error) {
+	var product Product
+	if err := db.Select("id, stock").First(&product, productID).Error; err != nil {
+		return 0, err
+	}
+
+	query := db.Model(&StockReservation{}).
+		Where("product_id = ? AND expires_at > ?", productID, time.Now())
+	if len(excludeCartItemIDs) > 0 {
+		query = query.Where("cart_item_id NOT IN ?", excludeCartItemIDs)
+	}
+
+	var reserved int
+	if err := query.Select("COALESCE(SUM(quantity), 0)").Scan(&reserved).Error; err != nil {
+		return 0, err
+	}
+
+	if available := product.Stock - reserved; available > 0 {
+		return available, nil
+	}
+	return 0, nil
+}
+
+// FillAvailableToSell sets AvailableToSell on each product
+func FillAvailableToSell(db *gorm.DB, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+
+	var rows []struct {
+		ProductID uint
+		Reserved  int
+	}
+	err := db.Model(&StockReservation{}).
+		Select("product_id, SUM(quantity) as reserved").
+		Where("product_id IN ? AND expires_at > ?", ids, time.Now()).
+		Group("product_id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	reserved := make(map[uint]int, len(rows))
+	for _, r := range rows {
+		reserved[r.ProductID] = r.Reserved
+	}
+
+	for i := range products {
+		available := products[i].Stock - reserved[products[i].ID]
+		if available < 0 {
+			available = 0
+		}
+		products[i].AvailableToSell = &available
+	}
+	return nil
+}
+
+// ReleaseExpiredReservations deletes reservations past their expiry and returns how many were released
+func ReleaseExpiredReservations(db *gorm.DB) (int64, error) {
+	result := db.Where("expires_at <= ?", time.Now()).Delete(&StockReservation{})
+	return result.RowsAffected, result.Error
+}
+
+// Helper function to create or refresh the reservation of a cart item
+func reserveCartItem(tx *gorm.DB, item *CartItem) error {
+	// Guest lines are soft holds: guest IDs cost nothing, so they could otherwise lock up stock.
+	// The line is reserved once it moves into a user's cart at login.
+	if item.UserID == 0 {
+		return releaseCartItems(tx, item.ID)
+	}
+
+	reservation := StockReservation{
+		CartItemID: item.ID,
+		ProductID:  item.ProductID,
+		Quantity:   item.Quantity,
+		ExpiresAt:  time.Now().Add(ReservationTTL),
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "cart_item_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"product_id", "quantity", "expires_at", "updated_at"}),
+	}).Create(&reservation).Error
+}
+
+// Helper function to release the reservations of cart items
+func releaseCartItems(tx *gorm.DB, cartItemIDs ...uint) error {
+	if len(cartItemIDs) == 0 {
+		return nil
+	}
+	return tx.Where("cart_item_id IN ?", cartItemIDs).Delete(&StockReservation{}).Error
+}
@@ -2,15 +2,30 @@ package models
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/amcishara/web_Tracking_system/utils"
 	"gorm.io/gorm"
 )
 
+// sessionTouchInterval limits how often last_seen_at is written for a busy session
+const sessionTouchInterval = time.Minute
+
+// Session is one signed-in device. Its access token is stored only as a SHA-256 hash
+// and is replaced each time the login's refresh token rotates.
 type Session struct {
-	UserID    uint      `gorm:"primaryKey;column:user_id" json:"user_id"`
-	Token     string    `gorm:"primaryKey;unique" json:"token"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"not null;index;column:user_id" json:"user_id"`
+	TokenHash  string    `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	FamilyID   string    `gorm:"size:64;index" json:"-"` // Refresh token family that issued the session
+	Device     string    `gorm:"size:100" json:"device"`
+	UserAgent  string    `gorm:"size:255" json:"user_agent"`
+	IP         string    `gorm:"size:45" json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`              // Signed in
+	IssuedAt   time.Time `gorm:"not null;index" json:"-"` // Current access token issued
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `gorm:"not null;index" json:"expires_at"` // End of the login unless refreshed
 }
 
 // TableName overrides the table name
@@ -18,23 +33,39 @@ func (Session) TableName() string {
 	return "sessions"
 }
 
+// SessionInfo describes the client a session was started from
+type SessionInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// SessionResponse is a session as listed to its user
+type SessionResponse struct {
+	Session
+	Current bool `json:"current"`
+}
+
 func CreateSession(db *gorm.DB, userID uint, token string) error {
+	now := time.Now()
 	session := Session{
-		UserID: userID,
-		Token:  token,
+		UserID:     userID,
+		TokenHash:  hashToken(token),
+		IssuedAt:   now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(utils.AccessTokenTTL),
 	}
 	return db.Create(&session).Error
 }
 
 func DeleteSession(db *gorm.DB, token string) error {
-	result := db.Where("token = ?", token).Delete(&Session{})
+	result := db.Where("token_hash = ?", hashToken(token)).Delete(&Session{})
 	if result.Error != nil {
 		return result.Error
 	}
 
 	// Verify deletion
 	var count int64
-	db.Model(&Session{}).Where("token = ?", token).Count(&count)
+	db.Model(&Session{}).Where("token_hash = ?", hashToken(token)).Count(&count)
 	if count > 0 {
 		return fmt.Errorf("failed to delete session")
 	}
@@ -42,11 +73,127 @@ func DeleteSession(db *gorm.DB, token string) error {
 	return nil
 }
 
+// GetSession returns the session of an access token issued less than utils.AccessTokenTTL ago
 func GetSession(db *gorm.DB, token string) (*Session, error) {
 	var session Session
-	result := db.Where("token = ? AND created_at > DATE_SUB(NOW(), INTERVAL 24 HOUR)", token).First(&session)
+	ttl := int(utils.AccessTokenTTL.Seconds())
+	result := db.Where("token_hash = ? AND issued_at > DATE_SUB(NOW(), INTERVAL ? SECOND)", hashToken(token), ttl).First(&session)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return &session, nil
 }
+
+// TouchSession records that a session was used, at most once per sessionTouchInterval
+func TouchSession(db *gorm.DB, session *Session, ip string) error {
+	if time.Since(session.LastSeenAt) < sessionTouchInterval && session.IP == ip {
+		return nil
+	}
+	session.LastSeenAt = time.Now()
+	session.IP = ip
+	return db.Model(session).Updates(map[string]interface{}{
+		"last_seen_at": session.LastSeenAt,
+		"ip":           ip,
+	}).Error
+}
+
+// GetUserSessions lists a user's active sessions, most recently used first
+func GetUserSessions(db *gorm.DB, userID, currentID uint) ([]SessionResponse, error) {
+	var sessions []Session
+	err := db.Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC, id DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		responses[i] = SessionResponse{Session: session, Current: session.ID == currentID}
+	}
+	return responses, nil
+}
+
+// RevokeSession signs one of the user's devices out and revokes its refresh tokens
+func RevokeSession(db *gorm.DB, userID, sessionID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var session Session
+		if err := tx.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+			return fmt.Errorf("session not found")
+		}
+		return endSession(tx, &session)
+	})
+}
+
+// RevokeUserSessions signs a user out everywhere except keepID (0 keeps nothing)
+// and returns how many sessions ended
+func RevokeUserSessions(db *gorm.DB, userID, keepID uint) (int, error) {
+	ended := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var sessions []Session
+		if err := tx.Where("user_id = ? AND id <> ?", userID, keepID).Find(&sessions).Error; err != nil {
+			return err
+		}
+		for i := range sessions {
+			if err := endSession(tx, &sessions[i]); err != nil {
+				return err
+			}
+		}
+		ended = len(sessions)
+
+		// Refresh tokens whose session row is already gone
+		query := tx.Model(&RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+		var keep Session
+		if keepID != 0 && tx.First(&keep, keepID).Error == nil && keep.FamilyID != "" {
+			query = query.Where("family_id <> ?", keep.FamilyID)
+		}
+		return query.Update("revoked_at", time.Now()).Error
+	})
+	return ended, err
+}
+
+// Helper function to delete a session and revoke its refresh token family
+func endSession(tx *gorm.DB, session *Session) error {
+	if session.FamilyID != "" {
+		if err := revokeRefreshFamily(tx, session.FamilyID); err != nil {
+			return err
+		}
+	}
+	return tx.Delete(session).Error
+}
+
+// Helper function to name the browser and OS of a user agent, e.g. "Chrome on Windows"
+func describeDevice(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	for _, os := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, os.token) {
+			return browser + " on " + os.name
+		}
+	}
+	return browser
+}
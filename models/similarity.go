@@ -0,0 +1,221 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProductSimilarity is a precomputed neighbor of a product
+type ProductSimilarity struct {
+	ProductID        uint      `gorm:"primaryKey;column:product_id" json:"product_id"`
+	SimilarProductID uint      `gorm:"primaryKey;column:similar_product_id" json:"similar_product_id"`
+	Score            float64   `gorm:"not null" json:"score"`
+	Support          int       `gorm:"not null" json:"support"` // Number of visitors who viewed both
+	ComputedAt       time.Time `json:"computed_at"`
+}
+
+// TableName overrides the table name
+func (ProductSimilarity) TableName() string {
+	return "product_similarities"
+}
+
+// Add valid similarity metrics constant
+var ValidSimilarityMetrics = map[string]bool{
+	"cosine":  true,
+	"jaccard": true,
+}
+
+// SimilarityConfig controls how the item-to-item index is computed
+type SimilarityConfig struct {
+	Metric             string        // "cosine" or "jaccard"
+	MinSupport         int           // Minimum co-viewing visitors for a pair to be kept
+	TopK               int           // Neighbors stored per product
+	MaxItemsPerVisitor int           // Caps pair generation for very long histories
+	Weights            CoViewWeights // Weight of user and guest views and wishlists
+}
+
+// SimilaritySettings is used by the background job and admin rebuilds
+var SimilaritySettings = DefaultSimilarityConfig()
+
+// DefaultSimilarityConfig returns the settings used when none are configured
+func DefaultSimilarityConfig() SimilarityConfig {
+	return SimilarityConfig{
+		Metric:             "cosine",
+		MinSupport:         2,
+		TopK:               20,
+		MaxItemsPerVisitor: 100,
+		Weights:            CollaborativeWeights,
+	}
+}
+
+type productPair struct {
+	a, b uint
+}
+
+type pairStats struct {
+	support int
+	overlap float64 // Sum over shared visitors of w_a*w_b (cosine) or min(w_a, w_b) (Jaccard)
+}
+
+// visitorItem is a product in a visitor's history, weighted by how the visitor engaged with it
+type visitorItem struct {
+	productID uint
+	weight    float64
+}
+
+// ComputeProductSimilarities rebuilds product_similarities from user and guest co-views
+func ComputeProductSimilarities(db *gorm.DB, config SimilarityConfig) error {
+	if !ValidSimilarityMetrics[config.Metric] {
+		return fmt.Errorf("invalid similarity metric: %s", config.Metric)
+	}
+
+	// Stream each visitor's distinct products, most recently seen first
+	rows, err := db.Raw(`
+		SELECT visitor, product_id, MAX(weight) as weight, MAX(viewed_at) as last_viewed
+		FROM (`+visitorViewsSQL+`) v
+		GROUP BY visitor, product_id
+		ORDER BY visitor, last_viewed DESC, product_id
+	`, config.Weights.User, config.Weights.Guest, config.Weights.User, config.Weights.Wishlist).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// Accumulate per-product norms and per-pair overlaps one visitor at a time
+	norms := make(map[uint]float64)
+	pairs := make(map[productPair]*pairStats)
+	var current string
+	var items []visitorItem
+	for rows.Next() {
+		var visitor string
+		var productID uint
+		var weight float64
+		var lastViewed time.Time
+		if err := rows.Scan(&visitor, &productID, &weight, &lastViewed); err != nil {
+			return err
+		}
+		if visitor != current {
+			accumulatePairs(items, config.Metric, norms, pairs)
+			current, items = visitor, items[:0]
+		}
+		if weight <= 0 {
+			continue
+		}
+		// Long histories keep their most recent items
+		if config.MaxItemsPerVisitor > 0 && len(items) >= config.MaxItemsPerVisitor {
+			continue
+		}
+		items = append(items, visitorItem{productID, weight})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	accumulatePairs(items, config.Metric, norms, pairs)
+	rows.Close()
+
+	// Score pairs that meet the support threshold
+	now := time.Now()
+	neighbors := make(map[uint][]ProductSimilarity)
+	for key, stats := range pairs {
+		if stats.support < config.MinSupport {
+			continue
+		}
+
+		var score float64
+		if config.Metric == "cosine" {
+			score = stats.overlap / math.Sqrt(norms[key.a]*norms[key.b])
+		} else {
+			score = stats.overlap / (norms[key.a] + norms[key.b] - stats.overlap)
+		}
+
+		neighbors[key.a] = append(neighbors[key.a], ProductSimilarity{
+			ProductID: key.a, SimilarProductID: key.b, Score: score, Support: stats.support, ComputedAt: now,
+		})
+		neighbors[key.b] = append(neighbors[key.b], ProductSimilarity{
+			ProductID: key.b, SimilarProductID: key.a, Score: score, Support: stats.support, ComputedAt: now,
+		})
+	}
+
+	// Keep only the top K neighbors of each product
+	var similarities []ProductSimilarity
+	for _, list := range neighbors {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Score != list[j].Score {
+				return list[i].Score > list[j].Score
+			}
+			if list[i].Support != list[j].Support {
+				return list[i].Support > list[j].Support
+			}
+			return list[i].SimilarProductID < list[j].SimilarProductID
+		})
+		if config.TopK > 0 && len(list) > config.TopK {
+			list = list[:config.TopK]
+		}
+		similarities = append(similarities, list...)
+	}
+
+	// Swap in the new index atomically
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&ProductSimilarity{}).Error; err != nil {
+			return err
+		}
+		if len(similarities) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&similarities, 500).Error
+	})
+}
+
+// GetSimilarProducts returns the top precomputed in-stock neighbors of a product
+func GetSimilarProducts(db *gorm.DB, productID uint, limit int) ([]ProductRecommendation, error) {
+	var recommendations []ProductRecommendation
+	err := db.Raw(`
+		SELECT
+			p.id, p.name, p.description, p.price, p.category, p.stock,
+			s.support as view_count,
+			s.score as relevance
+		FROM product_similarities s
+		JOIN products p ON p.id = s.similar_product_id
+		WHERE s.product_id = ?
+		AND p.stock > 0
+		ORDER BY s.score DESC, s.support DESC, p.id ASC
+		LIMIT ?
+	`, productID, limit).Scan(&recommendations).Error
+	return recommendations, err
+}
+
+// Helper function to add one visitor's items to the product norms and pair overlaps
+func accumulatePairs(items []visitorItem, metric string, norms map[uint]float64, pairs map[productPair]*pairStats) {
+	// Weights are per (visitor, product): a wishlisted product counts more than a viewed one
+	for _, item := range items {
+		if metric == "cosine" {
+			norms[item.productID] += item.weight * item.weight
+		} else {
+			norms[item.productID] += item.weight
+		}
+	}
+
+	for i := 0; i < len(items); i++ {
+		for j := i + 1; j < len(items); j++ {
+			key := productPair{items[i].productID, items[j].productID}
+			if key.a > key.b {
+				key.a, key.b = key.b, key.a
+			}
+			stats, ok := pairs[key]
+			if !ok {
+				stats = &pairStats{}
+				pairs[key] = stats
+			}
+			stats.support++
+			if metric == "cosine" {
+				stats.overlap += items[i].weight * items[j].weight
+			} else {
+				stats.overlap += math.Min(items[i].weight, items[j].weight)
+			}
+		}
+	}
+}
@@ -2,10 +2,36 @@ package models
 
 import (
 	"fmt"
+	"math"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// TrendingBucketSize is the granularity of bucketed view counts
+const TrendingBucketSize = 15 * time.Minute
+
+// TrendingWindow defines how far back trending looks and how fast views decay
+type TrendingWindow struct {
+	Duration time.Duration
+	HalfLife time.Duration
+}
+
+// Add valid trending windows constant
+var TrendingWindows = map[string]TrendingWindow{
+	"1h":  {Duration: time.Hour, HalfLife: 30 * time.Minute},
+	"24h": {Duration: 24 * time.Hour, HalfLife: 6 * time.Hour},
+	"7d":  {Duration: 7 * 24 * time.Hour, HalfLife: 48 * time.Hour},
+}
+
+// TrendingOptions filters windowed trending results
+type TrendingOptions struct {
+	Window   string // One of TrendingWindows, or "all" for the all-time counter
+	Category string
+	Limit    int
+	At       time.Time // End of the window, now when zero
+}
+
 // TrendingProduct represents a trending product with its view count
 type TrendingProduct struct {
 	ID          uint    `json:"id"`
@@ -15,6 +41,19 @@ type TrendingProduct struct {
 	Category    string  `json:"category"`
 	Stock       int     `json:"stock"`
 	ViewCount   int     `json:"-"` // Hide from JSON output but keep in struct
+	Score       float64 `json:"-"` // Time-decayed views within the window
+}
+
+// ProductViewBucket counts views of a product within one time bucket
+type ProductViewBucket struct {
+	ProductID   uint      `gorm:"primaryKey;column:product_id"`
+	BucketStart time.Time `gorm:"primaryKey;column:bucket_start;index"`
+	Views       int       `gorm:"not null;default:0"`
+}
+
+// TableName overrides the table name
+func (ProductViewBucket) TableName() string {
+	return "product_view_buckets"
 }
 
 // TrendingProductDB is the database model for trending products
@@ -60,13 +99,108 @@ func UpdateTrendingViews(tx *gorm.DB, productID uint, title string) error {
 		return fmt.Errorf("product not found")
 	}
 
+	return incrementTrendingViews(tx, productID, title, 1, time.Now())
+}
+
+// incrementTrendingViews adds count views seen at viewedAt to a product in trending_products
+func incrementTrendingViews(tx *gorm.DB, productID uint, title string, count int, viewedAt time.Time) error {
 	result := tx.Exec(`
         INSERT INTO trending_products (product_id, title, total_views)
-        VALUES (?, ?, 1)
+        VALUES (?, ?, ?)
         ON DUPLICATE KEY UPDATE 
-        total_views = total_views + 1,
+        total_views = total_views + ?,
         title = ?
-    `, productID, title, title)
+    `, productID, title, count, count, title)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// Count the views in the bucket they happened in for windowed trending
+	bucketStart := viewedAt.Truncate(TrendingBucketSize)
+	return tx.Exec(`
+        INSERT INTO product_view_buckets (product_id, bucket_start, views)
+        VALUES (?, ?, ?)
+        ON DUPLICATE KEY UPDATE 
+        views = views + ?
+    `, productID, bucketStart, count, count).Error
+}
+
+// GetWindowedTrendingProducts ranks in-stock products by time-decayed views within a window
+func GetWindowedTrendingProducts(db *gorm.DB, opts TrendingOptions) ([]TrendingProduct, error) {
+	if opts.Window == "all" {
+		return getAllTimeTrendingProducts(db, opts.Category, opts.Limit)
+	}
+
+	window, ok := TrendingWindows[opts.Window]
+	if !ok {
+		return nil, fmt.Errorf("invalid trending window: %s", opts.Window)
+	}
+
+	now := opts.At
+	if now.IsZero() {
+		now = time.Now()
+	}
+	decayRate := math.Ln2 / window.HalfLife.Seconds()
+
+	var trending []TrendingProduct
+	tx := db.Raw(`
+        SELECT 
+            p.id,
+            p.name,
+            p.description,
+            p.price,
+            p.category,
+            p.stock,
+            COALESCE(b.views, 0) as view_count,
+            COALESCE(b.score, 0) as score
+        FROM products p
+        LEFT JOIN (
+            SELECT 
+                product_id,
+                SUM(views) as views,
+                SUM(views * EXP(-? * GREATEST(TIMESTAMPDIFF(SECOND, bucket_start, ?), 0))) as score
+            FROM product_view_buckets
+            WHERE bucket_start >= ?
+            GROUP BY product_id
+        ) b ON p.id = b.product_id
+        LEFT JOIN trending_products t ON p.id = t.product_id
+        WHERE p.stock > 0
+        AND (? = '' OR p.category = ?)
+        ORDER BY score DESC, COALESCE(t.total_views, 0) DESC, p.created_at DESC
+        LIMIT ?
+    `, decayRate, now, now.Add(-window.Duration).Truncate(TrendingBucketSize),
+		opts.Category, opts.Category, opts.Limit)
+
+	err := tx.Scan(&trending).Error
+	return trending, err
+}
+
+// Helper function to rank by the all-time counter with an optional category filter
+func getAllTimeTrendingProducts(db *gorm.DB, category string, limit int) ([]TrendingProduct, error) {
+	var trending []TrendingProduct
+
+	err := db.Raw(`
+        SELECT 
+            p.id,
+            p.name,
+            p.description,
+            p.price,
+            p.category,
+            p.stock,
+            COALESCE(t.total_views, 0) as view_count
+        FROM products p
+        LEFT JOIN trending_products t ON p.id = t.product_id
+        WHERE p.stock > 0
+        AND (? = '' OR p.category = ?)
+        ORDER BY COALESCE(t.total_views, 0) DESC, p.created_at DESC
+        LIMIT ?
+    `, category, category, limit).Scan(&trending).Error
+
+	return trending, err
+}
 
-	return result.Error
+// PruneViewBuckets deletes view buckets older than the retention period
+func PruneViewBuckets(db *gorm.DB, retention time.Duration) error {
+	return db.Where("bucket_start < ?", time.Now().Add(-retention)).
+		Delete(&ProductViewBucket{}).Error
 }
@@ -33,6 +33,22 @@ var ValidRoles = map[string]bool{
 	"admin": true,
 }
 
+// Add valid unverified account policy constant
+const (
+	UnverifiedPolicyAllow    = "allow"    // Unverified accounts can do everything
+	UnverifiedPolicyRestrict = "restrict" // Unverified accounts can sign in but not check out
+	UnverifiedPolicyBlock    = "block"    // Unverified accounts cannot sign in
+)
+
+var ValidUnverifiedPolicies = map[string]bool{
+	UnverifiedPolicyAllow:    true,
+	UnverifiedPolicyRestrict: true,
+	UnverifiedPolicyBlock:    true,
+}
+
+// UnverifiedAccountPolicy decides what accounts with an unconfirmed email address may do
+var UnverifiedAccountPolicy = UnverifiedPolicyAllow
+
 // Add validation function
 func isValidEmail(email string) bool {
 	return emailRegex.MatchString(email)
@@ -93,6 +109,8 @@ type User struct {
 	Password  string    `gorm:"not null" json:"password" binding:"required"`
 	Role      string    `gorm:"default:user" json:"role"`
 	CreatedAt time.Time `json:"created_at"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
 }
 
 // TableName overrides the table name
@@ -126,6 +144,9 @@ func CreateUser(db *gorm.DB, u *User) error {
 	}
 	u.Password = hashedPassword
 
+	// New accounts always start unverified; only the emailed link can verify them
+	u.EmailVerifiedAt = nil
+
 	result := db.Create(u)
 	return result.Error
 }
@@ -166,6 +187,11 @@ func UpdateUser(db *gorm.DB, u *User) error {
 		return fmt.Errorf("email '%s' already taken", u.Email)
 	}
 
+	var existing User
+	if err := db.Select("user_id", "email", "email_verified_at").First(&existing, u.UserID).Error; err != nil {
+		return fmt.Errorf("user not found")
+	}
+
 	// If password is being updated, hash it
 	if u.Password != "" {
 		if err := isValidPassword(u.Password); err != nil {
@@ -178,16 +204,73 @@ func UpdateUser(db *gorm.DB, u *User) error {
 		u.Password = hashedPassword
 	}
 
-	result := db.Save(u)
-	return result.Error
+	// Verification is only changed by the emailed link, or reset when the address changes
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("email_verified_at").Save(u).Error; err != nil {
+			return err
+		}
+		if u.Email == existing.Email {
+			u.EmailVerifiedAt = existing.EmailVerifiedAt
+			return nil
+		}
+		u.EmailVerifiedAt = nil
+		return tx.Model(&User{}).Where("user_id = ?", u.UserID).Update("email_verified_at", nil).Error
+	})
 }
 
+// DeleteUser deletes a user together with their sign-ins, tokens, history, wishlists and cart
 func DeleteUser(db *gorm.DB, id int) error {
-	result := db.Delete(&User{}, id)
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("user not found")
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Select("user_id").First(&user, id).Error; err != nil {
+			return fmt.Errorf("user not found")
+		}
+
+		owned := []interface{}{
+			&Session{},
+			&RefreshToken{},
+			&AccountToken{},
+			&UserInteraction{},
+			&GuestUserLink{},
+			&CartCoupon{},
+		}
+		for _, model := range owned {
+			if err := tx.Where("user_id = ?", user.UserID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		// Cart lines hold stock until their reservations are released
+		var cartItemIDs []uint
+		if err := tx.Model(&CartItem{}).Where("user_id = ?", user.UserID).Pluck("id", &cartItemIDs).Error; err != nil {
+			return err
+		}
+		if err := releaseCartItems(tx, cartItemIDs...); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.UserID).Delete(&CartItem{}).Error; err != nil {
+			return err
+		}
+
+		wishlistIDs := tx.Model(&Wishlist{}).Select("id").Where("user_id = ?", user.UserID)
+		if err := tx.Where("wishlist_id IN (?)", wishlistIDs).Delete(&WishlistItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.UserID).Delete(&Wishlist{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&user).Error
+	})
+}
+
+// IsEmailVerified reports whether a user has confirmed their email address
+func IsEmailVerified(db *gorm.DB, userID uint) bool {
+	var user User
+	if err := db.Select("user_id", "email_verified_at").First(&user, userID).Error; err != nil {
+		return false
 	}
-	return result.Error
+	return user.EmailVerifiedAt != nil
 }
 
 func IsAdmin(db *gorm.DB, userID uint) bool {
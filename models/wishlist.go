@@ -0,0 +1,449 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Wishlist kinds; each user has at most one save-for-later list, created on first use
+const (
+	WishlistKindWishlist     = "wishlist"
+	WishlistKindSaveForLater = "save_for_later"
+)
+
+// SaveForLaterName is the name of the save-for-later list
+const SaveForLaterName = "Saved for later"
+
+// Wishlist alert types sent to the WishlistNotifier
+const (
+	WishlistAlertPriceDrop   = "wishlist_price_drop"
+	WishlistAlertBackInStock = "wishlist_back_in_stock"
+)
+
+// Wishlist is a named list of products a user wants to keep an eye on
+type Wishlist struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	UserID    uint           `gorm:"not null;uniqueIndex:idx_wishlist_user_name" json:"-"`
+	Name      string         `gorm:"size:100;not null;uniqueIndex:idx_wishlist_user_name" json:"name"`
+	Kind      string         `gorm:"size:20;not null;default:wishlist" json:"kind"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Items     []WishlistItem `gorm:"foreignKey:WishlistID" json:"-"`
+}
+
+// WishlistItem is a product on a wishlist
+type WishlistItem struct {
+	ID         uint      `gorm:"primaryKey" json:"-"`
+	WishlistID uint      `gorm:"not null;uniqueIndex:idx_wishlist_product" json:"-"`
+	ProductID  uint      `gorm:"not null;uniqueIndex:idx_wishlist_product;index" json:"-"`
+	Quantity   int       `gorm:"not null;default:1" json:"-"`
+	CreatedAt  time.Time `json:"-"`
+	UpdatedAt  time.Time `json:"-"`
+
+	// Snapshot taken when the item was added
+	PriceAtAdd  float64 `gorm:"not null;default:0" json:"-"`
+	ProductName string  `gorm:"type:varchar(255)" json:"-"`
+
+	// Last price and stock state alerts were raised against
+	AlertPrice float64 `gorm:"not null;default:0" json:"-"`
+	InStock    bool    `gorm:"not null;default:false" json:"-"`
+
+	// No foreign key: items outlive a deleted product and show as unavailable
+	Product Product `gorm:"foreignKey:ProductID;constraint:-" json:"-"`
+}
+
+// TableName overrides the table name
+func (Wishlist) TableName() string {
+	return "wishlists"
+}
+
+// TableName overrides the table name
+func (WishlistItem) TableName() string {
+	return "wishlist_items"
+}
+
+// WishlistItemResponse is the JSON response structure for wishlist items
+type WishlistItemResponse struct {
+	ProductID  uint      `json:"product_id"`
+	Name       string    `json:"name"`
+	Price      float64   `json:"price"`
+	PriceAtAdd float64   `json:"price_at_add"`
+	Category   string    `json:"category"`
+	Quantity   int       `json:"quantity"`
+	InStock    bool      `json:"in_stock"`
+	Available  bool      `json:"available"` // False once the product is deleted
+	AddedAt    time.Time `json:"added_at"`
+}
+
+// WishlistResponse is a wishlist with its items
+type WishlistResponse struct {
+	ID        uint                   `json:"id"`
+	Name      string                 `json:"name"`
+	Kind      string                 `json:"kind"`
+	Items     []WishlistItemResponse `json:"items"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// WishlistAlert tells a user that a wishlisted product got cheaper or is back in stock
+type WishlistAlert struct {
+	Type        string  `json:"type"`
+	UserID      uint    `json:"user_id"`
+	Email       string  `json:"email"`
+	WishlistID  uint    `json:"wishlist_id"`
+	ProductID   uint    `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	OldPrice    float64 `json:"old_price,omitempty"`
+	NewPrice    float64 `json:"new_price"`
+	Stock       int     `json:"stock"`
+}
+
+// WishlistNotifier delivers wishlist alerts to the user
+type WishlistNotifier interface {
+	NotifyWishlistAlert(alert *WishlistAlert) error
+}
+
+// DefaultWishlistNotifier receives wishlist alerts; nil sends nothing
+var DefaultWishlistNotifier WishlistNotifier
+
+// CreateWishlist creates a named wishlist for a user
+func CreateWishlist(db *gorm.DB, userID uint, name string) (*Wishlist, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("wishlist name required")
+	}
+	if len(name) > 100 {
+		return nil, fmt.Errorf("wishlist name too long")
+	}
+	if strings.EqualFold(name, SaveForLaterName) {
+		return nil, fmt.Errorf("wishlist name is reserved")
+	}
+
+	var count int64
+	if err := db.Model(&Wishlist{}).Where("user_id = ? AND name = ?", userID, name).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("wishlist %s already exists", name)
+	}
+
+	wishlist := Wishlist{UserID: userID, Name: name, Kind: WishlistKindWishlist}
+	if err := db.Create(&wishlist).Error; err != nil {
+		return nil, err
+	}
+	return &wishlist, nil
+}
+
+// GetWishlists lists a user's wishlists with their items, save-for-later first
+func GetWishlists(db *gorm.DB, userID uint) ([]WishlistResponse, error) {
+	var wishlists []Wishlist
+	err := db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC, id DESC")
+	}).Preload("Items.Product").
+		Where("user_id = ?", userID).
+		Order("kind = 'save_for_later' DESC, created_at, id").
+		Find(&wishlists).Error
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]WishlistResponse, len(wishlists))
+	for i := range wishlists {
+		responses[i] = wishlistResponse(&wishlists[i])
+	}
+	return responses, nil
+}
+
+// GetWishlist returns one of the user's wishlists with its items
+func GetWishlist(db *gorm.DB, userID, wishlistID uint) (*WishlistResponse, error) {
+	var wishlist Wishlist
+	err := db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC, id DESC")
+	}).Preload("Items.Product").
+		Where("id = ? AND user_id = ?", wishlistID, userID).
+		First(&wishlist).Error
+	if err != nil {
+		return nil, fmt.Errorf("wishlist not found")
+	}
+
+	response := wishlistResponse(&wishlist)
+	return &response, nil
+}
+
+// DeleteWishlist deletes a user's wishlist and its items
+func DeleteWishlist(db *gorm.DB, userID, wishlistID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		wishlist, err := findWishlist(tx, userID, wishlistID)
+		if err != nil {
+			return err
+		}
+		if wishlist.Kind == WishlistKindSaveForLater {
+			return fmt.Errorf("save for later list cannot be deleted")
+		}
+		if err := tx.Where("wishlist_id = ?", wishlist.ID).Delete(&WishlistItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(wishlist).Error
+	})
+}
+
+// AddToWishlist adds a product to a user's wishlist, or refreshes it when already there
+func AddToWishlist(db *gorm.DB, userID, wishlistID, productID uint, quantity int) error {
+	if quantity < 1 {
+		quantity = 1
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		wishlist, err := findWishlist(tx, userID, wishlistID)
+		if err != nil {
+			return err
+		}
+		return putWishlistItem(tx, wishlist.ID, productID, quantity)
+	})
+}
+
+// RemoveFromWishlist removes a product from a user's wishlist
+func RemoveFromWishlist(db *gorm.DB, userID, wishlistID, productID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		wishlist, err := findWishlist(tx, userID, wishlistID)
+		if err != nil {
+			return err
+		}
+		result := tx.Where("wishlist_id = ? AND product_id = ?", wishlist.ID, productID).Delete(&WishlistItem{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("item not found in wishlist")
+		}
+		return nil
+	})
+}
+
+// MoveWishlistItemToCart moves a product from a wishlist into the user's cart.
+// A quantity of 0 moves the quantity kept on the wishlist; it adds to any quantity already in the cart.
+func MoveWishlistItemToCart(db *gorm.DB, userID, wishlistID, productID uint, quantity int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		wishlist, err := findWishlist(tx, userID, wishlistID)
+		if err != nil {
+			return err
+		}
+
+		var item WishlistItem
+		if err := tx.Where("wishlist_id = ? AND product_id = ?", wishlist.ID, productID).First(&item).Error; err != nil {
+			return fmt.Errorf("item not found in wishlist")
+		}
+		if quantity <= 0 {
+			quantity = item.Quantity
+		}
+
+		owner := CartOwner{UserID: userID}
+		var existing CartItem
+		if err := owner.scope(tx).Where("product_id = ?", productID).First(&existing).Error; err == nil {
+			quantity += existing.Quantity
+		}
+
+		if err := AddToCartFor(tx, owner, productID, quantity); err != nil {
+			return err
+		}
+		return tx.Delete(&item).Error
+	})
+}
+
+// SaveForLater moves a cart item to the user's save-for-later list and releases its reservation
+func SaveForLater(db *gorm.DB, userID, cartItemID uint) (*Wishlist, error) {
+	owner := CartOwner{UserID: userID}
+
+	var list *Wishlist
+	err := db.Transaction(func(tx *gorm.DB) error {
+		item, err := GetCartItem(tx, owner, cartItemID)
+		if err != nil {
+			return err
+		}
+
+		list, err = getSaveForLaterList(tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := putWishlistItem(tx, list.ID, item.ProductID, item.Quantity); err != nil {
+			return err
+		}
+		return RemoveFromCartFor(tx, owner, item.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CheckWishlistAlerts compares wishlisted products with the catalog and sends price drop
+// and back in stock alerts. Items whose alerts fail to send are retried on the next run.
+func CheckWishlistAlerts(db *gorm.DB, notifier WishlistNotifier) (int, error) {
+	var rows []struct {
+		ID          uint
+		WishlistID  uint
+		ProductID   uint
+		AlertPrice  float64
+		InStock     bool
+		UserID      uint
+		Email       string
+		ProductName string
+		Price       float64
+		Stock       int
+	}
+	err := db.Table("wishlist_items").
+		Select("wishlist_items.id, wishlist_items.wishlist_id, wishlist_items.product_id, wishlist_items.alert_price, wishlist_items.in_stock, wishlists.user_id, users.email, products.name AS product_name, products.price, products.stock").
+		Joins("JOIN wishlists ON wishlists.id = wishlist_items.wishlist_id").
+		Joins("JOIN users ON users.user_id = wishlists.user_id").
+		Joins("JOIN products ON products.id = wishlist_items.product_id").
+		Where("products.price <> wishlist_items.alert_price OR (products.stock > 0) <> wishlist_items.in_stock").
+		Order("wishlist_items.id").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+
+	// A product on several of a user's lists is only announced once per run
+	type alertKey struct {
+		userID, productID uint
+		alertType         string
+	}
+	announced := make(map[alertKey]bool)
+
+	sent := 0
+	for _, row := range rows {
+		var alerts []WishlistAlert
+		base := WishlistAlert{
+			UserID:      row.UserID,
+			Email:       row.Email,
+			WishlistID:  row.WishlistID,
+			ProductID:   row.ProductID,
+			ProductName: row.ProductName,
+			NewPrice:    row.Price,
+			Stock:       row.Stock,
+		}
+		if row.Price < row.AlertPrice && row.Stock > 0 {
+			alert := base
+			alert.Type = WishlistAlertPriceDrop
+			alert.OldPrice = row.AlertPrice
+			alerts = append(alerts, alert)
+		}
+		if !row.InStock && row.Stock > 0 {
+			alert := base
+			alert.Type = WishlistAlertBackInStock
+			alerts = append(alerts, alert)
+		}
+
+		delivered := true
+		for i := range alerts {
+			key := alertKey{row.UserID, row.ProductID, alerts[i].Type}
+			if announced[key] {
+				continue
+			}
+			if err := notifier.NotifyWishlistAlert(&alerts[i]); err != nil {
+				fmt.Printf("Failed to send %s alert for wishlist item %d: %v\n", alerts[i].Type, row.ID, err)
+				delivered = false
+				break
+			}
+			announced[key] = true
+			sent++
+		}
+		if !delivered {
+			continue
+		}
+
+		// Price rises and stock running out are recorded silently. A drop while sold out keeps
+		// the old price so it is announced once the product is back in stock.
+		alertPrice := row.Price
+		if row.Stock <= 0 && row.Price < row.AlertPrice {
+			alertPrice = row.AlertPrice
+		}
+		err := db.Model(&WishlistItem{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+			"alert_price": alertPrice,
+			"in_stock":    row.Stock > 0,
+		}).Error
+		if err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// Helper function to load one of the user's wishlists
+func findWishlist(db *gorm.DB, userID, wishlistID uint) (*Wishlist, error) {
+	var wishlist Wishlist
+	if err := db.Where("id = ? AND user_id = ?", wishlistID, userID).First(&wishlist).Error; err != nil {
+		return nil, fmt.Errorf("wishlist not found")
+	}
+	return &wishlist, nil
+}
+
+// Helper function to find or create the user's save-for-later list
+func getSaveForLaterList(db *gorm.DB, userID uint) (*Wishlist, error) {
+	list := Wishlist{UserID: userID, Name: SaveForLaterName, Kind: WishlistKindSaveForLater}
+	err := db.Where("user_id = ? AND kind = ?", userID, WishlistKindSaveForLater).
+		FirstOrCreate(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Helper function to add a product to a list, snapshotting its price and stock state
+func putWishlistItem(tx *gorm.DB, wishlistID, productID uint, quantity int) error {
+	var product Product
+	if err := tx.First(&product, productID).Error; err != nil {
+		return fmt.Errorf("product not found")
+	}
+
+	var item WishlistItem
+	result := tx.Where("wishlist_id = ? AND product_id = ?", wishlistID, productID).First(&item)
+	if result.Error == nil {
+		item.Quantity = quantity
+		item.ProductName = product.Name
+		return tx.Save(&item).Error
+	}
+
+	item = WishlistItem{
+		WishlistID:  wishlistID,
+		ProductID:   productID,
+		Quantity:    quantity,
+		PriceAtAdd:  product.Price,
+		ProductName: product.Name,
+		AlertPrice:  product.Price,
+		InStock:     product.Stock > 0,
+	}
+	return tx.Create(&item).Error
+}
+
+// Helper function to build the JSON response for a wishlist
+func wishlistResponse(wishlist *Wishlist) WishlistResponse {
+	response := WishlistResponse{
+		ID:        wishlist.ID,
+		Name:      wishlist.Name,
+		Kind:      wishlist.Kind,
+		Items:     make([]WishlistItemResponse, 0, len(wishlist.Items)),
+		CreatedAt: wishlist.CreatedAt,
+	}
+	for _, item := range wishlist.Items {
+		line := WishlistItemResponse{
+			ProductID:  item.ProductID,
+			Name:       item.Product.Name,
+			Price:      item.Product.Price,
+			PriceAtAdd: item.PriceAtAdd,
+			Category:   item.Product.Category,
+			Quantity:   item.Quantity,
+			InStock:    item.Product.Stock > 0,
+			Available:  item.Product.ID != 0,
+			AddedAt:    item.CreatedAt,
+		}
+		if !line.Available {
+			line.Name = item.ProductName
+			line.Price = item.PriceAtAdd
+		}
+		response.Items = append(response.Items, line)
+	}
+	return response
+}
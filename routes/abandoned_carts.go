@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/db"
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/gin-gonic/gin"
+)
+
+// getAbandonedCartReport handles GET /admin/reports/abandoned-carts
+func getAbandonedCartReport(c *gin.Context) {
+	until := time.Now()
+	if value := c.Query("until"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid until, expected RFC3339"})
+			return
+		}
+		until = parsed
+	}
+
+	// Defaults to the last 30 days
+	since := until.AddDate(0, 0, -30)
+	if value := c.Query("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since, expected RFC3339"})
+			return
+		}
+		since = parsed
+	}
+
+	if !since.Before(until) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since must be before until"})
+		return
+	}
+
+	report, err := models.GetAbandonedCartReport(db.DB, since, until)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build abandoned cart report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/amcishara/web_Tracking_system/db"
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/gin-gonic/gin"
+)
+
+// forgotPassword handles POST /password/forgot. It answers the same way whether or not
+// the email belongs to an account.
+func forgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := models.RequestPasswordReset(db.DB, input.Email); err != nil {
+		fmt.Printf("Failed to queue password reset email: %v\n", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If an account exists for that email, a reset link is on its way"})
+}
+
+// resetPassword handles POST /password/reset
+func resetPassword(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := models.ResetPassword(db.DB, input.Token, input.Password); err != nil {
+		respondAccountTokenError(c, err)
+		return
+	}
+
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset. Please sign in again"})
+}
+
+// verifyEmail handles GET /verify-email?token=
+func verifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	user, err := models.VerifyEmail(db.DB, token)
+	if err != nil {
+		respondAccountTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Email verified",
+		"email":             user.Email,
+		"email_verified_at": user.EmailVerifiedAt,
+	})
+}
+
+// resendVerificationEmail handles POST /verify-email/resend
+func resendVerificationEmail(c *gin.Context) {
+	if err := models.RequestEmailVerification(db.DB, c.GetUint("user_id")); err != nil {
+		if err.Error() == "email already verified" {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// Helper function to map account token errors to HTTP status codes
+func respondAccountTokenError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "invalid or expired token", "token already used":
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		if strings.HasPrefix(err.Error(), "password") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process token"})
+	}
+}
@@ -19,9 +19,39 @@ func getAnalytics(c *gin.Context) {
 	var interactions []models.UserInteraction
 	db.DB.Preload("User").Preload("Product").Find(&interactions)
 
+	eventCounts, err := models.GetEventCounts(db.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get event counts"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"total_interactions": len(interactions),
 		"interactions":       interactions,
+		"event_counts":       eventCounts,
+	})
+}
+
+func getIngestionStats(c *gin.Context) {
+	stats, running := models.GetIngestionStats()
+	c.JSON(http.StatusOK, gin.H{
+		"running": running,
+		"stats":   stats,
+	})
+}
+
+func rebuildSimilarities(c *gin.Context) {
+	if err := models.ComputeProductSimilarities(db.DB, models.SimilaritySettings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	db.DB.Model(&models.ProductSimilarity{}).Count(&count)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Similarity index rebuilt",
+		"similarities": count,
 	})
 }
 
@@ -47,37 +77,15 @@ func deleteUserAdmin(c *gin.Context) {
 		return
 	}
 
-	// Begin transaction
-	tx := db.DB.Begin()
-
-	// Delete user's sessions
-	if err := tx.Where("user_id = ?", id).Delete(&models.Session{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user sessions"})
-		return
-	}
-
-	// Delete user's interactions
-	if err := tx.Where("user_id = ?", id).Delete(&models.UserInteraction{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user interactions"})
-		return
-	}
-
-	// Delete the user
-	if err := tx.Delete(&models.User{}, id).Error; err != nil {
-		tx.Rollback()
+	if err := models.DeleteUser(db.DB, id); err != nil {
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User and all related data deleted successfully",
 	})
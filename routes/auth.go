@@ -2,9 +2,11 @@ package routes
 
 import (
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/amcishara/web_Tracking_system/db"
 	"github.com/amcishara/web_Tracking_system/models"
@@ -13,11 +15,15 @@ import (
 )
 
 func signup(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var input struct {
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	user := models.User{Email: input.Email, Password: input.Password}
 
 	// Debug print
 	fmt.Printf("Received signup request: %+v\n", user)
@@ -27,7 +33,19 @@ func signup(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "User created successfully"})
+	linkGuestHistory(c, user.UserID)
+	cartWarnings := mergeGuestCart(c, user.UserID)
+
+	// The account exists either way; the user can ask for another link
+	if err := models.RequestEmailVerification(db.DB, user.UserID); err != nil {
+		fmt.Printf("Failed to queue verification email for user %d: %v\n", user.UserID, err)
+	}
+
+	response := gin.H{"message": "User created successfully. Check your email to verify your address"}
+	if len(cartWarnings) > 0 {
+		response["cart_warnings"] = cartWarnings
+	}
+	c.JSON(http.StatusCreated, response)
 }
 
 func login(c *gin.Context) {
@@ -37,41 +55,105 @@ func login(c *gin.Context) {
 		return
 	}
 
+	// Refuse attempts while the email or IP is locked out or must wait after a failure
+	block, err := models.CheckLogin(db.DB, user.Email, c.ClientIP(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+		return
+	}
+	if block != nil {
+		respondLoginBlocked(c, block)
+		return
+	}
+
 	// Get user ID from ValidateUser
 	userID, err := models.ValidateUser(db.DB, &user)
 	if err != nil {
+		block, recordErr := models.RecordLoginFailure(db.DB, user.Email, c.ClientIP(), time.Now())
+		if recordErr != nil {
+			fmt.Printf("Failed to record login failure: %v\n", recordErr)
+		}
+		if block != nil && block.Locked {
+			respondLoginBlocked(c, block)
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
+	if err := models.RecordLoginSuccess(db.DB, user.Email); err != nil {
+		fmt.Printf("Failed to reset login failures: %v\n", err)
+	}
+
+	if models.UnverifiedAccountPolicy == models.UnverifiedPolicyBlock && !models.IsEmailVerified(db.DB, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email address before signing in"})
+		return
+	}
 
 	fmt.Printf("Login - User authenticated with ID: %d\n", userID)
 
-	// Generate token
-	token, err := utils.GenerateToken(userID, user.Email)
+	linkGuestHistory(c, userID)
+	cartWarnings := mergeGuestCart(c, userID)
+
+	// Issue a short-lived access token and a refresh token to renew it
+	pair, err := models.CreateTokenPair(db.DB, userID, user.Email, sessionInfo(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
 		return
 	}
+	setAuthCookies(c, pair)
 
-	// Create session
-	if err := models.CreateSession(db.DB, userID, token); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+	response := gin.H{
+		"message":            "Login successful",
+		"token":              pair.AccessToken,
+		"refresh_token":      pair.RefreshToken,
+		"expires_in":         pair.ExpiresIn,
+		"refresh_expires_in": pair.RefreshExpiresIn,
+	}
+	if len(cartWarnings) > 0 {
+		response["cart_warnings"] = cartWarnings
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// refreshToken handles POST /auth/refresh, rotating the refresh token from the body or cookie
+func refreshToken(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	// The body is optional; browsers send the refresh_token cookie instead
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+	if input.RefreshToken == "" {
+		input.RefreshToken, _ = c.Cookie("refresh_token")
+	}
+	if input.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token required"})
 		return
 	}
 
-	c.SetCookie(
-		"token",     // name
-		token,       // value
-		3600*24,     // max age in seconds (24 hours)
-		"/",         // path
-		"localhost", // domain
-		false,       // secure
-		true,        // httpOnly
-	)
+	pair, err := models.RefreshTokenPair(db.DB, input.RefreshToken, sessionInfo(c))
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), "refresh token"), err.Error() == "invalid refresh token":
+			clearAuthCookies(c)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		}
+		return
+	}
+	setAuthCookies(c, pair)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   token,
+		"token":              pair.AccessToken,
+		"refresh_token":      pair.RefreshToken,
+		"expires_in":         pair.ExpiresIn,
+		"refresh_expires_in": pair.RefreshExpiresIn,
 	})
 }
 
@@ -82,18 +164,35 @@ func updateUser(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var input struct {
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Role     string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	user.UserID = uint(id)
+	existing, err := models.GetUserByID(db.DB, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := models.User{UserID: uint(id), Email: input.Email, Password: input.Password, Role: input.Role}
 	if err := models.UpdateUser(db.DB, &user); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// A changed address has to be confirmed again
+	if user.Email != existing.Email {
+		if err := models.RequestEmailVerification(db.DB, user.UserID); err != nil {
+			fmt.Printf("Failed to queue verification email for user %d: %v\n", user.UserID, err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
 }
 
@@ -105,7 +204,11 @@ func deleteUser(c *gin.Context) {
 	}
 
 	if err := models.DeleteUser(db.DB, id); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
 
@@ -122,25 +225,109 @@ func logout(c *gin.Context) {
 		}
 	}
 
-	// Try to delete session if token exists
+	// End the session and revoke its refresh tokens
 	if token != "" {
-		if err := models.DeleteSession(db.DB, token); err != nil {
+		if err := models.EndSession(db.DB, token); err != nil {
 			fmt.Printf("Failed to delete session: %v\n", err) // Add logging
 		}
 	}
 
-	// Clear the cookie
-	c.SetCookie(
-		"token",     // name
-		"",          // value
-		-1,          // max age
-		"/",         // path
-		"localhost", // domain
-		false,       // secure
-		true,        // httpOnly
-	)
+	// Clients without an access token can still revoke their refresh token (body or cookie)
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if c.Request.ContentLength > 0 {
+		c.ShouldBindJSON(&input)
+	}
+	if input.RefreshToken == "" {
+		input.RefreshToken, _ = c.Cookie("refresh_token")
+	}
+	if input.RefreshToken != "" {
+		if err := models.RevokeRefreshToken(db.DB, input.RefreshToken); err != nil {
+			fmt.Printf("Failed to revoke refresh token: %v\n", err)
+		}
+	}
+
+	clearAuthCookies(c)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Logged out successfully",
 	})
 }
+
+// getJWKS handles GET /.well-known/jwks.json, publishing the public keys that verify our tokens
+func getJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.CurrentKeySet().JWKS(time.Now()))
+}
+
+// Helper function to turn away a login attempt with how long to wait. Unknown emails are
+// throttled the same way, so the answer does not reveal whether an account exists.
+func respondLoginBlocked(c *gin.Context, block *models.LoginBlock) {
+	seconds := int(math.Ceil(block.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+
+	message := "Too many failed login attempts, please wait before trying again"
+	if block.Locked {
+		message = "Too many failed login attempts, login is temporarily locked"
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       message,
+		"retry_after": seconds,
+	})
+}
+
+// Helper function to describe the client of a request for its session
+func sessionInfo(c *gin.Context) models.SessionInfo {
+	return models.SessionInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+// Helper function to set the access and refresh token cookies
+func setAuthCookies(c *gin.Context, pair *models.TokenPair) {
+	c.SetCookie(
+		"token",          // name
+		pair.AccessToken, // value
+		pair.ExpiresIn,   // max age in seconds
+		"/",              // path
+		"localhost",      // domain
+		false,            // secure
+		true,             // httpOnly
+	)
+	// The refresh token is only sent to /auth endpoints
+	c.SetCookie("refresh_token", pair.RefreshToken, pair.RefreshExpiresIn, "/auth", "localhost", false, true)
+}
+
+// Helper function to clear the access and refresh token cookies
+func clearAuthCookies(c *gin.Context) {
+	c.SetCookie("token", "", -1, "/", "localhost", false, true)
+	c.SetCookie("refresh_token", "", -1, "/auth", "localhost", false, true)
+}
+
+// Helper function to stitch the visitor's guest history onto their account
+func linkGuestHistory(c *gin.Context, userID uint) {
+	guestID, _ := c.Cookie("guest_id")
+	if guestID == "" {
+		return
+	}
+
+	if err := models.LinkGuestToUser(db.DB, guestID, userID); err != nil {
+		fmt.Printf("Failed to link guest %s to user %d: %v\n", guestID, userID, err)
+	}
+}
+
+// Helper function to move the guest cart into the user's cart after signup or login
+func mergeGuestCart(c *gin.Context, userID uint) []models.CartWarning {
+	guestID, _ := c.Cookie("guest_id")
+	if guestID == "" {
+		return nil
+	}
+
+	warnings, err := models.MergeGuestCart(db.DB, guestID, userID, models.CartMergeRule)
+	if err != nil {
+		fmt.Printf("Failed to merge cart of guest %s into user %d: %v\n", guestID, userID, err)
+	}
+	return warnings
+}
@@ -11,7 +11,7 @@ import (
 
 // addToCart handles POST /cart request
 func addToCart(c *gin.Context) {
-	userID := c.GetUint("user_id")
+	owner := getCartOwner(c)
 
 	var input struct {
 		ProductID uint `json:"product_id" binding:"required"`
@@ -23,52 +23,109 @@ func addToCart(c *gin.Context) {
 		return
 	}
 
-	if err := models.AddToCart(db.DB, userID, input.ProductID, input.Quantity); err != nil {
+	if err := models.AddToCartFor(db.DB, owner, input.ProductID, input.Quantity); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	emitEvent(c, models.EventAddToCart, &input.ProductID, models.EventProperties{
+		"quantity": input.Quantity,
+	})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Item added to cart"})
 }
 
 // removeFromCart handles DELETE /cart/:id request
 func removeFromCart(c *gin.Context) {
-	userID := c.GetUint("user_id")
+	owner := getCartOwner(c)
 	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
 		return
 	}
 
-	if err := models.RemoveFromCart(db.DB, userID, uint(itemID)); err != nil {
+	// Look up the item first so the removal event knows the product
+	cartItem, err := models.GetCartItem(db.DB, owner, uint(itemID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := models.RemoveFromCartFor(db.DB, owner, uint(itemID)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	emitEvent(c, models.EventRemoveFromCart, &cartItem.ProductID, models.EventProperties{
+		"quantity": cartItem.Quantity,
+	})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Item removed from cart"})
 }
 
 // getCart handles GET /cart request
 func getCart(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	if userID == 0 {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	summary, err := models.GetCartFor(db.DB, getCartOwner(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
 		return
 	}
 
-	summary, err := models.GetCart(db.DB, userID)
+	c.JSON(http.StatusOK, summary)
+}
+
+// revalidateCart handles POST /cart/revalidate
+func revalidateCart(c *gin.Context) {
+	var input struct {
+		ItemIDs []uint `json:"item_ids"`
+	}
+
+	// The body is optional; without item IDs every change is accepted
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+
+	summary, err := models.RevalidateCart(db.DB, getCartOwner(c), input.ItemIDs)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revalidate cart"})
 		return
 	}
 
 	c.JSON(http.StatusOK, summary)
 }
 
+// quoteCart handles GET /cart/quote
+func quoteCart(c *gin.Context) {
+	address := models.Address{
+		Country:    c.Query("country"),
+		Region:     c.Query("region"),
+		PostalCode: c.Query("postal_code"),
+	}
+
+	quote, err := models.QuoteCart(db.DB, getCartOwner(c), address)
+	if err != nil {
+		if err.Error() == "shipping not available to this address" {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to quote cart"})
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
+
 // updateQuantity handles PATCH /cart/:id/quantity
 func updateQuantity(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	itemID := c.Param("id")
+	owner := getCartOwner(c)
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
 
 	var input struct {
 		Increment int `json:"increment" binding:"required"`
@@ -79,9 +136,9 @@ func updateQuantity(c *gin.Context) {
 		return
 	}
 
-	// Update quantity - add user check
-	var cartItem models.CartItem
-	if err := db.DB.Where("id = ? AND user_id = ?", itemID, userID).First(&cartItem).Error; err != nil {
+	// Update quantity - add owner check
+	cartItem, err := models.GetCartItem(db.DB, owner, uint(itemID))
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
 		return
 	}
@@ -92,11 +149,37 @@ func updateQuantity(c *gin.Context) {
 		return
 	}
 
-	cartItem.Quantity = newQuantity
-	if err := db.DB.Save(&cartItem).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quantity"})
+	// Re-checks available stock and refreshes the item's reservation
+	if _, err := models.SetCartItemQuantityFor(db.DB, owner, cartItem.ID, newQuantity); err != nil {
+		switch err.Error() {
+		case "insufficient stock":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case "item not found in cart":
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quantity"})
+		}
 		return
 	}
 
+	// Record the change as an add or remove event
+	if input.Increment > 0 {
+		emitEvent(c, models.EventAddToCart, &cartItem.ProductID, models.EventProperties{
+			"quantity": input.Increment,
+		})
+	} else {
+		emitEvent(c, models.EventRemoveFromCart, &cartItem.ProductID, models.EventProperties{
+			"quantity": -input.Increment,
+		})
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Quantity updated"})
 }
+
+// Helper function to pick the cart of the signed-in user, or of the guest cookie otherwise
+func getCartOwner(c *gin.Context) models.CartOwner {
+	if userID := c.GetUint("user_id"); userID != 0 {
+		return models.CartOwner{UserID: userID}
+	}
+	return models.CartOwner{GuestID: getOrCreateGuestID(c)}
+}
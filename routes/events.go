@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/amcishara/web_Tracking_system/db"
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// EventRequest represents the request body for POST /events
+type EventRequest struct {
+	Type       models.EventType       `json:"type" binding:"required"`
+	Name       string                 `json:"name"`
+	ProductID  *uint                  `json:"product_id"`
+	Properties models.EventProperties `json:"properties"`
+}
+
+// trackEvent handles POST /events for both users and guests
+func trackEvent(c *gin.Context) {
+	var input EventRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if models.ServerEventTypes[input.Type] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "event type is recorded by the server"})
+		return
+	}
+
+	event := models.Event{
+		Type:       input.Type,
+		Name:       input.Name,
+		ProductID:  input.ProductID,
+		Properties: input.Properties,
+	}
+	setEventVisitor(c, &event)
+
+	if err := models.RecordEvent(db.DB, &event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Event recorded",
+		"event":   event,
+	})
+}
+
+// Helper function to get the guest ID cookie, creating one if missing
+func getOrCreateGuestID(c *gin.Context) string {
+	guestID, _ := c.Cookie("guest_id")
+	if guestID == "" {
+		guestID = uuid.New().String()
+		c.SetCookie("guest_id", guestID, 86400*30, "/", "localhost", false, true)
+	}
+	return guestID
+}
+
+// Helper function to attach the current user or guest to an event
+func setEventVisitor(c *gin.Context, event *models.Event) {
+	if userID := c.GetUint("user_id"); userID != 0 {
+		event.UserID = &userID
+		return
+	}
+	event.GuestID = getOrCreateGuestID(c)
+}
+
+// Helper function to record an event from a handler without failing the request
+func emitEvent(c *gin.Context, eventType models.EventType, productID *uint, props models.EventProperties) {
+	event := models.Event{
+		Type:       eventType,
+		ProductID:  productID,
+		Properties: props,
+	}
+	setEventVisitor(c, &event)
+
+	if err := models.RecordEvent(db.DB, &event); err != nil {
+		fmt.Printf("Failed to record %s event: %v\n", eventType, err)
+	}
+}
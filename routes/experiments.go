@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/amcishara/web_Tracking_system/db"
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/gin-gonic/gin"
+)
+
+// createExperiment handles POST /admin/experiments
+func createExperiment(c *gin.Context) {
+	var experiment models.Experiment
+	if err := c.ShouldBindJSON(&experiment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := models.GetPlacement(experiment.Placement); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown placement"})
+		return
+	}
+
+	if err := models.CreateExperiment(db.DB, &experiment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, experiment)
+}
+
+// getExperiments handles GET /admin/experiments
+func getExperiments(c *gin.Context) {
+	experiments, err := models.GetExperiments(db.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get experiments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"experiments": experiments})
+}
+
+// updateExperimentStatus handles PATCH /admin/experiments/:id/status
+func updateExperimentStatus(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var input struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	if err := models.SetExperimentStatus(db.DB, uint(id), input.Status); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Experiment status updated"})
+}
+
+// getExperimentReport handles GET /admin/experiments/:id/report
+func getExperimentReport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	report, err := models.GetExperimentReport(db.DB, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"experiment_id": id,
+		"variants":      report,
+	})
+}
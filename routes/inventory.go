@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/amcishara/web_Tracking_system/db"
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/gin-gonic/gin"
+)
+
+// postStockMovement handles POST /admin/products/:id/stock-movements
+func postStockMovement(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+
+	var input struct {
+		Type     string `json:"type" binding:"required"`
+		Quantity int    `json:"quantity" binding:"required"`
+		Reason   string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	actorID := c.GetUint("user_id")
+	movement, err := models.RecordStockMovement(db.DB, models.StockMovementInput{
+		ProductID: uint(id),
+		Type:      input.Type,
+		Quantity:  input.Quantity,
+		Reason:    input.Reason,
+		ActorID:   &actorID,
+	})
+	if err != nil {
+		switch err.Error() {
+		case "product not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "insufficient stock":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, movement)
+}
+
+// getStockHistory handles GET /admin/products/:id/stock-movements
+func getStockHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID format"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be between 1 and 500"})
+		return
+	}
+
+	var product models.Product
+	if err := db.DB.First(&product, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	movements, err := models.GetStockHistory(db.DB, uint(id), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stock history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"product_id": product.ID,
+		"stock":      product.Stock,
+		"movements":  movements,
+	})
+}
+
+// getStockReconciliation handles GET /admin/inventory/reconcile
+func getStockReconciliation(c *gin.Context) {
+	drifted, err := models.ReconcileStock(db.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile stock"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"drifted": drifted})
+}
+
+// fixStockReconciliation handles POST /admin/inventory/reconcile
+func fixStockReconciliation(c *gin.Context) {
+	actorTx := models.WithStockActor(db.DB, c.GetUint("user_id"))
+	corrected, err := models.FixStockDrift(actorTx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to correct stock drift"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Ledger reconciled",
+		"corrected": corrected,
+	})
+}
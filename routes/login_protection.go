@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/db"
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/gin-gonic/gin"
+)
+
+// getLoginLockouts handles GET /admin/login-lockouts
+func getLoginLockouts(c *gin.Context) {
+	lockouts, err := models.GetLoginLockouts(db.DB, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve lockouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"lockouts": lockouts})
+}
+
+// deleteLoginLockout handles DELETE /admin/login-lockouts/:id, unlocking an email or IP
+func deleteLoginLockout(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	if err := models.UnlockLogin(db.DB, uint(id), c.GetUint("user_id")); err != nil {
+		if err.Error() == "lockout not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lockout lifted"})
+}
+
+// unlockUserAdmin handles POST /admin/users/:id/unlock
+func unlockUserAdmin(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	if err := models.UnlockAccount(db.DB, uint(id), c.GetUint("user_id")); err != nil {
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+// getLoginAudit handles GET /admin/login-audit
+func getLoginAudit(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+
+	events, err := models.GetLoginAuditEvents(db.DB, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve login audit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
@@ -0,0 +1,171 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/amcishara/web_Tracking_system/db"
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/gin-gonic/gin"
+)
+
+// checkout handles POST /checkout
+func checkout(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		ShippingAddress models.Address `json:"shipping_address"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	// Tax and shipping are charged by address, so an order cannot be placed without one
+	if err := models.ValidateShippingAddress(input.ShippingAddress); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := models.CheckoutTo(db.DB, userID, input.ShippingAddress)
+	if err != nil {
+		switch {
+		case err.Error() == "cart is empty":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err.Error() == "shipping not available to this address":
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "insufficient stock"), strings.HasPrefix(err.Error(), "price changed"),
+			strings.HasPrefix(err.Error(), "coupon"), err.Error() == "product not found":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to place order"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Order placed",
+		"order":   order,
+	})
+}
+
+// getMyOrders handles GET /orders
+func getMyOrders(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	orders, err := models.GetUserOrders(db.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"orders": orders})
+}
+
+// getMyOrder handles GET /orders/:id
+func getMyOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	order, err := models.GetUserOrder(db.DB, userID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// cancelMyOrder handles POST /orders/:id/cancel
+func cancelMyOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	order, err := models.CancelOrder(db.DB, userID, uint(id))
+	if err != nil {
+		switch err.Error() {
+		case "order not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "only pending orders can be cancelled", "order has a payment in progress":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel order"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order cancelled",
+		"order":   order,
+	})
+}
+
+// getOrdersAdmin handles GET /admin/orders
+func getOrdersAdmin(c *gin.Context) {
+	filter := models.OrderFilter{Status: c.Query("status")}
+
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := strconv.Atoi(userID)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		filter.UserID = uint(id)
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be between 1 and 200"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+	filter.Limit = limit
+	filter.Offset = offset
+
+	orders, total, err := models.GetOrders(db.DB, filter)
+	if err != nil {
+		if err.Error() == "invalid order status" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders": orders,
+		"total":  total,
+	})
+}
+
+// getOrderAdmin handles GET /admin/orders/:id
+func getOrderAdmin(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	order, err := models.GetOrder(db.DB, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
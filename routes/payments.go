@@ -0,0 +1,178 @@
+package routes
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/amcishara/web_Tracking_system/db"
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/gin-gonic/gin"
+)
+
+// payForCart handles POST /checkout/pay
+func payForCart(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		PaymentToken    string         `json:"payment_token" binding:"required"`
+		ShippingAddress models.Address `json:"shipping_address"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if err := models.ValidateShippingAddress(input.ShippingAddress); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provider, ok := models.GetPaymentProvider(models.DefaultPaymentProvider)
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payments are not configured"})
+		return
+	}
+
+	order, payment, err := models.PayForCartTo(db.DB, provider, userID, input.PaymentToken, input.ShippingAddress)
+	if err != nil {
+		switch {
+		case payment != nil:
+			// The order was created, then cancelled because the payment did not go through
+			c.JSON(http.StatusPaymentRequired, gin.H{
+				"error":   err.Error(),
+				"order":   order,
+				"payment": payment,
+			})
+		case err.Error() == "cart is empty":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err.Error() == "shipping not available to this address":
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	respondPayment(c, order, payment)
+}
+
+// payMyOrder handles POST /orders/:id/pay for an order placed with POST /checkout
+func payMyOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var input struct {
+		PaymentToken string `json:"payment_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	provider, ok := models.GetPaymentProvider(models.DefaultPaymentProvider)
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payments are not configured"})
+		return
+	}
+
+	order, payment, err := models.PayOrder(db.DB, provider, userID, uint(id), input.PaymentToken)
+	if err != nil {
+		switch {
+		case payment != nil:
+			// The order was cancelled because the payment did not go through
+			c.JSON(http.StatusPaymentRequired, gin.H{
+				"error":   err.Error(),
+				"order":   order,
+				"payment": payment,
+			})
+		case err.Error() == "order not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	respondPayment(c, order, payment)
+}
+
+// paymentWebhook handles POST /webhooks/payments/:provider
+func paymentWebhook(c *gin.Context) {
+	provider, ok := models.GetPaymentProvider(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment provider"})
+		return
+	}
+
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	event, err := provider.VerifyWebhook(payload, c.GetHeader("X-Webhook-Signature"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	processed, err := models.ProcessPaymentWebhook(db.DB, provider.Name(), event)
+	if err != nil {
+		// Non-2xx makes the provider retry later
+		fmt.Printf("Failed to process %s webhook %s: %v\n", provider.Name(), event.ID, err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !processed {
+		c.JSON(http.StatusOK, gin.H{"status": "duplicate"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "processed"})
+}
+
+// refundPayment handles POST /admin/payments/:id/refund
+func refundPayment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	payment, err := models.RefundPayment(db.DB, uint(id))
+	if err != nil {
+		if err.Error() == "payment not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment refunded",
+		"payment": payment,
+	})
+}
+
+// Helper function to answer a successful payment, which may still be waiting for the provider
+func respondPayment(c *gin.Context, order *models.Order, payment *models.Payment) {
+	status := http.StatusCreated
+	message := "Order paid"
+	if payment.Status == models.PaymentPending {
+		status = http.StatusAccepted
+		message = "Payment is being processed"
+	}
+
+	c.JSON(status, gin.H{
+		"message": message,
+		"order":   order,
+		"payment": payment,
+	})
+}
@@ -25,7 +25,7 @@ func createProduct(c *gin.Context) {
 		return
 	}
 
-	if err := models.CreateProduct(db.DB, &product); err != nil {
+	if err := models.CreateProduct(models.WithStockActor(db.DB, c.GetUint("user_id")), &product); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -40,6 +40,7 @@ type BulkProductRequest []struct {
 	Price       float64 `json:"price"`
 	Category    string  `json:"category"`
 	Stock       int     `json:"stock"`
+	Weight      float64 `json:"weight"`
 }
 
 func createBulkProducts(c *gin.Context) {
@@ -72,6 +73,7 @@ func createBulkProducts(c *gin.Context) {
 			Price:       reqProduct.Price,
 			Category:    reqProduct.Category,
 			Stock:       reqProduct.Stock,
+			Weight:      reqProduct.Weight,
 		}
 
 		// Check if product name already exists
@@ -81,7 +83,7 @@ func createBulkProducts(c *gin.Context) {
 		}
 
 		// Create product
-		if err := models.CreateProduct(tx, &product); err != nil {
+		if err := models.CreateProduct(models.WithStockActor(tx, c.GetUint("user_id")), &product); err != nil {
 			failedProducts = append(failedProducts, fmt.Sprintf("Failed to create '%s': %v", product.Name, err))
 			continue
 		}
@@ -114,6 +116,9 @@ func createBulkProducts(c *gin.Context) {
 
 func getProducts(c *gin.Context) {
 	products := models.GetAllProducts(db.DB)
+	if err := models.FillAvailableToSell(db.DB, products); err != nil {
+		fmt.Printf("Failed to compute available stock: %v\n", err)
+	}
 	c.JSON(http.StatusOK, products)
 }
 
@@ -127,8 +132,7 @@ func getProductAsGuest(c *gin.Context) {
 	// If token exists, check if it's valid
 	if token != "" {
 		token = strings.TrimPrefix(token, "Bearer ")
-		var session models.Session
-		if err := db.DB.Where("token = ?", token).First(&session).Error; err == nil {
+		if _, err := models.GetSession(db.DB, token); err == nil {
 			// Valid session found - user is logged in
 			c.JSON(http.StatusForbidden, gin.H{
 				"error": "This route is only for guest users. Please use /products/:id for authenticated users",
@@ -151,34 +155,18 @@ func getProductAsGuest(c *gin.Context) {
 	}
 
 	// Get or create guest ID from cookie
-	guestID, _ := c.Cookie("guest_id")
-	if guestID == "" {
-		guestID = uuid.New().String()
-		c.SetCookie("guest_id", guestID, 86400*30, "/", "localhost", false, true)
-	}
+	guestID := getOrCreateGuestID(c)
 
 	// Track the view
 	if err := models.TrackGuestView(db.DB, guestID, uint(id)); err != nil {
 		fmt.Printf("Failed to track guest view: %v\n", err)
 	}
 
-	// Get collaborative recommendations
-	collaborative, err := models.GetCollaborativeRecommendations(db.DB, uint(id), 5)
-	if err != nil {
-		fmt.Printf("Failed to get collaborative recommendations: %v\n", err)
-	}
-
-	// Get category recommendations
-	category, err := models.GetCategoryRecommendations(db.DB, uint(id), 5)
-	if err != nil {
-		fmt.Printf("Failed to get category recommendations: %v\n", err)
-	}
-
-	// Get trending products
-	trending, err := models.GetTrendingProducts(db.DB, 5)
-	if err != nil {
-		fmt.Printf("Failed to get trending products: %v\n", err)
-	}
+	// Get recommendations
+	collaborative, category, trending := getProductPageRecommendations(models.RecommendationContext{
+		ProductID: uint(id),
+		GuestID:   guestID,
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"product":               product,
@@ -209,45 +197,58 @@ func getProductAsUser(c *gin.Context) {
 	}
 
 	// Get recommendations
-	collaborative, err := models.GetCollaborativeRecommendations(db.DB, uint(id), 5)
+	collaborative, category, trending := getProductPageRecommendations(models.RecommendationContext{
+		ProductID: uint(id),
+		UserID:    userID.(uint),
+	})
+
+	response := models.ProductWithRecommendations{
+		Product: struct {
+			ID              uint    `json:"id"`
+			Name            string  `json:"name"`
+			Description     string  `json:"description"`
+			Price           float64 `json:"price"`
+			Category        string  `json:"category"`
+			Stock           int     `json:"stock"`
+			AvailableToSell int     `json:"available_to_sell"`
+		}{
+			ID:              product.ID,
+			Name:            product.Name,
+			Description:     product.Description,
+			Price:           product.Price,
+			Category:        product.Category,
+			Stock:           product.Stock,
+			AvailableToSell: product.AvailableToSell,
+		},
+		CustomersAlsoViewed:  collaborative,
+		OtherRecommendations: category,
+		TrendingProducts:     trending,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Helper function to build the recommendation sections shown on a product page
+func getProductPageRecommendations(ctx models.RecommendationContext) ([]models.ScoredItem, []models.ScoredItem, []models.TrendingProduct) {
+	// Get collaborative recommendations
+	collaborative, err := models.ServeRecommendations(db.DB, models.PlacementCustomersAlsoViewed, ctx)
 	if err != nil {
 		fmt.Printf("Failed to get collaborative recommendations: %v\n", err)
 	}
 
 	// Get category recommendations
-	category, err := models.GetCategoryRecommendations(db.DB, uint(id), 5)
+	category, err := models.ServeRecommendations(db.DB, models.PlacementOtherRecommendations, ctx)
 	if err != nil {
 		fmt.Printf("Failed to get category recommendations: %v\n", err)
 	}
 
 	// Get trending products
-	trending, err := models.GetTrendingProducts(db.DB, 5)
+	trending, err := models.GetWindowedTrendingProducts(db.DB, models.TrendingOptions{Window: "24h", Limit: 5})
 	if err != nil {
 		fmt.Printf("Failed to get trending products: %v\n", err)
 	}
 
-	response := models.ProductWithRecommendations{
-		Product: struct {
-			ID          uint    `json:"id"`
-			Name        string  `json:"name"`
-			Description string  `json:"description"`
-			Price       float64 `json:"price"`
-			Category    string  `json:"category"`
-			Stock       int     `json:"stock"`
-		}{
-			ID:          product.ID,
-			Name:        product.Name,
-			Description: product.Description,
-			Price:       product.Price,
-			Category:    product.Category,
-			Stock:       product.Stock,
-		},
-		CustomersAlsoViewed:  collaborative,
-		OtherRecommendations: category,
-		TrendingProducts:     trending,
-	}
-
-	c.JSON(http.StatusOK, response)
+	return collaborative, category, trending
 }
 
 // Helper function to generate guest ID
@@ -285,7 +286,7 @@ func updateProduct(c *gin.Context) {
 	}
 
 	product.ID = uint(id)
-	if err := models.UpdateProduct(db.DB, &product); err != nil {
+	if err := models.UpdateProduct(models.WithStockActor(db.DB, c.GetUint("user_id")), &product); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
@@ -336,6 +337,16 @@ func searchProducts(c *gin.Context) {
 		return
 	}
 
+	if err := models.FillAvailableToSell(db.DB, products); err != nil {
+		fmt.Printf("Failed to compute available stock: %v\n", err)
+	}
+
+	emitEvent(c, models.EventSearch, nil, models.EventProperties{
+		"query":    query,
+		"category": category,
+		"results":  len(products),
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"products": products,
 		"filters": gin.H{
@@ -362,7 +373,27 @@ func getUserViewHistory(c *gin.Context) {
 }
 
 func getTrendingProducts(c *gin.Context) {
-	trending, err := models.GetTrendingProducts(db.DB, 5) // Get top 5 trending products
+	window := c.DefaultQuery("window", "24h") // Trending window (1h/24h/7d/all)
+	category := c.Query("category")           // Category filter
+
+	if _, ok := models.TrendingWindows[window]; !ok && window != "all" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid window. Use '1h', '24h', '7d', or 'all'",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if err != nil || limit < 1 || limit > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit. Use a number between 1 and 50"})
+		return
+	}
+
+	trending, err := models.GetWindowedTrendingProducts(db.DB, models.TrendingOptions{
+		Window:   window,
+		Category: category,
+		Limit:    limit,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get trending products"})
 		return
@@ -370,6 +401,11 @@ func getTrendingProducts(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"trending_products": trending,
+		"filters": gin.H{
+			"window":   window,
+			"category": category,
+			"limit":    limit,
+		},
 	})
 }
 
@@ -395,27 +431,43 @@ func adminDeleteProduct(c *gin.Context) {
 		return
 	}
 
-	// 2. Delete from user_interactions
+	// 2. Delete bucketed view counts
+	if err := tx.Exec("DELETE FROM product_view_buckets WHERE product_id = ?", id).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete trending data"})
+		return
+	}
+
+	// 3. Delete precomputed similarities
+	if err := tx.Exec("DELETE FROM product_similarities WHERE product_id = ? OR similar_product_id = ?", id, id).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete similarity data"})
+		return
+	}
+
+	// 4. Delete from user_interactions
 	if err := tx.Exec("DELETE FROM user_interactions WHERE product_id = ?", id).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user interactions"})
 		return
 	}
 
-	// 3. Delete from guest_interactions
+	// 5. Delete from guest_interactions
 	if err := tx.Exec("DELETE FROM guest_interactions WHERE product_id = ?", id).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete guest interactions"})
 		return
 	}
 
-	// 4. Delete from cart_items if exists
-	if err := tx.Exec("DELETE FROM cart_items WHERE product_id = ?", id).Error; err != nil {
+	// 6. Delete stock reservations
+	if err := tx.Exec("DELETE FROM stock_reservations WHERE product_id = ?", id).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete cart items"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete stock reservations"})
 		return
 	}
 
+	// Cart items are kept so customers see a product_deleted warning in their cart
+
 	// Finally delete the product
 	if err := tx.Delete(&models.Product{}, id).Error; err != nil {
 		tx.Rollback()
@@ -437,11 +489,12 @@ func adminDeleteProduct(c *gin.Context) {
 
 // AdminProductUpdate represents the request body for updating a product
 type AdminProductUpdate struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Category    string  `json:"category"`
-	Stock       int     `json:"stock"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       float64  `json:"price"`
+	Category    string   `json:"category"`
+	Stock       int      `json:"stock"`
+	Weight      *float64 `json:"weight" binding:"omitempty,min=0"` // Omitted keeps the stored weight
 }
 
 func adminUpdateProduct(c *gin.Context) {
@@ -493,7 +546,9 @@ func adminUpdateProduct(c *gin.Context) {
 		"description": update.Description,
 		"price":       update.Price,
 		"category":    update.Category,
-		"stock":       update.Stock,
+	}
+	if update.Weight != nil {
+		updates["weight"] = *update.Weight
 	}
 
 	if err := tx.Model(&existingProduct).Updates(updates).Error; err != nil {
@@ -502,6 +557,14 @@ func adminUpdateProduct(c *gin.Context) {
 		return
 	}
 
+	// Stock changes go through the inventory ledger
+	actorTx := models.WithStockActor(tx, c.GetUint("user_id"))
+	if err := models.SetStockLevel(actorTx, uint(id), update.Stock, "admin product update"); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Update trending products table if name changed
 	if update.Name != existingProduct.Name {
 		if err := tx.Exec("UPDATE trending_products SET title = ? WHERE product_id = ?", update.Name, id).Error; err != nil {
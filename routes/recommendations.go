@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/amcishara/web_Tracking_system/db"
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/gin-gonic/gin"
+)
+
+// getHomepageRecommendations handles GET /recommendations/homepage
+func getHomepageRecommendations(c *gin.Context) {
+	ctx := models.RecommendationContext{}
+	if userID := c.GetUint("user_id"); userID != 0 {
+		ctx.UserID = userID
+	} else {
+		ctx.GuestID, _ = c.Cookie("guest_id")
+	}
+
+	items, err := models.ServeRecommendations(db.DB, models.PlacementHomepage, ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recommendations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recommendations": items,
+	})
+}
+
+// getForYouRecommendations handles GET /recommendations/for-you
+func getForYouRecommendations(c *gin.Context) {
+	ctx := models.RecommendationContext{}
+	if userID := c.GetUint("user_id"); userID != 0 {
+		ctx.UserID = userID
+	} else {
+		ctx.GuestID, _ = c.Cookie("guest_id")
+	}
+
+	items, coldStart, err := models.GetForYouRecommendations(db.DB, ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recommendations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recommendations": items,
+		"cold_start":      coldStart,
+	})
+}
@@ -14,13 +14,23 @@ func SetupRouter(router *gin.Engine) {
 	router.POST("/signup", signup)
 	router.POST("/login", login)
 	router.POST("/logout", logout)
+	router.POST("/auth/logout", logout) // Under /auth so the refresh token cookie is sent
+	router.POST("/auth/refresh", refreshToken)
+	router.GET("/.well-known/jwks.json", getJWKS)
+	router.POST("/password/forgot", forgotPassword)
+	router.POST("/password/reset", resetPassword)
+	router.GET("/verify-email", verifyEmail)
 
 	// Guest product routes
 	router.GET("/products", getProducts)
-	router.GET("/products/search", searchProducts)
+	router.GET("/products/search", middleware.OptionalAuth(), searchProducts)
 	router.GET("/guest/products/:id", getProductAsGuest) // Guest product view
 	router.GET("/guest/view-history", getGuestViewHistory)
 	router.GET("/trending", getTrendingProducts)
+	router.POST("/events", middleware.OptionalAuth(), trackEvent)
+	router.POST("/webhooks/payments/:provider", paymentWebhook)
+	router.GET("/recommendations/homepage", middleware.OptionalAuth(), getHomepageRecommendations)
+	router.GET("/recommendations/for-you", middleware.OptionalAuth(), getForYouRecommendations)
 
 	// Protected routes
 	protected := router.Group("/")
@@ -29,18 +39,47 @@ func SetupRouter(router *gin.Engine) {
 		protected.PUT("/user/:id", updateUser)
 		protected.DELETE("/user/:id", deleteUser)
 		protected.GET("/my/view-history", getUserViewHistory)
+		protected.POST("/verify-email/resend", resendVerificationEmail)
+		protected.GET("/my/sessions", getMySessions)
+		protected.DELETE("/my/sessions", deleteMySessions)
+		protected.DELETE("/my/sessions/:id", deleteMySession)
 		protected.GET("/products/:id", getProductAsUser) // Authenticated user product view
+		protected.GET("/wishlists", getWishlists)
+		protected.POST("/wishlists", createWishlist)
+		protected.GET("/wishlists/:id", getWishlist)
+		protected.DELETE("/wishlists/:id", deleteWishlist)
+		protected.POST("/wishlists/:id/items", addToWishlist)
+		protected.DELETE("/wishlists/:id/items/:product_id", removeFromWishlist)
+		protected.POST("/wishlists/:id/items/:product_id/move-to-cart", moveWishlistItemToCart)
 	}
 
-	// Customer routes (with cart functionality)
+	// Cart routes, open to guests (keyed by guest_id cookie) and customers
+	cart := router.Group("/cart")
+	cart.Use(middleware.OptionalAuth())
+	cart.Use(middleware.CartMiddleware())
+	{
+		cart.POST("", addToCart)
+		cart.DELETE("/:id", removeFromCart)
+		cart.GET("", getCart)
+		cart.PATCH("/:id/quantity", updateQuantity)
+		cart.POST("/:id/save-for-later", saveForLater)
+		cart.POST("/revalidate", revalidateCart)
+		cart.GET("/quote", quoteCart)
+		cart.POST("/coupon", applyCoupon)
+		cart.DELETE("/coupon", removeCoupon)
+	}
+
+	// Customer routes (checkout and orders)
 	customer := router.Group("/")
 	customer.Use(middleware.AuthRequired())
 	customer.Use(middleware.CustomerMiddleware())
 	{
-		customer.POST("/cart", addToCart)
-		customer.DELETE("/cart/:id", removeFromCart)
-		customer.GET("/cart", getCart)
-		customer.PATCH("/cart/:id/quantity", updateQuantity)
+		customer.POST("/checkout", middleware.VerifiedEmailRequired(), checkout)
+		customer.POST("/checkout/pay", middleware.VerifiedEmailRequired(), payForCart)
+		customer.GET("/orders", getMyOrders)
+		customer.GET("/orders/:id", getMyOrder)
+		customer.POST("/orders/:id/pay", middleware.VerifiedEmailRequired(), payMyOrder)
+		customer.POST("/orders/:id/cancel", cancelMyOrder)
 	}
 
 	// Admin routes
@@ -51,13 +90,39 @@ func SetupRouter(router *gin.Engine) {
 		admin.GET("/users", getUsers)
 		admin.PUT("/users/:id", manageUser)
 		admin.GET("/analytics", getAnalytics)
+		admin.GET("/ingestion", getIngestionStats)
+		admin.POST("/similarities/rebuild", rebuildSimilarities)
+		admin.POST("/experiments", createExperiment)
+		admin.GET("/experiments", getExperiments)
+		admin.PATCH("/experiments/:id/status", updateExperimentStatus)
+		admin.GET("/experiments/:id/report", getExperimentReport)
+		admin.GET("/orders", getOrdersAdmin)
+		admin.GET("/orders/:id", getOrderAdmin)
+		admin.POST("/payments/:id/refund", refundPayment)
+		admin.POST("/promotions", createPromotion)
+		admin.GET("/promotions", getPromotions)
+		admin.GET("/promotions/:id", getPromotion)
+		admin.PUT("/promotions/:id", updatePromotion)
+		admin.PATCH("/promotions/:id/status", updatePromotionStatus)
+		admin.GET("/reports/abandoned-carts", getAbandonedCartReport)
 		admin.POST("/products", createProduct)
 		admin.POST("/products/bulk", createBulkProducts)
 		admin.PUT("/products/:id", updateProduct)
 		admin.PUT("/update-products/:id", adminUpdateProduct)
 		admin.DELETE("/products/:id", deleteProduct)
+		admin.POST("/products/:id/stock-movements", postStockMovement)
+		admin.GET("/products/:id/stock-movements", getStockHistory)
+		admin.GET("/inventory/reconcile", getStockReconciliation)
+		admin.POST("/inventory/reconcile", fixStockReconciliation)
 		admin.DELETE("/delete-products/:id", adminDeleteProduct)
 		admin.DELETE("/users/:id", deleteUserAdmin)
+		admin.GET("/users/:id/sessions", getUserSessionsAdmin)
+		admin.DELETE("/users/:id/sessions", deleteUserSessionsAdmin)
+		admin.DELETE("/users/:id/sessions/:session_id", deleteUserSessionAdmin)
+		admin.POST("/users/:id/unlock", unlockUserAdmin)
+		admin.GET("/login-lockouts", getLoginLockouts)
+		admin.DELETE("/login-lockouts/:id", deleteLoginLockout)
+		admin.GET("/login-audit", getLoginAudit)
 	}
 }
 
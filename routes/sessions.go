@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/amcishara/web_Tracking_system/db"
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/gin-gonic/gin"
+)
+
+// getMySessions handles GET /my/sessions
+func getMySessions(c *gin.Context) {
+	sessions, err := models.GetUserSessions(db.DB, c.GetUint("user_id"), c.GetUint("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// deleteMySession handles DELETE /my/sessions/:id
+func deleteMySession(c *gin.Context) {
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if err := models.RevokeSession(db.DB, c.GetUint("user_id"), uint(sessionID)); err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	if uint(sessionID) == c.GetUint("session_id") {
+		clearAuthCookies(c)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// deleteMySessions handles DELETE /my/sessions, signing out every other device.
+// With ?include_current=true the current session is signed out too.
+func deleteMySessions(c *gin.Context) {
+	keepID := c.GetUint("session_id")
+	if c.Query("include_current") == "true" {
+		keepID = 0
+	}
+
+	revoked, err := models.RevokeUserSessions(db.DB, c.GetUint("user_id"), keepID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	message := "Signed out of other sessions"
+	if keepID == 0 {
+		clearAuthCookies(c)
+		message = "Signed out of all sessions"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"revoked": revoked,
+	})
+}
+
+// getUserSessionsAdmin handles GET /admin/users/:id/sessions
+func getUserSessionsAdmin(c *gin.Context) {
+	userID, ok := parseSessionUserID(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := models.GetUserSessions(db.DB, userID, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// deleteUserSessionsAdmin handles DELETE /admin/users/:id/sessions, signing the user out everywhere
+func deleteUserSessionsAdmin(c *gin.Context) {
+	userID, ok := parseSessionUserID(c)
+	if !ok {
+		return
+	}
+
+	revoked, err := models.RevokeUserSessions(db.DB, userID, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User signed out of all sessions",
+		"revoked": revoked,
+	})
+}
+
+// deleteUserSessionAdmin handles DELETE /admin/users/:id/sessions/:session_id
+func deleteUserSessionAdmin(c *gin.Context) {
+	userID, ok := parseSessionUserID(c)
+	if !ok {
+		return
+	}
+	sessionID, err := strconv.ParseUint(c.Param("session_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if err := models.RevokeSession(db.DB, userID, uint(sessionID)); err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// Helper function to parse the user ID of an admin session route
+func parseSessionUserID(c *gin.Context) (uint, bool) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+// Helper function to map session errors to HTTP status codes
+func respondSessionError(c *gin.Context, err error) {
+	if err.Error() == "session not found" {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+}
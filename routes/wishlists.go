@@ -0,0 +1,222 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/amcishara/web_Tracking_system/db"
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/gin-gonic/gin"
+)
+
+// getWishlists handles GET /wishlists
+func getWishlists(c *gin.Context) {
+	wishlists, err := models.GetWishlists(db.DB, c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve wishlists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"wishlists": wishlists})
+}
+
+// createWishlist handles POST /wishlists
+func createWishlist(c *gin.Context) {
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	wishlist, err := models.CreateWishlist(db.DB, c.GetUint("user_id"), input.Name)
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "already exists") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, wishlist)
+}
+
+// getWishlist handles GET /wishlists/:id
+func getWishlist(c *gin.Context) {
+	wishlistID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wishlist ID"})
+		return
+	}
+
+	wishlist, err := models.GetWishlist(db.DB, c.GetUint("user_id"), uint(wishlistID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, wishlist)
+}
+
+// deleteWishlist handles DELETE /wishlists/:id
+func deleteWishlist(c *gin.Context) {
+	wishlistID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wishlist ID"})
+		return
+	}
+
+	if err := models.DeleteWishlist(db.DB, c.GetUint("user_id"), uint(wishlistID)); err != nil {
+		respondWishlistError(c, err, "Failed to delete wishlist")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Wishlist deleted"})
+}
+
+// addToWishlist handles POST /wishlists/:id/items
+func addToWishlist(c *gin.Context) {
+	wishlistID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wishlist ID"})
+		return
+	}
+
+	var input struct {
+		ProductID uint `json:"product_id" binding:"required"`
+		Quantity  int  `json:"quantity" binding:"omitempty,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	err = models.AddToWishlist(db.DB, c.GetUint("user_id"), uint(wishlistID), input.ProductID, input.Quantity)
+	if err != nil {
+		respondWishlistError(c, err, "Failed to add item to wishlist")
+		return
+	}
+
+	emitEvent(c, models.EventAddToWishlist, &input.ProductID, models.EventProperties{
+		"wishlist_id": wishlistID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Item added to wishlist"})
+}
+
+// removeFromWishlist handles DELETE /wishlists/:id/items/:product_id
+func removeFromWishlist(c *gin.Context) {
+	wishlistID, productID, ok := parseWishlistItemParams(c)
+	if !ok {
+		return
+	}
+
+	if err := models.RemoveFromWishlist(db.DB, c.GetUint("user_id"), wishlistID, productID); err != nil {
+		respondWishlistError(c, err, "Failed to remove item from wishlist")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Item removed from wishlist"})
+}
+
+// moveWishlistItemToCart handles POST /wishlists/:id/items/:product_id/move-to-cart
+func moveWishlistItemToCart(c *gin.Context) {
+	wishlistID, productID, ok := parseWishlistItemParams(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Quantity int `json:"quantity" binding:"omitempty,min=1"`
+	}
+
+	// The body is optional; without a quantity the wishlist's quantity is moved
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+
+	err := models.MoveWishlistItemToCart(db.DB, c.GetUint("user_id"), wishlistID, productID, input.Quantity)
+	if err != nil {
+		respondWishlistError(c, err, "Failed to move item to cart")
+		return
+	}
+
+	emitEvent(c, models.EventAddToCart, &productID, models.EventProperties{
+		"wishlist_id": wishlistID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Item moved to cart"})
+}
+
+// saveForLater handles POST /cart/:id/save-for-later
+func saveForLater(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Log in to save items for later"})
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	// Look up the item first so the removal event knows the product
+	cartItem, err := models.GetCartItem(db.DB, models.CartOwner{UserID: userID}, uint(itemID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	list, err := models.SaveForLater(db.DB, userID, uint(itemID))
+	if err != nil {
+		respondWishlistError(c, err, "Failed to save item for later")
+		return
+	}
+
+	emitEvent(c, models.EventRemoveFromCart, &cartItem.ProductID, models.EventProperties{
+		"quantity":    cartItem.Quantity,
+		"wishlist_id": list.ID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Item saved for later",
+		"wishlist_id": list.ID,
+	})
+}
+
+// Helper function to parse the wishlist and product IDs of an item route
+func parseWishlistItemParams(c *gin.Context) (uint, uint, bool) {
+	wishlistID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wishlist ID"})
+		return 0, 0, false
+	}
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return 0, 0, false
+	}
+	return uint(wishlistID), uint(productID), true
+}
+
+// Helper function to map wishlist errors to status codes
+func respondWishlistError(c *gin.Context, err error, fallback string) {
+	switch {
+	case strings.HasSuffix(err.Error(), "not found"), strings.HasPrefix(err.Error(), "item not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err.Error() == "insufficient stock":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err.Error() == "save for later list cannot be deleted":
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
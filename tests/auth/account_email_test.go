@@ -0,0 +1,281 @@
+package auth_test
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+// Helper function to pull the token out of the link in the latest outbox email to an address
+func tokenFromOutbox(t *testing.T, to string) string {
+	var message models.EmailMessage
+	if err := utils.TestDB.Where("to_address = ?", to).Order("id DESC").First(&message).Error; err != nil {
+		t.Fatalf("No email queued for %s: %v", to, err)
+	}
+	i := strings.Index(message.Body, "token=")
+	if i < 0 {
+		t.Fatalf("Email has no token link: %s", message.Body)
+	}
+	raw := message.Body[i+len("token="):]
+	raw = raw[:strings.IndexAny(raw, " \n")]
+	token, _ := url.QueryUnescape(raw)
+	return token
+}
+
+func resetAccountEmailTables() {
+	utils.TruncateTable("users")
+	utils.TruncateTable("sessions")
+	utils.TruncateTable("refresh_tokens")
+	utils.TruncateTable("account_tokens")
+	utils.TruncateTable("email_outbox")
+}
+
+func TestEmailVerification(t *testing.T) {
+	resetAccountEmailTables()
+	models.AccountEmailCooldown = 0
+
+	user := &models.User{
+		Email:    "verify@example.com",
+		Password: "Str0ng!Pass",
+	}
+	models.CreateUser(utils.TestDB, user)
+
+	t.Run("Verify Email", func(t *testing.T) {
+		err := models.RequestEmailVerification(utils.TestDB, user.UserID)
+		token := tokenFromOutbox(t, user.Email)
+
+		verified, verifyErr := models.VerifyEmail(utils.TestDB, token)
+		_, reuseErr := models.VerifyEmail(utils.TestDB, token)
+
+		passed := err == nil && verifyErr == nil && verified.EmailVerifiedAt != nil &&
+			models.IsEmailVerified(utils.TestDB, user.UserID) &&
+			reuseErr != nil && reuseErr.Error() == "token already used"
+		errMsg := ""
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to request verification: %v", err)
+		} else if !passed {
+			errMsg = "Expected the link to verify the email once"
+		}
+		utils.RecordTest(t, "Email Verification - Verify", passed, errMsg)
+	})
+
+	t.Run("Already Verified", func(t *testing.T) {
+		err := models.RequestEmailVerification(utils.TestDB, user.UserID)
+
+		passed := err != nil && err.Error() == "email already verified"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected no new link for a verified email"
+		}
+		utils.RecordTest(t, "Email Verification - Already Verified", passed, errMsg)
+	})
+
+	t.Run("Newer Link Replaces Older", func(t *testing.T) {
+		other := &models.User{Email: "verify2@example.com", Password: "Str0ng!Pass"}
+		models.CreateUser(utils.TestDB, other)
+
+		models.RequestEmailVerification(utils.TestDB, other.UserID)
+		first := tokenFromOutbox(t, other.Email)
+		models.RequestEmailVerification(utils.TestDB, other.UserID)
+		second := tokenFromOutbox(t, other.Email)
+
+		_, firstErr := models.VerifyEmail(utils.TestDB, first)
+		_, secondErr := models.VerifyEmail(utils.TestDB, second)
+		_, forgedErr := models.VerifyEmail(utils.TestDB, second+"x")
+
+		passed := first != second && firstErr != nil && secondErr == nil && forgedErr != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected only the latest, correctly signed link to work"
+		}
+		utils.RecordTest(t, "Email Verification - Newer Link Replaces Older", passed, errMsg)
+	})
+}
+
+func TestEmailVerifiedAtProtected(t *testing.T) {
+	resetAccountEmailTables()
+	models.AccountEmailCooldown = 0
+
+	t.Run("Signup Cannot Preverify", func(t *testing.T) {
+		verifiedAt := time.Now()
+		user := &models.User{Email: "preverified@example.com", Password: "Str0ng!Pass", EmailVerifiedAt: &verifiedAt}
+		err := models.CreateUser(utils.TestDB, user)
+
+		passed := err == nil && !models.IsEmailVerified(utils.TestDB, user.UserID)
+		errMsg := ""
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to create user: %v", err)
+		} else if !passed {
+			errMsg = "Expected a new account to start unverified"
+		}
+		utils.RecordTest(t, "Email Verification - Signup Cannot Preverify", passed, errMsg)
+	})
+
+	t.Run("Update Keeps Then Resets", func(t *testing.T) {
+		user := &models.User{Email: "profile@example.com", Password: "Str0ng!Pass"}
+		models.CreateUser(utils.TestDB, user)
+		models.RequestEmailVerification(utils.TestDB, user.UserID)
+		models.VerifyEmail(utils.TestDB, tokenFromOutbox(t, user.Email))
+
+		sameErr := models.UpdateUser(utils.TestDB, &models.User{UserID: user.UserID, Email: user.Email, Password: "N3w!Password"})
+		keptVerified := models.IsEmailVerified(utils.TestDB, user.UserID)
+
+		changedErr := models.UpdateUser(utils.TestDB, &models.User{UserID: user.UserID, Email: "profile2@example.com", Password: "N3w!Password"})
+		resetVerified := models.IsEmailVerified(utils.TestDB, user.UserID)
+
+		passed := sameErr == nil && keptVerified && changedErr == nil && !resetVerified
+		errMsg := ""
+		if sameErr != nil || changedErr != nil {
+			errMsg = fmt.Sprintf("Failed to update user: %v, %v", sameErr, changedErr)
+		} else if !passed {
+			errMsg = "Expected profile edits to keep verification until the email changes"
+		}
+		utils.RecordTest(t, "Email Verification - Update Keeps Then Resets", passed, errMsg)
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+	resetAccountEmailTables()
+	models.AccountEmailCooldown = 0
+
+	user := &models.User{
+		Email:    "reset@example.com",
+		Password: "Str0ng!Pass",
+	}
+	models.CreateUser(utils.TestDB, user)
+
+	t.Run("Unknown Email", func(t *testing.T) {
+		err := models.RequestPasswordReset(utils.TestDB, "nobody@example.com")
+
+		var count int64
+		utils.TestDB.Model(&models.EmailMessage{}).Count(&count)
+
+		passed := err == nil && count == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected unknown emails to be ignored silently"
+		}
+		utils.RecordTest(t, "Password Reset - Unknown Email", passed, errMsg)
+	})
+
+	t.Run("Reset Password", func(t *testing.T) {
+		pair, _ := models.CreateTokenPair(utils.TestDB, user.UserID, user.Email, models.SessionInfo{})
+		err := models.RequestPasswordReset(utils.TestDB, user.Email)
+		token := tokenFromOutbox(t, user.Email)
+
+		weakErr := models.ResetPassword(utils.TestDB, token, "weak")
+		resetErr := models.ResetPassword(utils.TestDB, token, "N3w!Password")
+		reuseErr := models.ResetPassword(utils.TestDB, token, "An0ther!Password")
+
+		_, loginErr := models.ValidateUser(utils.TestDB, &models.User{Email: user.Email, Password: "N3w!Password"})
+		_, sessionErr := models.GetSession(utils.TestDB, pair.AccessToken)
+
+		passed := err == nil && weakErr != nil && resetErr == nil && reuseErr != nil &&
+			loginErr == nil && sessionErr != nil && models.IsEmailVerified(utils.TestDB, user.UserID)
+		errMsg := ""
+		if resetErr != nil {
+			errMsg = fmt.Sprintf("Failed to reset password: %v", resetErr)
+		} else if !passed {
+			errMsg = "Expected the new password to work once and every session to end"
+		}
+		utils.RecordTest(t, "Password Reset - Reset", passed, errMsg)
+	})
+
+	t.Run("Cooldown", func(t *testing.T) {
+		utils.TruncateTable("email_outbox")
+		models.AccountEmailCooldown = time.Minute
+		defer func() { models.AccountEmailCooldown = 0 }()
+
+		models.RequestPasswordReset(utils.TestDB, user.Email)
+		models.RequestPasswordReset(utils.TestDB, user.Email)
+
+		var count int64
+		utils.TestDB.Model(&models.EmailMessage{}).Count(&count)
+
+		passed := count == 1
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected one reset email within the cooldown, got %d", count)
+		}
+		utils.RecordTest(t, "Password Reset - Cooldown", passed, errMsg)
+	})
+}
+
+func TestEmailOutbox(t *testing.T) {
+	resetAccountEmailTables()
+	maxAttempts := models.EmailMaxAttempts
+	models.EmailMaxAttempts = 2
+	defer func() { models.EmailMaxAttempts = maxAttempts }()
+
+	t.Run("Deliver", func(t *testing.T) {
+		mailer := &models.MemoryMailer{}
+		models.QueueEmail(utils.TestDB, "outbox@example.com", "Hello", "Body")
+
+		sent, err := models.DeliverEmails(utils.TestDB, mailer, 10)
+		again, _ := models.DeliverEmails(utils.TestDB, mailer, 10)
+
+		passed := err == nil && sent == 1 && again == 0 && len(mailer.Messages()) == 1 &&
+			mailer.Messages()[0].To == "outbox@example.com"
+		errMsg := ""
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to deliver emails: %v", err)
+		} else if !passed {
+			errMsg = "Expected the email to be sent exactly once"
+		}
+		utils.RecordTest(t, "Email Outbox - Deliver", passed, errMsg)
+	})
+
+	t.Run("Retry Then Fail", func(t *testing.T) {
+		mailer := &models.MemoryMailer{Fail: fmt.Errorf("smtp unavailable")}
+		message, _ := models.QueueEmail(utils.TestDB, "retry@example.com", "Hello", "Body")
+
+		models.DeliverEmails(utils.TestDB, mailer, 10)
+		var afterFirst models.EmailMessage
+		utils.TestDB.First(&afterFirst, message.ID)
+
+		// Make the retry due now
+		utils.TestDB.Model(&models.EmailMessage{}).Where("id = ?", message.ID).
+			Update("next_attempt_at", time.Now().Add(-time.Second))
+		models.DeliverEmails(utils.TestDB, mailer, 10)
+		var afterSecond models.EmailMessage
+		utils.TestDB.First(&afterSecond, message.ID)
+
+		passed := afterFirst.Status == models.EmailStatusPending && afterFirst.Attempts == 1 &&
+			afterFirst.NextAttemptAt.After(time.Now()) && afterFirst.LastError == "smtp unavailable" &&
+			afterSecond.Status == models.EmailStatusFailed
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected a delayed retry then failure, got %s after %d attempts", afterSecond.Status, afterSecond.Attempts)
+		}
+		utils.RecordTest(t, "Email Outbox - Retry", passed, errMsg)
+	})
+
+	t.Run("File Mailer", func(t *testing.T) {
+		dir := t.TempDir()
+		mailer, err := models.NewFileMailer(dir, "no-reply@example.com")
+		if err == nil {
+			err = mailer.Send(&models.EmailMessage{ID: 1, To: "file@example.com", Subject: "Hi", Body: "Line one\nLine two"})
+		}
+
+		files, _ := os.ReadDir(dir)
+		content := ""
+		if len(files) == 1 {
+			data, _ := os.ReadFile(dir + "/" + files[0].Name())
+			content = string(data)
+		}
+
+		passed := err == nil && strings.Contains(content, "To: file@example.com\r\n") &&
+			strings.Contains(content, "Subject: Hi\r\n") && strings.HasSuffix(content, "Line one\r\nLine two")
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected one .eml file with headers and body"
+		}
+		utils.RecordTest(t, "Email Outbox - File Mailer", passed, errMsg)
+	})
+}
@@ -0,0 +1,121 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+func TestLinkGuestToUser(t *testing.T) {
+	utils.TruncateTable("guest_user_links")
+	utils.TruncateTable("guest_interactions")
+	utils.TruncateTable("user_interactions")
+	utils.TruncateTable("products")
+	utils.TruncateTable("users")
+
+	user := &models.User{
+		Email:    "stitch@example.com",
+		Password: "SecureP@ss123",
+	}
+	models.CreateUser(utils.TestDB, user)
+
+	other := &models.User{
+		Email:    "other@example.com",
+		Password: "SecureP@ss123",
+	}
+	models.CreateUser(utils.TestDB, other)
+
+	product := &models.Product{
+		Name:        "Stitched Product",
+		Description: "Viewed as a guest",
+		Price:       29.99,
+		Category:    "Accessories",
+		Stock:       10,
+	}
+	utils.TestDB.Create(product)
+
+	models.TrackGuestView(utils.TestDB, "guest-stitch", product.ID)
+
+	t.Run("Guest History Moves To User", func(t *testing.T) {
+		err := models.LinkGuestToUser(utils.TestDB, "guest-stitch", user.UserID)
+		history, histErr := models.GetUserViewHistory(utils.TestDB, user.UserID)
+		guestHistory, _ := models.GetGuestViewHistory(utils.TestDB, "guest-stitch")
+
+		passed := err == nil && histErr == nil &&
+			len(history) == 1 && history[0].ID == product.ID &&
+			len(guestHistory) == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected guest view to appear in user history"
+		}
+		utils.RecordTest(t, "Guest Link - History Migrated", passed, errMsg)
+	})
+
+	t.Run("Link Is Recorded", func(t *testing.T) {
+		var links []models.GuestUserLink
+		err := utils.TestDB.Where("user_id = ?", user.UserID).Find(&links).Error
+		passed := err == nil && len(links) == 1 && links[0].GuestID == "guest-stitch"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected guest_user_links mapping for the user"
+		}
+		utils.RecordTest(t, "Guest Link - Mapping Stored", passed, errMsg)
+	})
+
+	t.Run("Relink To Other User", func(t *testing.T) {
+		err := models.LinkGuestToUser(utils.TestDB, "guest-stitch", other.UserID)
+		passed := err != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected error linking guest to a second user"
+		}
+		utils.RecordTest(t, "Guest Link - Reject Relink", passed, errMsg)
+	})
+
+	t.Run("Queued Views Follow The Link", func(t *testing.T) {
+		models.StartIngestion(utils.TestDB, models.IngestionConfig{
+			QueueSize:     10,
+			Workers:       1,
+			BatchSize:     10,
+			FlushInterval: time.Minute,
+		})
+
+		// The view is still queued when the guest signs in
+		models.TrackGuestView(utils.TestDB, "guest-queued", product.ID)
+		err := models.LinkGuestToUser(utils.TestDB, "guest-queued", other.UserID)
+		models.StopIngestion()
+
+		history, _ := models.GetUserViewHistory(utils.TestDB, other.UserID)
+		guestHistory, _ := models.GetGuestViewHistory(utils.TestDB, "guest-queued")
+
+		passed := err == nil && len(history) == 1 && history[0].ID == product.ID && len(guestHistory) == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the queued guest view to be written to the linked user's history"
+		}
+		utils.RecordTest(t, "Guest Link - Queued Views", passed, errMsg)
+	})
+
+	t.Run("Merge Keeps Latest View Time", func(t *testing.T) {
+		viewedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+		utils.TestDB.Exec("INSERT INTO user_interactions (user_id, product_id, viewed_at) VALUES (?, ?, ?)", user.UserID, product.ID, viewedAt)
+		utils.TestDB.Exec("INSERT INTO guest_interactions (guest_id, product_id, viewed_at) VALUES (?, ?, ?)", "guest-repeat", product.ID, viewedAt)
+
+		err := models.LinkGuestToUser(utils.TestDB, "guest-repeat", user.UserID)
+
+		var rows int64
+		utils.TestDB.Table("user_interactions").
+			Where("user_id = ? AND product_id = ? AND viewed_at = ?", user.UserID, product.ID, viewedAt).
+			Count(&rows)
+		guestHistory, _ := models.GetGuestViewHistory(utils.TestDB, "guest-repeat")
+
+		passed := err == nil && rows == 1 && len(guestHistory) == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected a clashing guest view to merge into the user's row"
+		}
+		utils.RecordTest(t, "Guest Link - Merge Clashing View", passed, errMsg)
+	})
+}
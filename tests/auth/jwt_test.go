@@ -0,0 +1,311 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/routes"
+	testutils "github.com/amcishara/web_Tracking_system/tests/utils"
+	"github.com/amcishara/web_Tracking_system/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWTSigningKeys(t *testing.T) {
+	original := utils.CurrentKeySet()
+	defer utils.SetKeySet(original)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name string
+		key  *utils.SigningKey
+	}{
+		{"HS256", utils.NewHMACKey("hmac", []byte("a-shared-secret-of-at-least-32-characters"))},
+		{"RS256", utils.NewRSAKey("rsa", rsaKey)},
+		{"EdDSA", utils.NewEd25519Key("ed", edKey)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := utils.NewKeySet([]*utils.SigningKey{tt.key})
+			if err == nil {
+				utils.SetKeySet(keys)
+			}
+
+			token, signErr := utils.GenerateToken(7, "jwt@example.com")
+			claims, validateErr := utils.ValidateToken(token)
+			parsed, _, _ := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+
+			passed := err == nil && signErr == nil && validateErr == nil &&
+				(*claims)["email"] == "jwt@example.com" && parsed.Header["kid"] == tt.key.ID &&
+				parsed.Method.Alg() == tt.key.Algorithm
+			errMsg := ""
+			if !passed {
+				errMsg = "Expected a token signed with the key's algorithm and kid to validate"
+			}
+			testutils.RecordTest(t, "JWT Keys - "+tt.name, passed, errMsg)
+		})
+	}
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	original := utils.CurrentKeySet()
+	defer utils.SetKeySet(original)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	now := time.Now()
+
+	oldKey := utils.NewRSAKey("old", rsaKey)
+	oldKey.ExpiresAt = now.Add(time.Hour)
+	newKey := utils.NewEd25519Key("new", edKey)
+	newKey.ActiveFrom = now.Add(30 * time.Minute)
+	secret := utils.NewHMACKey("secret", []byte("a-shared-secret-of-at-least-32-characters"))
+	secret.ActiveFrom = now.Add(-time.Hour)
+
+	keys, err := utils.NewKeySet([]*utils.SigningKey{oldKey, newKey, secret})
+	if err != nil {
+		t.Fatalf("Failed to build key set: %v", err)
+	}
+
+	t.Run("Scheduled Rotation", func(t *testing.T) {
+		current, _ := keys.SigningKey(now)
+		later, _ := keys.SigningKey(now.Add(45 * time.Minute))
+
+		passed := current != nil && current.ID == "secret" && later != nil && later.ID == "new"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the newest active key to sign"
+		}
+		testutils.RecordTest(t, "JWT Keys - Scheduled Rotation", passed, errMsg)
+	})
+
+	t.Run("Old Keys Validate Until Expiry", func(t *testing.T) {
+		signing, _ := utils.NewKeySet([]*utils.SigningKey{utils.NewRSAKey("old", rsaKey)})
+		utils.SetKeySet(signing)
+		token, _ := utils.GenerateToken(7, "jwt@example.com")
+
+		utils.SetKeySet(keys)
+		_, validErr := utils.ValidateToken(token)
+		_, expiredErr := keys.Key("old", now.Add(2*time.Hour))
+
+		passed := validErr == nil && expiredErr != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the old key to validate until it expires"
+		}
+		testutils.RecordTest(t, "JWT Keys - Old Keys Validate", passed, errMsg)
+	})
+
+	t.Run("Algorithm Must Match Key", func(t *testing.T) {
+		utils.SetKeySet(keys)
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id": 1,
+			"exp":     now.Add(time.Hour).Unix(),
+		})
+		forged.Header["kid"] = "old"
+		forgedToken, _ := forged.SignedString(x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))
+
+		unsigned := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 1})
+		noKidToken, _ := unsigned.SignedString([]byte("a-shared-secret-of-at-least-32-characters"))
+
+		_, forgedErr := utils.ValidateToken(forgedToken)
+		_, noKidErr := utils.ValidateToken(noKidToken)
+
+		passed := forgedErr != nil && noKidErr != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected tokens with a mismatched algorithm or no kid to be rejected"
+		}
+		testutils.RecordTest(t, "JWT Keys - Algorithm Confusion", passed, errMsg)
+	})
+
+	t.Run("JWKS", func(t *testing.T) {
+		jwks := keys.JWKS(now)
+		kinds := make(map[string]string)
+		for _, k := range jwks.Keys {
+			kinds[k.KeyID] = k.KeyType
+		}
+
+		passed := len(jwks.Keys) == 2 && kinds["old"] == "RSA" && kinds["new"] == "OKP"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected public RSA and Ed25519 keys, and no HS256 secret"
+		}
+		testutils.RecordTest(t, "JWT Keys - JWKS", passed, errMsg)
+	})
+}
+
+func TestJWTKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	os.WriteFile(filepath.Join(dir, "ed.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+
+	write := func(content string) string {
+		path := filepath.Join(dir, "keys.json")
+		os.WriteFile(path, []byte(content), 0600)
+		return path
+	}
+
+	t.Run("Load Keys", func(t *testing.T) {
+		keys, err := utils.LoadKeySetFile(write(`{"keys": [
+			{"kid": "ed", "alg": "EdDSA", "private_key_file": "ed.pem", "active_from": "2024-01-01T00:00:00Z"},
+			{"kid": "hmac", "alg": "HS256", "secret": "a-shared-secret-of-at-least-32-characters"}
+		]}`))
+
+		var signing *utils.SigningKey
+		if err == nil {
+			signing, _ = keys.SigningKey(time.Now())
+		}
+		passed := err == nil && signing != nil && signing.ID == "ed"
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		} else if !passed {
+			errMsg = "Expected the key file's newest key to sign"
+		}
+		testutils.RecordTest(t, "JWT Key File - Load", passed, errMsg)
+	})
+
+	t.Run("Reject Bad Keys", func(t *testing.T) {
+		_, shortErr := utils.LoadKeySetFile(write(`{"keys": [{"kid": "hmac", "alg": "HS256", "secret": "short"}]}`))
+		_, mismatchErr := utils.LoadKeySetFile(write(`{"keys": [{"kid": "ed", "alg": "RS256", "private_key_file": "ed.pem"}]}`))
+		_, duplicateErr := utils.LoadKeySetFile(write(`{"keys": [
+			{"kid": "ed", "alg": "EdDSA", "private_key_file": "ed.pem"},
+			{"kid": "ed", "alg": "EdDSA", "private_key_file": "ed.pem"}
+		]}`))
+
+		passed := shortErr != nil && mismatchErr != nil && duplicateErr != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected short secrets, mismatched algorithms and duplicate kids to be refused"
+		}
+		testutils.RecordTest(t, "JWT Key File - Reject Bad Keys", passed, errMsg)
+	})
+}
+
+func TestActionTokens(t *testing.T) {
+	t.Run("Purpose Bound", func(t *testing.T) {
+		token, jti, err := utils.GenerateActionToken(7, "password_reset", time.Hour)
+		userID, gotJTI, validErr := utils.ValidateActionToken(token, "password_reset")
+		_, _, wrongPurposeErr := utils.ValidateActionToken(token, "verify_email")
+
+		passed := err == nil && validErr == nil && userID == 7 && gotJTI == jti && wrongPurposeErr != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected an action token to validate only for its own purpose"
+		}
+		testutils.RecordTest(t, "Action Tokens - Purpose Bound", passed, errMsg)
+	})
+
+	t.Run("Not Access Tokens", func(t *testing.T) {
+		actionToken, _, _ := utils.GenerateActionToken(7, "password_reset", time.Hour)
+		accessToken, _ := utils.GenerateToken(7, "jwt@example.com")
+
+		_, actionErr := utils.ValidateToken(actionToken)
+		_, _, accessErr := utils.ValidateActionToken(accessToken, "password_reset")
+
+		passed := actionErr != nil && accessErr != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected action and access tokens not to be interchangeable"
+		}
+		testutils.RecordTest(t, "Action Tokens - Not Access Tokens", passed, errMsg)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		token, _, _ := utils.GenerateActionToken(7, "verify_email", -time.Minute)
+		_, _, err := utils.ValidateActionToken(token, "verify_email")
+
+		passed := err != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected an expired action token to be refused"
+		}
+		testutils.RecordTest(t, "Action Tokens - Expired", passed, errMsg)
+	})
+}
+
+func TestAuthMiddlewareKeys(t *testing.T) {
+	original := utils.CurrentKeySet()
+	defer utils.SetKeySet(original)
+
+	testutils.TruncateTable("users")
+	testutils.TruncateTable("sessions")
+	testutils.TruncateTable("refresh_tokens")
+
+	user := &models.User{
+		Email:    "middleware-keys@example.com",
+		Password: "password123",
+	}
+	models.CreateUser(testutils.TestDB, user)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	signing, _ := utils.NewKeySet([]*utils.SigningKey{utils.NewRSAKey("old", rsaKey)})
+	utils.SetKeySet(signing)
+	pair, _ := models.CreateTokenPair(testutils.TestDB, user.UserID, user.Email, models.SessionInfo{})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	routes.SetupRouter(router)
+	listSessions := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/my/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	secret := utils.NewHMACKey("current", []byte("a-shared-secret-of-at-least-32-characters"))
+
+	t.Run("Active Key Accepted", func(t *testing.T) {
+		code := listSessions()
+		passed := code == http.StatusOK
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected 200 with a token from an active key, got %d", code)
+		}
+		testutils.RecordTest(t, "JWT Keys - Middleware Active Key", passed, errMsg)
+	})
+
+	t.Run("Expired Key Rejected", func(t *testing.T) {
+		expired := utils.NewRSAKey("old", rsaKey)
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		keys, _ := utils.NewKeySet([]*utils.SigningKey{expired, secret})
+		utils.SetKeySet(keys)
+
+		code := listSessions()
+		passed := code == http.StatusUnauthorized
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected 401 once the signing key expired, got %d", code)
+		}
+		testutils.RecordTest(t, "JWT Keys - Middleware Expired Key", passed, errMsg)
+	})
+
+	t.Run("Unknown Key Rejected", func(t *testing.T) {
+		keys, _ := utils.NewKeySet([]*utils.SigningKey{secret})
+		utils.SetKeySet(keys)
+
+		code := listSessions()
+		passed := code == http.StatusUnauthorized
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected 401 once the signing key was removed, got %d", code)
+		}
+		testutils.RecordTest(t, "JWT Keys - Middleware Unknown Key", passed, errMsg)
+	})
+}
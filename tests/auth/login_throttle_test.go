@@ -0,0 +1,143 @@
+package auth_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	utils.TruncateTable("users")
+	utils.TruncateTable("login_throttles")
+	utils.TruncateTable("login_audit_events")
+
+	original := models.LoginProtection
+	defer func() { models.LoginProtection = original }()
+	models.LoginProtection = models.LoginProtectionConfig{
+		AccountMaxFailures: 3,
+		IPMaxFailures:      5,
+		FailureWindow:      15 * time.Minute,
+		BaseDelay:          time.Second,
+		MaxDelay:           30 * time.Second,
+		LockoutDuration:    15 * time.Minute,
+		MaxLockoutDuration: 24 * time.Hour,
+	}
+
+	user := &models.User{
+		Email:    "locked@example.com",
+		Password: "Str0ng!Pass",
+	}
+	models.CreateUser(utils.TestDB, user)
+	now := time.Now().Truncate(time.Second) // Stored timestamps lose sub-second precision
+
+	t.Run("Progressive Delay", func(t *testing.T) {
+		models.RecordLoginFailure(utils.TestDB, "delay@example.com", "192.0.2.1", now)
+		first, _ := models.CheckLogin(utils.TestDB, "delay@example.com", "192.0.2.1", now)
+		later, _ := models.CheckLogin(utils.TestDB, "delay@example.com", "192.0.2.1", now.Add(2*time.Second))
+
+		models.RecordLoginFailure(utils.TestDB, "delay@example.com", "192.0.2.1", now.Add(2*time.Second))
+		second, _ := models.CheckLogin(utils.TestDB, "DELAY@example.com", "192.0.2.1", now.Add(2*time.Second))
+
+		passed := first != nil && !first.Locked && first.RetryAfter == time.Second &&
+			later == nil && second != nil && second.RetryAfter == 2*time.Second
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected each failure to double the wait before the next attempt"
+		}
+		utils.RecordTest(t, "Login Throttle - Progressive Delay", passed, errMsg)
+	})
+
+	t.Run("Account Lockout", func(t *testing.T) {
+		var block *models.LoginBlock
+		for i := 0; i < 3; i++ {
+			block, _ = models.RecordLoginFailure(utils.TestDB, user.Email, "192.0.2.2", now)
+		}
+		during, _ := models.CheckLogin(utils.TestDB, user.Email, "192.0.2.3", now.Add(time.Minute))
+		after, _ := models.CheckLogin(utils.TestDB, user.Email, "192.0.2.3", now.Add(16*time.Minute))
+
+		var event models.LoginAuditEvent
+		auditErr := utils.TestDB.Where("type = ? AND throttle_key = ?", models.LoginAuditLocked, user.Email).First(&event).Error
+
+		passed := block != nil && block.Locked && block.Scope == models.LoginScopeAccount &&
+			during != nil && during.Locked && after == nil &&
+			auditErr == nil && event.UserID != nil && *event.UserID == user.UserID && event.IP == "192.0.2.2"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the account to lock, be audited, and unlock on its own"
+		}
+		utils.RecordTest(t, "Login Throttle - Account Lockout", passed, errMsg)
+	})
+
+	t.Run("Escalating Lockout", func(t *testing.T) {
+		later := now.Add(20 * time.Minute)
+		var block *models.LoginBlock
+		for i := 0; i < 3; i++ {
+			block, _ = models.RecordLoginFailure(utils.TestDB, user.Email, "192.0.2.4", later)
+		}
+
+		passed := block != nil && block.Locked && block.RetryAfter == 30*time.Minute
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected the second lockout to last twice as long, got %+v", block)
+		}
+		utils.RecordTest(t, "Login Throttle - Escalating Lockout", passed, errMsg)
+	})
+
+	t.Run("Admin Unlock", func(t *testing.T) {
+		err := models.UnlockAccount(utils.TestDB, user.UserID, 99)
+		block, _ := models.CheckLogin(utils.TestDB, user.Email, "192.0.2.5", now.Add(21*time.Minute))
+
+		var event models.LoginAuditEvent
+		auditErr := utils.TestDB.Where("type = ?", models.LoginAuditUnlocked).First(&event).Error
+
+		passed := err == nil && block == nil && auditErr == nil && event.ActorID != nil && *event.ActorID == 99
+		errMsg := ""
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to unlock account: %v", err)
+		} else if !passed {
+			errMsg = "Expected the admin unlock to lift the lockout and be audited"
+		}
+		utils.RecordTest(t, "Login Throttle - Admin Unlock", passed, errMsg)
+	})
+
+	t.Run("IP Lockout", func(t *testing.T) {
+		var block *models.LoginBlock
+		for i := 0; i < 5; i++ {
+			block, _ = models.RecordLoginFailure(utils.TestDB, fmt.Sprintf("spray%d@example.com", i), "192.0.2.66", now)
+		}
+		other, _ := models.CheckLogin(utils.TestDB, "fresh@example.com", "192.0.2.66", now.Add(time.Minute))
+		lockouts, _ := models.GetLoginLockouts(utils.TestDB, now.Add(time.Minute))
+
+		ipLocked := false
+		for _, l := range lockouts {
+			if l.Scope == models.LoginScopeIP && l.Key == "192.0.2.66" {
+				ipLocked = true
+			}
+		}
+
+		passed := block != nil && block.Locked && block.Scope == models.LoginScopeIP &&
+			other != nil && other.Locked && ipLocked
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected failures across many emails to lock the IP"
+		}
+		utils.RecordTest(t, "Login Throttle - IP Lockout", passed, errMsg)
+	})
+
+	t.Run("Success Resets Account", func(t *testing.T) {
+		models.RecordLoginFailure(utils.TestDB, "reset-count@example.com", "", now)
+		models.RecordLoginFailure(utils.TestDB, "reset-count@example.com", "", now)
+		err := models.RecordLoginSuccess(utils.TestDB, "reset-count@example.com")
+		block, _ := models.RecordLoginFailure(utils.TestDB, "reset-count@example.com", "", now)
+
+		passed := err == nil && block != nil && !block.Locked && block.RetryAfter == time.Second
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected a successful login to clear earlier failures"
+		}
+		utils.RecordTest(t, "Login Throttle - Success Resets", passed, errMsg)
+	})
+}
@@ -0,0 +1,135 @@
+package auth_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/routes"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRefreshTokens(t *testing.T) {
+	utils.TruncateTable("users")
+	utils.TruncateTable("sessions")
+	utils.TruncateTable("refresh_tokens")
+
+	user := &models.User{
+		Email:    "refresh@example.com",
+		Password: "password123",
+	}
+	models.CreateUser(utils.TestDB, user)
+
+	var pair *models.TokenPair
+
+	t.Run("Login Pair", func(t *testing.T) {
+		var err error
+		pair, err = models.CreateTokenPair(utils.TestDB, user.UserID, user.Email, models.SessionInfo{})
+
+		var stored models.RefreshToken
+		utils.TestDB.Where("user_id = ?", user.UserID).First(&stored)
+		_, sessionErr := models.GetSession(utils.TestDB, pair.AccessToken)
+
+		passed := err == nil && sessionErr == nil && pair.RefreshToken != "" &&
+			stored.TokenHash != "" && stored.TokenHash != pair.RefreshToken
+		errMsg := ""
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to create token pair: %v", err)
+		} else if !passed {
+			errMsg = "Expected an active session and a refresh token stored only as a hash"
+		}
+		utils.RecordTest(t, "Refresh Token - Login Pair", passed, errMsg)
+	})
+
+	var rotated *models.TokenPair
+
+	t.Run("Rotate", func(t *testing.T) {
+		var err error
+		rotated, err = models.RefreshTokenPair(utils.TestDB, pair.RefreshToken, models.SessionInfo{})
+
+		passed := err == nil && rotated.RefreshToken != pair.RefreshToken &&
+			rotated.AccessToken != pair.AccessToken
+		if passed {
+			_, sessionErr := models.GetSession(utils.TestDB, rotated.AccessToken)
+			passed = sessionErr == nil
+		}
+		errMsg := ""
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to rotate refresh token: %v", err)
+		} else if !passed {
+			errMsg = "Expected a new access token and refresh token"
+		}
+		utils.RecordTest(t, "Refresh Token - Rotate", passed, errMsg)
+	})
+
+	t.Run("Reuse Revokes Family", func(t *testing.T) {
+		_, reuseErr := models.RefreshTokenPair(utils.TestDB, pair.RefreshToken, models.SessionInfo{})
+		_, latestErr := models.RefreshTokenPair(utils.TestDB, rotated.RefreshToken, models.SessionInfo{})
+		_, sessionErr := models.GetSession(utils.TestDB, rotated.AccessToken)
+
+		passed := reuseErr != nil && reuseErr.Error() == "refresh token reuse detected" &&
+			latestErr != nil && latestErr.Error() == "refresh token revoked" && sessionErr != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected a replayed token to revoke the family and its sessions"
+		}
+		utils.RecordTest(t, "Refresh Token - Reuse Detection", passed, errMsg)
+	})
+
+	t.Run("Expired Token", func(t *testing.T) {
+		fresh, _ := models.CreateTokenPair(utils.TestDB, user.UserID, user.Email, models.SessionInfo{})
+		utils.TestDB.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.UserID).
+			Update("expires_at", time.Now().Add(-time.Minute))
+
+		_, err := models.RefreshTokenPair(utils.TestDB, fresh.RefreshToken, models.SessionInfo{})
+		_, unknownErr := models.RefreshTokenPair(utils.TestDB, "not-a-token", models.SessionInfo{})
+
+		passed := err != nil && err.Error() == "refresh token expired" &&
+			unknownErr != nil && unknownErr.Error() == "invalid refresh token"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected expired and unknown refresh tokens to be refused"
+		}
+		utils.RecordTest(t, "Refresh Token - Expired", passed, errMsg)
+	})
+
+	t.Run("Logout Revokes Family", func(t *testing.T) {
+		fresh, _ := models.CreateTokenPair(utils.TestDB, user.UserID, user.Email, models.SessionInfo{})
+		err := models.EndSession(utils.TestDB, fresh.AccessToken)
+		_, refreshErr := models.RefreshTokenPair(utils.TestDB, fresh.RefreshToken, models.SessionInfo{})
+
+		passed := err == nil && refreshErr != nil && refreshErr.Error() == "refresh token revoked"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected logout to revoke the session's refresh tokens"
+		}
+		utils.RecordTest(t, "Refresh Token - Logout", passed, errMsg)
+	})
+
+	t.Run("Logout Route Revokes Cookie", func(t *testing.T) {
+		fresh, _ := models.CreateTokenPair(utils.TestDB, user.UserID, user.Email, models.SessionInfo{})
+
+		// Browsers only send the refresh token cookie to /auth paths
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		routes.SetupRouter(router)
+		req := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+		req.AddCookie(&http.Cookie{Name: "refresh_token", Value: fresh.RefreshToken})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		_, refreshErr := models.RefreshTokenPair(utils.TestDB, fresh.RefreshToken, models.SessionInfo{})
+
+		passed := w.Code == http.StatusOK && refreshErr != nil && refreshErr.Error() == "refresh token revoked"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected POST /auth/logout to revoke the refresh token from its cookie"
+		}
+		utils.RecordTest(t, "Refresh Token - Logout Route Cookie", passed, errMsg)
+	})
+}
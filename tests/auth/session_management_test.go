@@ -0,0 +1,183 @@
+package auth_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/routes"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSessionManagement(t *testing.T) {
+	utils.TruncateTable("users")
+	utils.TruncateTable("sessions")
+	utils.TruncateTable("refresh_tokens")
+
+	user := &models.User{
+		Email:    "devices@example.com",
+		Password: "password123",
+	}
+	models.CreateUser(utils.TestDB, user)
+
+	laptop, _ := models.CreateTokenPair(utils.TestDB, user.UserID, user.Email, models.SessionInfo{
+		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36",
+		IP:        "203.0.113.10",
+	})
+	phone, _ := models.CreateTokenPair(utils.TestDB, user.UserID, user.Email, models.SessionInfo{
+		UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+		IP:        "198.51.100.7",
+	})
+
+	t.Run("List Sessions", func(t *testing.T) {
+		current, _ := models.GetSession(utils.TestDB, laptop.AccessToken)
+		sessions, err := models.GetUserSessions(utils.TestDB, user.UserID, current.ID)
+
+		devices := make(map[string]bool)
+		currentCount := 0
+		for _, s := range sessions {
+			devices[s.Device] = true
+			if s.Current {
+				currentCount++
+			}
+		}
+
+		passed := err == nil && len(sessions) == 2 && currentCount == 1 &&
+			devices["Chrome on Windows"] && devices["Safari on iOS"]
+		errMsg := ""
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to list sessions: %v", err)
+		} else if !passed {
+			errMsg = fmt.Sprintf("Expected two described devices with one current, got %+v", sessions)
+		}
+		utils.RecordTest(t, "Session Management - List", passed, errMsg)
+	})
+
+	t.Run("Token Stored Hashed", func(t *testing.T) {
+		var count int64
+		utils.TestDB.Model(&models.Session{}).Where("token_hash = ?", laptop.AccessToken).Count(&count)
+
+		passed := count == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the access token not to be stored in plaintext"
+		}
+		utils.RecordTest(t, "Session Management - Hashed Token", passed, errMsg)
+	})
+
+	t.Run("Refresh Keeps Session", func(t *testing.T) {
+		before, _ := models.GetSession(utils.TestDB, phone.AccessToken)
+		rotated, err := models.RefreshTokenPair(utils.TestDB, phone.RefreshToken, models.SessionInfo{IP: "198.51.100.8"})
+
+		passed := err == nil
+		if passed {
+			after, getErr := models.GetSession(utils.TestDB, rotated.AccessToken)
+			passed = getErr == nil && after.ID == before.ID && after.IP == "198.51.100.8"
+			phone = rotated
+		}
+		errMsg := ""
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to refresh: %v", err)
+		} else if !passed {
+			errMsg = "Expected the device to keep its session across refreshes"
+		}
+		utils.RecordTest(t, "Session Management - Refresh Keeps Session", passed, errMsg)
+	})
+
+	t.Run("Revoke One Session", func(t *testing.T) {
+		session, _ := models.GetSession(utils.TestDB, phone.AccessToken)
+		otherErr := models.RevokeSession(utils.TestDB, user.UserID+1, session.ID)
+		err := models.RevokeSession(utils.TestDB, user.UserID, session.ID)
+
+		_, sessionErr := models.GetSession(utils.TestDB, phone.AccessToken)
+		_, refreshErr := models.RefreshTokenPair(utils.TestDB, phone.RefreshToken, models.SessionInfo{})
+
+		passed := otherErr != nil && otherErr.Error() == "session not found" && err == nil &&
+			sessionErr != nil && refreshErr != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected only the owner to revoke a session, ending its refresh tokens"
+		}
+		utils.RecordTest(t, "Session Management - Revoke One", passed, errMsg)
+	})
+
+	t.Run("Sign Out Other Devices", func(t *testing.T) {
+		tablet, _ := models.CreateTokenPair(utils.TestDB, user.UserID, user.Email, models.SessionInfo{})
+		current, _ := models.GetSession(utils.TestDB, laptop.AccessToken)
+
+		revoked, err := models.RevokeUserSessions(utils.TestDB, user.UserID, current.ID)
+		_, keptErr := models.GetSession(utils.TestDB, laptop.AccessToken)
+		_, tabletErr := models.RefreshTokenPair(utils.TestDB, tablet.RefreshToken, models.SessionInfo{})
+
+		passed := err == nil && revoked == 1 && keptErr == nil && tabletErr != nil
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected one other session revoked and the current kept, got %d (%v)", revoked, err)
+		}
+		utils.RecordTest(t, "Session Management - Sign Out Others", passed, errMsg)
+	})
+
+	t.Run("Admin Revokes All", func(t *testing.T) {
+		revoked, err := models.RevokeUserSessions(utils.TestDB, user.UserID, 0)
+		_, sessionErr := models.GetSession(utils.TestDB, laptop.AccessToken)
+		_, refreshErr := models.RefreshTokenPair(utils.TestDB, laptop.RefreshToken, models.SessionInfo{})
+
+		passed := err == nil && revoked == 1 && sessionErr != nil && refreshErr != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected every session and refresh token to be revoked"
+		}
+		utils.RecordTest(t, "Session Management - Admin Revoke All", passed, errMsg)
+	})
+}
+
+func TestGuestRouteSession(t *testing.T) {
+	utils.TruncateTable("users")
+	utils.TruncateTable("sessions")
+	utils.TruncateTable("refresh_tokens")
+
+	user := &models.User{
+		Email:    "guest-route@example.com",
+		Password: "password123",
+	}
+	models.CreateUser(utils.TestDB, user)
+	product := &models.Product{Name: "Guest Route Product", Price: 10, Category: "Test", Stock: 5}
+	utils.TestDB.Create(product)
+	pair, _ := models.CreateTokenPair(utils.TestDB, user.UserID, user.Email, models.SessionInfo{})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	routes.SetupRouter(router)
+	viewAsGuest := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/guest/products/%d", product.ID), nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Signed In User Redirected", func(t *testing.T) {
+		code := viewAsGuest(pair.AccessToken)
+		passed := code == http.StatusForbidden
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected 403 for a valid session on the guest route, got %d", code)
+		}
+		utils.RecordTest(t, "Session Management - Guest Route Valid Token", passed, errMsg)
+	})
+
+	t.Run("Guest Allowed", func(t *testing.T) {
+		code := viewAsGuest("")
+		passed := code == http.StatusOK
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected 200 for a guest, got %d", code)
+		}
+		utils.RecordTest(t, "Session Management - Guest Route Without Token", passed, errMsg)
+	})
+}
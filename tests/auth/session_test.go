@@ -71,8 +71,8 @@ func TestSessionExpiration(t *testing.T) {
 			t.Fatalf("Failed to create session: %v", err)
 		}
 
-		// Simulate session expiration by directly updating the created_at timestamp
-		utils.TestDB.Exec("UPDATE sessions SET created_at = DATE_SUB(NOW(), INTERVAL 25 HOUR) WHERE token = ?", token)
+		// Simulate session expiration by directly updating the issued_at timestamp
+		utils.TestDB.Exec("UPDATE sessions SET issued_at = DATE_SUB(NOW(), INTERVAL 25 HOUR) WHERE user_id = ?", user.UserID)
 
 		session, getErr := models.GetSession(utils.TestDB, token)
 		passed := getErr != nil || session == nil
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/amcishara/web_Tracking_system/models"
 	"github.com/amcishara/web_Tracking_system/tests/utils"
@@ -228,6 +229,43 @@ func TestUserDelete(t *testing.T) {
 		}
 		utils.RecordTest(t, "User Delete - Non-existent", passed, errMsg)
 	})
+
+	t.Run("Delete Cascades", func(t *testing.T) {
+		user := &models.User{Email: "cascade@example.com", Password: "SecureP@ss123"}
+		models.CreateUser(utils.TestDB, user)
+		product := &models.Product{Name: "Cascade Product", Price: 10, Category: "Test", Stock: 5}
+		utils.TestDB.Create(product)
+
+		models.CreateTokenPair(utils.TestDB, user.UserID, user.Email, models.SessionInfo{})
+		utils.TestDB.Create(&models.AccountToken{
+			UserID: user.UserID, Purpose: "verify_email", JTI: "cascade-jti", ExpiresAt: time.Now().Add(time.Hour),
+		})
+		wishlist, _ := models.CreateWishlist(utils.TestDB, user.UserID, "Cascade")
+		models.AddToWishlist(utils.TestDB, user.UserID, wishlist.ID, product.ID, 1)
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 2)
+
+		err := models.DeleteUser(utils.TestDB, int(user.UserID))
+
+		remaining := map[string]int64{}
+		for _, table := range []string{"sessions", "refresh_tokens", "account_tokens", "wishlists", "cart_items"} {
+			var count int64
+			utils.TestDB.Table(table).Where("user_id = ?", user.UserID).Count(&count)
+			remaining[table] = count
+		}
+		var items, reservations int64
+		utils.TestDB.Model(&models.WishlistItem{}).Where("wishlist_id = ?", wishlist.ID).Count(&items)
+		utils.TestDB.Model(&models.StockReservation{}).Where("product_id = ?", product.ID).Count(&reservations)
+
+		passed := err == nil && items == 0 && reservations == 0
+		for _, count := range remaining {
+			passed = passed && count == 0
+		}
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected no rows left for the user, got %v, %d wishlist items and %d reservations", remaining, items, reservations)
+		}
+		utils.RecordTest(t, "User Delete - Cascades", passed, errMsg)
+	})
 }
 
 func TestUserRole(t *testing.T) {
@@ -0,0 +1,130 @@
+package cart_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+// recordingNotifier keeps the carts it was asked to send, failing while fail is set
+type recordingNotifier struct {
+	carts []models.AbandonedCart
+	fail  bool
+}
+
+func (n *recordingNotifier) NotifyAbandonedCart(cart *models.AbandonedCart) error {
+	if n.fail {
+		return fmt.Errorf("marketing endpoint unavailable")
+	}
+	n.carts = append(n.carts, *cart)
+	return nil
+}
+
+// Helper function to make a cart look idle since the given time
+func idleCart(owner models.CartOwner, since time.Time) {
+	query := utils.TestDB.Model(&models.CartItem{})
+	if owner.UserID != 0 {
+		query = query.Where("user_id = ?", owner.UserID)
+	} else {
+		query = query.Where("user_id = 0 AND guest_id = ?", owner.GuestID)
+	}
+	query.UpdateColumn("updated_at", since)
+}
+
+func TestAbandonedCarts(t *testing.T) {
+	resetOrderTables()
+	utils.TruncateTable("events")
+	user, product := setupTestData()
+	other := &models.Product{Name: "Other Product", Price: 20, Category: "Other Category", Stock: 100}
+	utils.TestDB.Create(other)
+
+	guest := models.CartOwner{GuestID: "guest-abandoned"}
+	models.AddToCart(utils.TestDB, user.UserID, product.ID, 2) // 199.98
+	models.AddToCartFor(utils.TestDB, guest, other.ID, 3)      // 60.00
+	models.AddToCartFor(utils.TestDB, guest, product.ID, 1)    // 99.99
+
+	t.Run("Active Carts Ignored", func(t *testing.T) {
+		detected, err := models.DetectAbandonedCarts(utils.TestDB, time.Now())
+		passed := err == nil && detected == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected no abandoned carts while carts are fresh"
+		}
+		utils.RecordTest(t, "Abandoned Carts - Active Ignored", passed, errMsg)
+	})
+
+	t.Run("Detect Idle Carts", func(t *testing.T) {
+		idleCart(models.CartOwner{UserID: user.UserID}, time.Now().Add(-3*time.Hour))
+		idleCart(guest, time.Now().Add(-2*time.Hour))
+
+		detected, err := models.DetectAbandonedCarts(utils.TestDB, time.Now())
+		again, _ := models.DetectAbandonedCarts(utils.TestDB, time.Now())
+
+		var userCart models.AbandonedCart
+		utils.TestDB.Where("user_id = ?", user.UserID).First(&userCart)
+		var events int64
+		utils.TestDB.Model(&models.Event{}).Where("type = ?", models.EventCartAbandoned).Count(&events)
+
+		passed := err == nil && detected == 2 && again == 0 &&
+			userCart.Email == user.Email && userCart.Value == 199.98 && events == 2
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected both idle carts recorded once, with an event each"
+		}
+		utils.RecordTest(t, "Abandoned Carts - Detect Idle", passed, errMsg)
+	})
+
+	t.Run("Notify With Retry", func(t *testing.T) {
+		notifier := &recordingNotifier{fail: true}
+		failedSent, failErr := models.NotifyAbandonedCarts(utils.TestDB, notifier)
+
+		notifier.fail = false
+		sent, err := models.NotifyAbandonedCarts(utils.TestDB, notifier)
+		resent, _ := models.NotifyAbandonedCarts(utils.TestDB, notifier)
+
+		passed := failErr == nil && failedSent == 0 && err == nil && sent == 2 && resent == 0 &&
+			len(notifier.carts) == 2 && len(notifier.carts[0].Items) > 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected failed sends to be retried and each cart sent once"
+		}
+		utils.RecordTest(t, "Abandoned Carts - Notify", passed, errMsg)
+	})
+
+	t.Run("Recovered By Checkout", func(t *testing.T) {
+		order, err := models.Checkout(utils.TestDB, user.UserID)
+
+		var userCart models.AbandonedCart
+		utils.TestDB.Where("user_id = ?", user.UserID).First(&userCart)
+
+		passed := err == nil && userCart.RecoveredAt != nil &&
+			userCart.RecoveredOrderID != nil && *userCart.RecoveredOrderID == order.ID
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected checkout to mark the abandoned cart recovered"
+		}
+		utils.RecordTest(t, "Abandoned Carts - Recovered", passed, errMsg)
+	})
+
+	t.Run("Report By Product And Category", func(t *testing.T) {
+		report, err := models.GetAbandonedCartReport(utils.TestDB, time.Now().Add(-time.Hour), time.Now().Add(time.Minute))
+
+		var productValue float64
+		for _, p := range report.ByProduct {
+			if p.ProductID == product.ID {
+				productValue = p.Value
+			}
+		}
+		passed := err == nil && report.Carts == 2 && report.Value == 359.97 &&
+			report.RecoveredCarts == 1 && report.RecoveryRate == 0.5 &&
+			productValue == 299.97 && len(report.ByCategory) == 2
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected abandoned value split by product and category"
+		}
+		utils.RecordTest(t, "Abandoned Carts - Report", passed, errMsg)
+	})
+}
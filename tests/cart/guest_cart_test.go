@@ -0,0 +1,159 @@
+package cart_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+func TestGuestCart(t *testing.T) {
+	resetOrderTables()
+	user, product := setupTestData()
+	guest := models.CartOwner{GuestID: "guest-cart-1"}
+
+	t.Run("Add And View", func(t *testing.T) {
+		err := models.AddToCartFor(utils.TestDB, guest, product.ID, 2)
+		summary, getErr := models.GetCartFor(utils.TestDB, guest)
+		userCart, _ := models.GetCart(utils.TestDB, user.UserID)
+
+		passed := err == nil && getErr == nil && summary.TotalItems == 2 && userCart.TotalItems == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected 2 items in the guest cart and none in the user cart"
+		}
+		utils.RecordTest(t, "Guest Cart - Add And View", passed, errMsg)
+	})
+
+	t.Run("Soft Hold", func(t *testing.T) {
+		// Fresh guest IDs must not be able to lock up stock
+		err := models.AddToCartFor(utils.TestDB, models.CartOwner{GuestID: "guest-cart-2"}, product.ID, 100)
+		available, _ := models.GetAvailableStock(utils.TestDB, product.ID)
+		userErr := models.AddToCart(utils.TestDB, user.UserID, product.ID, 100)
+
+		var reservations int64
+		utils.TestDB.Model(&models.StockReservation{}).Count(&reservations)
+
+		passed := err == nil && available == 100 && userErr == nil && reservations == 1
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected guest lines to hold no stock and a customer to still reserve all of it"
+		}
+		utils.RecordTest(t, "Guest Cart - Soft Hold", passed, errMsg)
+	})
+
+	t.Run("Other Guest Cannot Remove", func(t *testing.T) {
+		var item models.CartItem
+		utils.TestDB.Where("guest_id = ?", guest.GuestID).First(&item)
+
+		err := models.RemoveFromCartFor(utils.TestDB, models.CartOwner{GuestID: "someone-else"}, item.ID)
+		passed := err != nil && err.Error() == "item not found in cart"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected another guest's item to be out of reach"
+		}
+		utils.RecordTest(t, "Guest Cart - Isolation", passed, errMsg)
+	})
+
+	t.Run("Missing Owner", func(t *testing.T) {
+		err := models.AddToCartFor(utils.TestDB, models.CartOwner{}, product.ID, 1)
+		passed := err != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected an error without a user or guest ID"
+		}
+		utils.RecordTest(t, "Guest Cart - Missing Owner", passed, errMsg)
+	})
+}
+
+func TestMergeGuestCart(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		stock    int
+		expected int
+		warnings int
+	}{
+		{models.CartMergeSum, models.CartMergeSum, 100, 5, 0},
+		{models.CartMergeMax, models.CartMergeMax, 100, 3, 0},
+		{models.CartMergeCap, models.CartMergeCap, 4, 4, 0},
+		{models.CartMergeSum + " short", models.CartMergeSum, 4, 4, 1},
+		{models.CartMergeMax + " short", models.CartMergeMax, 2, 2, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run("Rule "+tt.name, func(t *testing.T) {
+			resetOrderTables()
+			user, product := setupTestData()
+			utils.TestDB.Model(&models.Product{}).Where("id = ?", product.ID).Update("stock", tt.stock)
+			guest := models.CartOwner{GuestID: "guest-merge-" + tt.rule}
+
+			models.AddToCart(utils.TestDB, user.UserID, product.ID, 2)
+			// Put the guest item in directly; the user's hold would otherwise block it on low stock
+			utils.TestDB.Create(&models.CartItem{GuestID: guest.GuestID, ProductID: product.ID, Quantity: 3})
+
+			warnings, err := models.MergeGuestCart(utils.TestDB, guest.GuestID, user.UserID, tt.rule)
+			userCart, _ := models.GetCart(utils.TestDB, user.UserID)
+			guestCart, _ := models.GetCartFor(utils.TestDB, guest)
+
+			// Every rule is clamped to stock, so the user's hold never exceeds it
+			var reserved int
+			utils.TestDB.Model(&models.StockReservation{}).Select("COALESCE(SUM(quantity), 0)").Scan(&reserved)
+
+			passed := err == nil && userCart.TotalItems == tt.expected && guestCart.TotalItems == 0 &&
+				len(warnings) == tt.warnings && reserved <= tt.stock
+			errMsg := ""
+			if !passed {
+				errMsg = "Unexpected merged quantity or warnings for rule " + tt.name
+			}
+			utils.RecordTest(t, "Guest Cart - Merge "+tt.name, passed, errMsg)
+		})
+	}
+
+	t.Run("Move Without Conflict", func(t *testing.T) {
+		resetOrderTables()
+		user, product := setupTestData()
+		guest := models.CartOwner{GuestID: "guest-move"}
+
+		models.AddToCartFor(utils.TestDB, guest, product.ID, 1)
+		_, err := models.MergeGuestCart(utils.TestDB, guest.GuestID, user.UserID, models.CartMergeSum)
+		userCart, _ := models.GetCart(utils.TestDB, user.UserID)
+
+		var reservations int64
+		utils.TestDB.Model(&models.StockReservation{}).Count(&reservations)
+
+		passed := err == nil && userCart.TotalItems == 1 && reservations == 1
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the guest item to move to the user with its reservation"
+		}
+		utils.RecordTest(t, "Guest Cart - Move Without Conflict", passed, errMsg)
+	})
+
+	t.Run("Keep User Line When Sold Out", func(t *testing.T) {
+		resetOrderTables()
+		user, product := setupTestData()
+		utils.TestDB.Model(&models.Product{}).Where("id = ?", product.ID).Update("stock", 3)
+		guest := models.CartOwner{GuestID: "guest-sold-out"}
+
+		// The user's hold lapses and another customer reserves all the stock
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 2)
+		utils.TestDB.Model(&models.StockReservation{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Minute))
+		other := models.User{Email: "other-merge@example.com", Password: "Password123!"}
+		utils.TestDB.Create(&other)
+		models.AddToCart(utils.TestDB, other.UserID, product.ID, 3)
+		utils.TestDB.Create(&models.CartItem{GuestID: guest.GuestID, ProductID: product.ID, Quantity: 1})
+
+		warnings, err := models.MergeGuestCart(utils.TestDB, guest.GuestID, user.UserID, models.CartMergeCap)
+		userCart, _ := models.GetCart(utils.TestDB, user.UserID)
+
+		passed := err == nil && len(warnings) == 1 && warnings[0].Type == models.CartWarningInsufficientStock &&
+			userCart.TotalItems == 2
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the user's line kept as is with an insufficient stock warning"
+		}
+		utils.RecordTest(t, "Guest Cart - Keep User Line When Sold Out", passed, errMsg)
+	})
+}
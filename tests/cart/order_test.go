@@ -0,0 +1,234 @@
+package cart_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+func resetOrderTables() {
+	utils.TruncateTable("events")
+	utils.TruncateTable("abandoned_cart_items")
+	utils.TruncateTable("abandoned_carts")
+	utils.TruncateTable("cart_coupons")
+	utils.TruncateTable("promotion_redemptions")
+	utils.TruncateTable("promotions")
+	utils.TruncateTable("stock_reservations")
+	utils.TruncateTable("order_items")
+	utils.TruncateTable("orders")
+	utils.TruncateTable("cart_items")
+	utils.TruncateTable("products")
+	utils.TruncateTable("users")
+}
+
+func TestCheckout(t *testing.T) {
+	resetOrderTables()
+	user, product := setupTestData()
+
+	t.Run("Empty Cart", func(t *testing.T) {
+		_, err := models.Checkout(utils.TestDB, user.UserID)
+		passed := err != nil && err.Error() == "cart is empty"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected 'cart is empty' error"
+		}
+		utils.RecordTest(t, "Checkout - Empty Cart", passed, errMsg)
+	})
+
+	t.Run("Place Order", func(t *testing.T) {
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 3)
+
+		order, err := models.Checkout(utils.TestDB, user.UserID)
+
+		var updated models.Product
+		utils.TestDB.First(&updated, product.ID)
+		var cartCount int64
+		utils.TestDB.Model(&models.CartItem{}).Where("user_id = ?", user.UserID).Count(&cartCount)
+
+		passed := err == nil &&
+			order.Status == models.OrderPending &&
+			order.TotalItems == 3 &&
+			len(order.Items) == 1 &&
+			order.Items[0].UnitPrice == product.Price &&
+			updated.Stock == 97 &&
+			cartCount == 0
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		} else if !passed {
+			errMsg = "Expected order with 3 items, stock decremented to 97 and cart cleared"
+		}
+		utils.RecordTest(t, "Checkout - Place Order", passed, errMsg)
+	})
+
+	t.Run("Price Snapshot", func(t *testing.T) {
+		utils.TestDB.Model(&models.Product{}).Where("id = ?", product.ID).Update("price", 149.99)
+
+		orders, err := models.GetUserOrders(utils.TestDB, user.UserID)
+		passed := err == nil && len(orders) == 1 && orders[0].Items[0].UnitPrice == 99.99
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected order item to keep the price paid at checkout"
+		}
+		utils.RecordTest(t, "Checkout - Price Snapshot", passed, errMsg)
+	})
+
+	t.Run("Purchase Events", func(t *testing.T) {
+		orders, _ := models.GetUserOrders(utils.TestDB, user.UserID)
+		err := models.RecordPurchaseEvents(utils.TestDB, &orders[0])
+
+		var events []models.Event
+		utils.TestDB.Where("type = ? AND user_id = ?", models.EventPurchase, user.UserID).Find(&events)
+
+		passed := err == nil && len(events) == 1 && events[0].ProductID != nil && *events[0].ProductID == product.ID &&
+			events[0].Properties["amount"] == orders[0].Items[0].Subtotal
+		errMsg := ""
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to record purchase events: %v", err)
+		} else if !passed {
+			errMsg = "Expected one purchase event valued at the line subtotal"
+		}
+		utils.RecordTest(t, "Checkout - Purchase Events", passed, errMsg)
+	})
+
+	t.Run("Insufficient Stock Rolls Back", func(t *testing.T) {
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 5)
+		utils.TestDB.Model(&models.Product{}).Where("id = ?", product.ID).Update("stock", 2)
+
+		_, err := models.Checkout(utils.TestDB, user.UserID)
+
+		var orderCount, cartCount int64
+		utils.TestDB.Model(&models.Order{}).Count(&orderCount)
+		utils.TestDB.Model(&models.CartItem{}).Where("user_id = ?", user.UserID).Count(&cartCount)
+
+		passed := err != nil && orderCount == 1 && cartCount == 1
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected checkout to fail without creating an order or clearing the cart"
+		}
+		utils.RecordTest(t, "Checkout - Insufficient Stock", passed, errMsg)
+	})
+
+	t.Run("Concurrent Checkouts", func(t *testing.T) {
+		utils.TestDB.Model(&models.Product{}).Where("id = ?", product.ID).Update("stock", 50)
+
+		var wg sync.WaitGroup
+		errs := make([]error, 4)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = models.Checkout(utils.TestDB, user.UserID)
+			}(i)
+		}
+		wg.Wait()
+
+		placed := 0
+		for _, err := range errs {
+			if err == nil {
+				placed++
+			}
+		}
+		var orderCount int64
+		utils.TestDB.Model(&models.Order{}).Where("user_id = ?", user.UserID).Count(&orderCount)
+
+		passed := placed == 1 && orderCount == 2
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected one of the concurrent checkouts to place an order, got %d", placed)
+		}
+		utils.RecordTest(t, "Checkout - Concurrent", passed, errMsg)
+	})
+}
+
+func TestOrderHistory(t *testing.T) {
+	resetOrderTables()
+	user, product := setupTestData()
+
+	models.AddToCart(utils.TestDB, user.UserID, product.ID, 1)
+	order, _ := models.Checkout(utils.TestDB, user.UserID)
+
+	t.Run("Own Order", func(t *testing.T) {
+		found, err := models.GetUserOrder(utils.TestDB, user.UserID, order.ID)
+		passed := err == nil && found.ID == order.ID && len(found.Items) == 1
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected user to see their own order"
+		}
+		utils.RecordTest(t, "Orders - Own Order", passed, errMsg)
+	})
+
+	t.Run("Other User's Order", func(t *testing.T) {
+		_, err := models.GetUserOrder(utils.TestDB, user.UserID+1, order.ID)
+		passed := err != nil && err.Error() == "order not found"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected 'order not found' for another user's order"
+		}
+		utils.RecordTest(t, "Orders - Other User's Order", passed, errMsg)
+	})
+
+	t.Run("Admin Filter", func(t *testing.T) {
+		pending, total, err := models.GetOrders(utils.TestDB, models.OrderFilter{Status: models.OrderPending})
+		_, _, invalidErr := models.GetOrders(utils.TestDB, models.OrderFilter{Status: "lost"})
+		passed := err == nil && total == 1 && len(pending) == 1 && invalidErr != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected one pending order and an error for an invalid status"
+		}
+		utils.RecordTest(t, "Orders - Admin Filter", passed, errMsg)
+	})
+}
+
+func TestCancelOrder(t *testing.T) {
+	resetOrderTables()
+	utils.TruncateTable("inventory_movements")
+	user, product := setupTestData()
+
+	t.Run("Cancel Pending", func(t *testing.T) {
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 4)
+		order, _ := models.Checkout(utils.TestDB, user.UserID)
+
+		cancelled, err := models.CancelOrder(utils.TestDB, user.UserID, order.ID)
+		_, againErr := models.CancelOrder(utils.TestDB, user.UserID, order.ID)
+
+		var updated models.Product
+		utils.TestDB.First(&updated, product.ID)
+
+		passed := err == nil && cancelled.Status == models.OrderCancelled && updated.Stock == 100 &&
+			againErr != nil && againErr.Error() == "only pending orders can be cancelled"
+		errMsg := ""
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to cancel order: %v", err)
+		} else if !passed {
+			errMsg = "Expected a pending order to be cancelled once with its stock returned"
+		}
+		utils.RecordTest(t, "Orders - Cancel Pending", passed, errMsg)
+	})
+
+	t.Run("Expire Unpaid", func(t *testing.T) {
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 2)
+		order, _ := models.Checkout(utils.TestDB, user.UserID)
+
+		early, earlyErr := models.ExpirePendingOrders(utils.TestDB, order.CreatedAt.Add(-time.Minute))
+		expired, err := models.ExpirePendingOrders(utils.TestDB, time.Now().Add(time.Minute))
+
+		stored, _ := models.GetOrder(utils.TestDB, order.ID)
+		var updated models.Product
+		utils.TestDB.First(&updated, product.ID)
+
+		passed := earlyErr == nil && early == 0 && err == nil && expired == 1 &&
+			stored.Status == models.OrderCancelled && updated.Stock == 100
+		errMsg := ""
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to expire orders: %v", err)
+		} else if !passed {
+			errMsg = fmt.Sprintf("Expected only the stale order to be cancelled, got %d", expired)
+		}
+		utils.RecordTest(t, "Orders - Expire Unpaid", passed, errMsg)
+	})
+}
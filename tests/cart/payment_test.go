@@ -0,0 +1,251 @@
+package cart_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+func resetPaymentTables() {
+	utils.TruncateTable("payment_webhook_events")
+	utils.TruncateTable("payments")
+	utils.TruncateTable("inventory_movements")
+	resetOrderTables()
+}
+
+// Helper function to feed queued fake webhooks through verification and processing
+func deliverWebhooks(gateway *models.FakeGateway) (processed, duplicates int, err error) {
+	for _, webhook := range gateway.DrainWebhooks() {
+		event, verifyErr := gateway.VerifyWebhook(webhook.Payload, webhook.Signature)
+		if verifyErr != nil {
+			return processed, duplicates, verifyErr
+		}
+		ok, processErr := models.ProcessPaymentWebhook(utils.TestDB, gateway.Name(), event)
+		if processErr != nil {
+			return processed, duplicates, processErr
+		}
+		if ok {
+			processed++
+		} else {
+			duplicates++
+		}
+	}
+	return processed, duplicates, nil
+}
+
+func TestPayForCart(t *testing.T) {
+	resetPaymentTables()
+	user, product := setupTestData()
+	gateway := models.NewFakeGateway("test-secret")
+
+	t.Run("Approved", func(t *testing.T) {
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 2)
+		order, payment, err := models.PayForCart(utils.TestDB, gateway, user.UserID, models.FakeTokenApprove)
+
+		stored, _ := models.GetOrder(utils.TestDB, order.ID)
+		passed := err == nil && payment.Status == models.PaymentCaptured &&
+			stored.Status == models.OrderPaid && len(stored.Payments) == 1
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		} else if !passed {
+			errMsg = "Expected captured payment and paid order"
+		}
+		utils.RecordTest(t, "Payment - Approved", passed, errMsg)
+	})
+
+	t.Run("Purchase Events On Capture", func(t *testing.T) {
+		var paid models.Order
+		utils.TestDB.Where("status = ?", models.OrderPaid).First(&paid)
+
+		var events []models.Event
+		utils.TestDB.Where("type = ?", models.EventPurchase).Find(&events)
+
+		passed := len(events) == 1 && events[0].Properties["order_id"] == float64(paid.ID) &&
+			events[0].Properties["amount"] == 2*product.Price
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected one purchase event with an amount for the paid order, got %d", len(events))
+		}
+		utils.RecordTest(t, "Payment - Purchase Events", passed, errMsg)
+	})
+
+	t.Run("Declined Restocks", func(t *testing.T) {
+		var before models.Product
+		utils.TestDB.First(&before, product.ID)
+
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 3)
+		order, payment, err := models.PayForCart(utils.TestDB, gateway, user.UserID, models.FakeTokenDecline)
+
+		var after models.Product
+		utils.TestDB.First(&after, product.ID)
+		stored, _ := models.GetOrder(utils.TestDB, order.ID)
+
+		passed := err != nil && payment.Status == models.PaymentDeclined &&
+			payment.FailureReason == "card_declined" &&
+			stored.Status == models.OrderCancelled && after.Stock == before.Stock
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected declined payment, cancelled order and stock restored"
+		}
+		utils.RecordTest(t, "Payment - Declined Restocks", passed, errMsg)
+	})
+
+	t.Run("Delayed Capture By Webhook", func(t *testing.T) {
+		gateway.DrainWebhooks()
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 1)
+		order, payment, err := models.PayForCart(utils.TestDB, gateway, user.UserID, models.FakeTokenDelayed)
+		pendingOK := err == nil && payment.Status == models.PaymentPending
+
+		processed, _, hookErr := deliverWebhooks(gateway)
+		stored, _ := models.GetOrder(utils.TestDB, order.ID)
+
+		passed := pendingOK && hookErr == nil && processed == 1 &&
+			stored.Status == models.OrderPaid && stored.Payments[0].Status == models.PaymentCaptured
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected pending payment to be captured by the webhook"
+		}
+		utils.RecordTest(t, "Payment - Delayed Capture", passed, errMsg)
+	})
+
+	t.Run("Failed Capture By Webhook", func(t *testing.T) {
+		gateway.DrainWebhooks()
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 1)
+		order, _, err := models.PayForCart(utils.TestDB, gateway, user.UserID, models.FakeTokenCaptureFails)
+
+		_, _, hookErr := deliverWebhooks(gateway)
+		stored, _ := models.GetOrder(utils.TestDB, order.ID)
+
+		passed := err == nil && hookErr == nil &&
+			stored.Status == models.OrderCancelled && stored.Payments[0].Status == models.PaymentFailed
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the failure webhook to cancel the order"
+		}
+		utils.RecordTest(t, "Payment - Failed Capture", passed, errMsg)
+	})
+}
+
+func TestPayOrder(t *testing.T) {
+	resetPaymentTables()
+	user, product := setupTestData()
+	gateway := models.NewFakeGateway("test-secret")
+
+	t.Run("Pay Placed Order", func(t *testing.T) {
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 1)
+		order, _ := models.Checkout(utils.TestDB, user.UserID)
+
+		paid, payment, err := models.PayOrder(utils.TestDB, gateway, user.UserID, order.ID, models.FakeTokenApprove)
+		_, _, againErr := models.PayOrder(utils.TestDB, gateway, user.UserID, order.ID, models.FakeTokenApprove)
+
+		var purchases int64
+		utils.TestDB.Model(&models.Event{}).Where("type = ?", models.EventPurchase).Count(&purchases)
+
+		passed := err == nil && paid.Status == models.OrderPaid && payment.Status == models.PaymentCaptured &&
+			againErr != nil && againErr.Error() == "only pending orders can be paid" && purchases == 1
+		errMsg := ""
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to pay order: %v", err)
+		} else if !passed {
+			errMsg = "Expected the placed order to be paid once and counted as one purchase"
+		}
+		utils.RecordTest(t, "Payment - Pay Placed Order", passed, errMsg)
+	})
+
+	t.Run("No Cancel While Settling", func(t *testing.T) {
+		gateway.DrainWebhooks()
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 1)
+		order, _ := models.Checkout(utils.TestDB, user.UserID)
+		models.PayOrder(utils.TestDB, gateway, user.UserID, order.ID, models.FakeTokenDelayed)
+
+		_, cancelErr := models.CancelOrder(utils.TestDB, user.UserID, order.ID)
+		expired, _ := models.ExpirePendingOrders(utils.TestDB, time.Now().Add(time.Minute))
+		deliverWebhooks(gateway)
+		stored, _ := models.GetOrder(utils.TestDB, order.ID)
+
+		passed := cancelErr != nil && cancelErr.Error() == "order has a payment in progress" &&
+			expired == 0 && stored.Status == models.OrderPaid
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected an order with a pending capture to stay open until the webhook pays it"
+		}
+		utils.RecordTest(t, "Payment - No Cancel While Settling", passed, errMsg)
+	})
+}
+
+func TestPaymentWebhooks(t *testing.T) {
+	resetPaymentTables()
+	user, product := setupTestData()
+	gateway := models.NewFakeGateway("test-secret")
+	gateway.Duplicates = 2
+
+	models.AddToCart(utils.TestDB, user.UserID, product.ID, 1)
+	order, payment, _ := models.PayForCart(utils.TestDB, gateway, user.UserID, models.FakeTokenApprove)
+
+	t.Run("Duplicate Deliveries", func(t *testing.T) {
+		processed, duplicates, err := deliverWebhooks(gateway)
+		passed := err == nil && processed == 1 && duplicates == 2
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected one processed delivery and two duplicates"
+		}
+		utils.RecordTest(t, "Webhook - Duplicate Deliveries", passed, errMsg)
+	})
+
+	t.Run("Invalid Signature", func(t *testing.T) {
+		_, err := gateway.VerifyWebhook([]byte(`{"id":"evt_forged","type":"payment.refunded"}`), "bad")
+		passed := err != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected forged webhook to be rejected"
+		}
+		utils.RecordTest(t, "Webhook - Invalid Signature", passed, errMsg)
+	})
+
+	t.Run("Refund", func(t *testing.T) {
+		models.RegisterPaymentProvider(gateway)
+		refunded, err := models.RefundPayment(utils.TestDB, payment.ID)
+		_, duplicates, hookErr := deliverWebhooks(gateway)
+		_, againErr := models.RefundPayment(utils.TestDB, payment.ID)
+		stored, _ := models.GetOrder(utils.TestDB, order.ID)
+
+		// The refund webhook arrives after the refund was applied and changes nothing
+		passed := err == nil && hookErr == nil && refunded.Status == models.PaymentRefunded &&
+			stored.Status == models.OrderRefunded && againErr != nil && duplicates == 2
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected refunded payment and order, and a second refund to fail"
+		}
+		utils.RecordTest(t, "Webhook - Refund", passed, errMsg)
+	})
+}
+
+func TestPaymentConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		secret   string
+		valid    bool
+	}{
+		{"Disabled", "", "", true},
+		{"Fake With Secret", "fake", "webhook-secret", true},
+		{"Missing Secret", "fake", "", false},
+		{"Unknown Provider", "stripe", "webhook-secret", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := models.ValidatePaymentConfig(tt.provider, tt.secret)
+			passed := (err == nil) == tt.valid
+			errMsg := ""
+			if !passed {
+				errMsg = "Unexpected validation result for " + tt.name
+			}
+			utils.RecordTest(t, "Payment Config - "+tt.name, passed, errMsg)
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package cart_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+// Helper function to create a coupon promotion
+func createCoupon(code, discountType string, value float64) *models.Promotion {
+	promotion := &models.Promotion{
+		Name:         code + " promotion",
+		Code:         &code,
+		DiscountType: discountType,
+		Value:        value,
+	}
+	models.CreatePromotion(utils.TestDB, promotion)
+	return promotion
+}
+
+func TestPromotionValidation(t *testing.T) {
+	resetOrderTables()
+
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+	}{
+		{"Unknown Type", models.Promotion{Name: "Bad", DiscountType: "bogo", Value: 10}},
+		{"Over 100 Percent", models.Promotion{Name: "Bad", DiscountType: models.DiscountPercentage, Value: 120}},
+		{"Category Without Category", models.Promotion{Name: "Bad", DiscountType: models.DiscountFixed, Value: 5, Scope: models.PromotionScopeCategory}},
+		{"Ends Before Start", models.Promotion{
+			Name: "Bad", DiscountType: models.DiscountFixed, Value: 5,
+			StartsAt: timePtr(time.Now()), EndsAt: timePtr(time.Now().Add(-time.Hour)),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := models.CreatePromotion(utils.TestDB, &tt.promotion)
+			passed := err != nil
+			errMsg := ""
+			if !passed {
+				errMsg = "Expected the promotion to be rejected"
+			}
+			utils.RecordTest(t, "Promotions - Reject "+tt.name, passed, errMsg)
+		})
+	}
+
+	t.Run("Duplicate Code", func(t *testing.T) {
+		createCoupon("save10", models.DiscountPercentage, 10)
+		code := "SAVE10"
+		err := models.CreatePromotion(utils.TestDB, &models.Promotion{
+			Name: "Again", Code: &code, DiscountType: models.DiscountFixed, Value: 1,
+		})
+		passed := err != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected codes to be unique regardless of case"
+		}
+		utils.RecordTest(t, "Promotions - Duplicate Code", passed, errMsg)
+	})
+}
+
+func TestCartDiscounts(t *testing.T) {
+	resetOrderTables()
+	user, product := setupTestData()
+	owner := models.CartOwner{UserID: user.UserID}
+
+	// A second product in another category to exercise scoped rules
+	other := &models.Product{Name: "Other Product", Price: 50, Category: "Other Category", Stock: 100}
+	utils.TestDB.Create(other)
+
+	models.AddToCart(utils.TestDB, user.UserID, product.ID, 2) // 199.98
+	models.AddToCart(utils.TestDB, user.UserID, other.ID, 1)   // 50.00
+
+	t.Run("Automatic Category Promotion", func(t *testing.T) {
+		models.CreatePromotion(utils.TestDB, &models.Promotion{
+			Name:         "Other category sale",
+			DiscountType: models.DiscountPercentage,
+			Value:        20,
+			Scope:        models.PromotionScopeCategory,
+			Category:     "Other Category",
+		})
+
+		summary, err := models.GetCart(utils.TestDB, user.UserID)
+		passed := err == nil && len(summary.Discounts) == 1 && summary.Discounts[0].Amount == 10 &&
+			summary.Subtotal == 249.98 && summary.TotalPrice == 239.98
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected 20% off the 50.00 category item only"
+		}
+		utils.RecordTest(t, "Discounts - Category Promotion", passed, errMsg)
+	})
+
+	t.Run("Coupon Minimum Cart Value", func(t *testing.T) {
+		code := "BIGSPEND"
+		models.CreatePromotion(utils.TestDB, &models.Promotion{
+			Name: "Big spender", Code: &code, DiscountType: models.DiscountFixed, Value: 25, MinCartValue: 500,
+		})
+
+		_, err := models.ApplyCoupon(utils.TestDB, owner, "bigspend")
+		passed := err != nil && err.Error() == "cart total must be at least 500.00"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the minimum cart value to be enforced"
+		}
+		utils.RecordTest(t, "Discounts - Minimum Cart Value", passed, errMsg)
+	})
+
+	t.Run("Coupon Discount Line", func(t *testing.T) {
+		createCoupon("FIVEOFF", models.DiscountFixed, 5)
+
+		_, err := models.ApplyCoupon(utils.TestDB, owner, "fiveoff")
+		summary, _ := models.GetCart(utils.TestDB, user.UserID)
+		passed := err == nil && len(summary.Discounts) == 2 && summary.Discounts[1].Code == "FIVEOFF" &&
+			summary.DiscountTotal == 15 && summary.TotalPrice == 234.98
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the coupon line after the automatic promotion"
+		}
+		utils.RecordTest(t, "Discounts - Coupon Line", passed, errMsg)
+	})
+
+	t.Run("Checkout Redeems Coupon", func(t *testing.T) {
+		order, err := models.Checkout(utils.TestDB, user.UserID)
+
+		var promotion models.Promotion
+		utils.TestDB.Where("code = ?", "FIVEOFF").First(&promotion)
+		var coupons int64
+		utils.TestDB.Model(&models.CartCoupon{}).Count(&coupons)
+
+		passed := err == nil && order.Subtotal == 249.98 && order.Discount == 15 &&
+			order.TotalPrice == 234.98 && len(order.Discounts) == 2 &&
+			promotion.UsageCount == 1 && coupons == 0
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		} else if !passed {
+			errMsg = "Expected a discounted order, a redemption per promotion and the coupon consumed"
+		}
+		utils.RecordTest(t, "Discounts - Checkout Redeems", passed, errMsg)
+	})
+}
+
+func TestCouponLimits(t *testing.T) {
+	resetOrderTables()
+	user, product := setupTestData()
+	owner := models.CartOwner{UserID: user.UserID}
+
+	code := "ONCE"
+	models.CreatePromotion(utils.TestDB, &models.Promotion{
+		Name: "Once per customer", Code: &code, DiscountType: models.DiscountPercentage, Value: 10, PerUserLimit: 1,
+	})
+
+	t.Run("Per User Limit", func(t *testing.T) {
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 1)
+		models.ApplyCoupon(utils.TestDB, owner, code)
+		_, checkoutErr := models.Checkout(utils.TestDB, user.UserID)
+
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 1)
+		_, err := models.ApplyCoupon(utils.TestDB, owner, code)
+
+		passed := checkoutErr == nil && err != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the coupon to be refused on the second order"
+		}
+		utils.RecordTest(t, "Coupons - Per User Limit", passed, errMsg)
+	})
+
+	t.Run("Expired Coupon Blocks Checkout", func(t *testing.T) {
+		expiring := createCoupon("SOON", models.DiscountFixed, 1)
+		models.ApplyCoupon(utils.TestDB, owner, "SOON")
+		utils.TestDB.Model(expiring).Update("ends_at", time.Now().Add(-time.Minute))
+
+		summary, _ := models.GetCart(utils.TestDB, user.UserID)
+		warning := findWarning(summary, models.CartWarningCouponNotApplied)
+		_, err := models.Checkout(utils.TestDB, user.UserID)
+
+		passed := warning != nil && warning.Code == "SOON" && len(summary.Discounts) == 0 &&
+			err != nil && err.Error() == "coupon SOON cannot be applied"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected a coupon warning on the cart and checkout to refuse"
+		}
+		utils.RecordTest(t, "Coupons - Expired", passed, errMsg)
+	})
+
+	t.Run("Guest Coupon Follows Login", func(t *testing.T) {
+		models.RemoveCoupon(utils.TestDB, owner)
+		guest := models.CartOwner{GuestID: "guest-coupon"}
+		createCoupon("WELCOME", models.DiscountFixed, 2)
+		models.AddToCartFor(utils.TestDB, guest, product.ID, 1)
+		_, applyErr := models.ApplyCoupon(utils.TestDB, guest, "WELCOME")
+
+		_, err := models.MergeGuestCart(utils.TestDB, guest.GuestID, user.UserID, models.CartMergeSum)
+		summary, _ := models.GetCart(utils.TestDB, user.UserID)
+
+		passed := applyErr == nil && err == nil && len(summary.Discounts) == 1 && summary.Discounts[0].Code == "WELCOME"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the guest coupon to move to the user cart"
+		}
+		utils.RecordTest(t, "Coupons - Guest Merge", passed, errMsg)
+	})
+
+	t.Run("Limit Rechecked At Checkout", func(t *testing.T) {
+		models.RemoveCoupon(utils.TestDB, owner)
+		raceCode := "RACE"
+		race := &models.Promotion{
+			Name: "Race per customer", Code: &raceCode, DiscountType: models.DiscountFixed, Value: 1, PerUserLimit: 1,
+		}
+		models.CreatePromotion(utils.TestDB, race)
+		_, applyErr := models.ApplyCoupon(utils.TestDB, owner, raceCode)
+
+		// Another checkout redeems the coupon after it was applied to this cart
+		utils.TestDB.Create(&models.PromotionRedemption{
+			PromotionID: race.ID, OrderID: 999999, UserID: user.UserID, Name: race.Name, Code: raceCode, Amount: 1,
+		})
+		var ordersBefore int64
+		utils.TestDB.Model(&models.Order{}).Where("user_id = ?", user.UserID).Count(&ordersBefore)
+
+		_, err := models.Checkout(utils.TestDB, user.UserID)
+
+		var ordersAfter int64
+		utils.TestDB.Model(&models.Order{}).Where("user_id = ?", user.UserID).Count(&ordersAfter)
+		var stored models.Promotion
+		utils.TestDB.First(&stored, race.ID)
+
+		passed := applyErr == nil && err != nil && ordersAfter == ordersBefore && stored.UsageCount == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected checkout to refuse a coupon the user already redeemed elsewhere"
+		}
+		utils.RecordTest(t, "Coupons - Limit Rechecked At Checkout", passed, errMsg)
+	})
+}
+
+// Helper function to take the address of a time
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
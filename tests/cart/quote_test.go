@@ -0,0 +1,166 @@
+package cart_test
+
+import (
+	"testing"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+var testTaxTable = &models.TaxTable{
+	Rates: []models.TaxRate{
+		{Rate: 0.05},
+		{Country: "US", Rate: 0.06},
+		{Country: "US", Region: "CA", Rate: 0.0725},
+		{Country: "US", Category: "Groceries", Rate: 0},
+	},
+}
+
+var testShippingTable = &models.ShippingTable{
+	Rates: []models.ShippingRate{
+		{Country: "US", Method: models.ShippingWeight, Amount: 4, PerKg: 1.5, FreeOver: 300},
+		{Country: "CA", Method: models.ShippingFlat, Amount: 12.5},
+	},
+}
+
+func TestTaxTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  models.Address
+		category string
+		expected float64
+	}{
+		{"Default Rate", models.Address{Country: "FR"}, "Books", 5},
+		{"Country Rate", models.Address{Country: "US", Region: "NY"}, "Books", 6},
+		{"Region Beats Country", models.Address{Country: "US", Region: "CA"}, "Books", 7.25},
+		{"Category Exemption", models.Address{Country: "US", Region: "NY"}, "Groceries", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := []models.QuoteLine{{Category: tt.category, Quantity: 1, Subtotal: 100}}
+			quote, err := testTaxTable.CalculateTax(tt.address, lines, 0)
+			passed := err == nil && quote.Total == tt.expected
+			errMsg := ""
+			if !passed {
+				errMsg = "Unexpected tax total"
+			}
+			utils.RecordTest(t, "Tax Table - "+tt.name, passed, errMsg)
+		})
+	}
+
+	t.Run("Taxed On Discounted Amount", func(t *testing.T) {
+		lines := []models.QuoteLine{{Category: "Books", Quantity: 1, Subtotal: 100, Discount: 20}}
+		quote, _ := testTaxTable.CalculateTax(models.Address{Country: "FR"}, lines, 0)
+		passed := len(quote.Lines) == 1 && quote.Lines[0].Taxable == 80 && quote.Total == 4
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected tax on the amount after discounts"
+		}
+		utils.RecordTest(t, "Tax Table - Discounted Amount", passed, errMsg)
+	})
+}
+
+func TestShippingTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  models.Address
+		subtotal float64
+		expected float64
+		free     bool
+	}{
+		{"Weight Based", models.Address{Country: "US"}, 100, 7, false}, // 4 + 2kg * 1.5
+		{"Free Over Threshold", models.Address{Country: "US"}, 300, 0, true},
+		{"Flat Rate", models.Address{Country: "CA"}, 100, 12.5, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := []models.QuoteLine{{Quantity: 2, Weight: 1, Subtotal: tt.subtotal}}
+			quote, err := testShippingTable.CalculateShipping(tt.address, lines)
+			passed := err == nil && quote.Amount == tt.expected && quote.FreeShipping == tt.free
+			errMsg := ""
+			if !passed {
+				errMsg = "Unexpected shipping charge"
+			}
+			utils.RecordTest(t, "Shipping Table - "+tt.name, passed, errMsg)
+		})
+	}
+
+	t.Run("Unserved Address", func(t *testing.T) {
+		lines := []models.QuoteLine{{Quantity: 1, Subtotal: 10}}
+		_, err := testShippingTable.CalculateShipping(models.Address{Country: "FR"}, lines)
+		passed := err != nil && err.Error() == "shipping not available to this address"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected addresses without a shipping row to be refused"
+		}
+		utils.RecordTest(t, "Shipping Table - Unserved Address", passed, errMsg)
+	})
+}
+
+func TestValidateShippingAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address models.Address
+		valid   bool
+	}{
+		{"Country Only", models.Address{Country: "us"}, true},
+		{"Full Address", models.Address{Country: "US", Region: "CA", PostalCode: "94105"}, true},
+		{"Missing", models.Address{}, false},
+		{"Blank Country", models.Address{Country: "  ", Region: "CA"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := models.ValidateShippingAddress(tt.address)
+			passed := (err == nil) == tt.valid
+			errMsg := ""
+			if !passed {
+				errMsg = "Unexpected validation result for " + tt.name
+			}
+			utils.RecordTest(t, "Shipping Address - "+tt.name, passed, errMsg)
+		})
+	}
+}
+
+func TestCartQuote(t *testing.T) {
+	resetOrderTables()
+	user, product := setupTestData()
+	utils.TestDB.Model(&models.Product{}).Where("id = ?", product.ID).Update("weight", 0.5)
+
+	models.DefaultTaxCalculator = testTaxTable
+	models.DefaultShippingCalculator = testShippingTable
+	defer func() {
+		models.DefaultTaxCalculator = &models.TaxTable{}
+		models.DefaultShippingCalculator = &models.ShippingTable{}
+	}()
+
+	models.AddToCart(utils.TestDB, user.UserID, product.ID, 2) // 199.98, 1kg
+	address := models.Address{Country: "us", Region: "ca"}
+
+	t.Run("Full Breakdown", func(t *testing.T) {
+		quote, err := models.QuoteCart(utils.TestDB, models.CartOwner{UserID: user.UserID}, address)
+		// Tax 7.25% of 199.98 = 14.50, shipping 4 + 1kg * 1.5 = 5.50
+		passed := err == nil && quote.Subtotal == 199.98 && quote.Shipping.Amount == 5.5 &&
+			quote.Tax.Total == 14.5 && quote.Total == 219.98 && quote.Address.Country == "US"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected subtotal, shipping and tax to add up to 219.98"
+		}
+		utils.RecordTest(t, "Cart Quote - Full Breakdown", passed, errMsg)
+	})
+
+	t.Run("Checkout Charges Quote", func(t *testing.T) {
+		order, err := models.CheckoutTo(utils.TestDB, user.UserID, address)
+		passed := err == nil && order.Shipping == 5.5 && order.Tax == 14.5 &&
+			order.TotalPrice == 219.98 && order.ShippingAddress.Region == "CA"
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		} else if !passed {
+			errMsg = "Expected the order to charge what the quote showed"
+		}
+		utils.RecordTest(t, "Cart Quote - Checkout Charges Quote", passed, errMsg)
+	})
+}
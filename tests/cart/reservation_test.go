@@ -0,0 +1,100 @@
+package cart_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+func TestStockReservations(t *testing.T) {
+	resetOrderTables()
+	user, product := setupTestData()
+
+	// Second shopper competing for the same stock
+	other := &models.User{Email: "other@example.com", Password: "SecureP@ss123"}
+	utils.TestDB.Create(other)
+	utils.TestDB.Model(&models.Product{}).Where("id = ?", product.ID).Update("stock", 5)
+
+	t.Run("Reserve On Add", func(t *testing.T) {
+		err := models.AddToCart(utils.TestDB, user.UserID, product.ID, 4)
+		available, availErr := models.GetAvailableStock(utils.TestDB, product.ID)
+		passed := err == nil && availErr == nil && available == 1
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected 1 unit available after reserving 4 of 5"
+		}
+		utils.RecordTest(t, "Reservation - Reserve On Add", passed, errMsg)
+	})
+
+	t.Run("Block Competing Cart", func(t *testing.T) {
+		err := models.AddToCart(utils.TestDB, other.UserID, product.ID, 2)
+		passed := err != nil && err.Error() == "insufficient stock"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected 'insufficient stock' for the second cart"
+		}
+		utils.RecordTest(t, "Reservation - Block Competing Cart", passed, errMsg)
+	})
+
+	t.Run("Quantity Update Rechecks Stock", func(t *testing.T) {
+		var item models.CartItem
+		utils.TestDB.Where("user_id = ?", user.UserID).First(&item)
+
+		_, tooMany := models.SetCartItemQuantity(utils.TestDB, user.UserID, item.ID, 6)
+		_, fits := models.SetCartItemQuantity(utils.TestDB, user.UserID, item.ID, 5)
+		passed := tooMany != nil && fits == nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected 6 to be rejected and 5 to fit"
+		}
+		utils.RecordTest(t, "Reservation - Quantity Update", passed, errMsg)
+	})
+
+	t.Run("Release On Remove", func(t *testing.T) {
+		var item models.CartItem
+		utils.TestDB.Where("user_id = ?", user.UserID).First(&item)
+		err := models.RemoveFromCart(utils.TestDB, user.UserID, item.ID)
+		available, _ := models.GetAvailableStock(utils.TestDB, product.ID)
+		passed := err == nil && available == 5
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected all 5 units available after removal"
+		}
+		utils.RecordTest(t, "Reservation - Release On Remove", passed, errMsg)
+	})
+
+	t.Run("Sweep Expired", func(t *testing.T) {
+		models.AddToCart(utils.TestDB, other.UserID, product.ID, 3)
+		utils.TestDB.Model(&models.StockReservation{}).
+			Where("product_id = ?", product.ID).
+			Update("expires_at", time.Now().Add(-time.Minute))
+
+		// Expired holds stop counting even before the sweeper runs
+		availableBefore, _ := models.GetAvailableStock(utils.TestDB, product.ID)
+		released, err := models.ReleaseExpiredReservations(utils.TestDB)
+		passed := err == nil && availableBefore == 5 && released == 1
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the expired reservation to be ignored and swept"
+		}
+		utils.RecordTest(t, "Reservation - Sweep Expired", passed, errMsg)
+	})
+
+	t.Run("Product Availability", func(t *testing.T) {
+		models.AddToCart(utils.TestDB, user.UserID, product.ID, 2)
+		var current models.Product
+		utils.TestDB.First(&current, product.ID)
+		products := []models.Product{current}
+		err := models.FillAvailableToSell(utils.TestDB, products)
+		response, _ := models.GetProductByID(utils.TestDB, int(product.ID))
+		passed := err == nil && products[0].AvailableToSell != nil && *products[0].AvailableToSell == 3 &&
+			response.AvailableToSell == 3
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected available_to_sell of 3 on product responses"
+		}
+		utils.RecordTest(t, "Reservation - Product Availability", passed, errMsg)
+	})
+}
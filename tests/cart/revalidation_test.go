@@ -0,0 +1,100 @@
+package cart_test
+
+import (
+	"testing"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+// Helper function to find a warning of the given type
+func findWarning(summary *models.CartSummary, warningType string) *models.CartWarning {
+	for i := range summary.Warnings {
+		if summary.Warnings[i].Type == warningType {
+			return &summary.Warnings[i]
+		}
+	}
+	return nil
+}
+
+func TestCartRevalidation(t *testing.T) {
+	resetOrderTables()
+	user, product := setupTestData()
+	owner := models.CartOwner{UserID: user.UserID}
+	models.AddToCart(utils.TestDB, user.UserID, product.ID, 3)
+
+	t.Run("Price Snapshot", func(t *testing.T) {
+		summary, err := models.GetCart(utils.TestDB, user.UserID)
+		passed := err == nil && len(summary.Items) == 1 &&
+			summary.Items[0].PriceAtAdd == product.Price && len(summary.Warnings) == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the add-time price and no warnings"
+		}
+		utils.RecordTest(t, "Cart Revalidation - Price Snapshot", passed, errMsg)
+	})
+
+	t.Run("Price Increase Warning", func(t *testing.T) {
+		utils.TestDB.Model(&models.Product{}).Where("id = ?", product.ID).Update("price", 119.99)
+
+		summary, err := models.GetCart(utils.TestDB, user.UserID)
+		warning := findWarning(summary, models.CartWarningPriceIncreased)
+		passed := err == nil && warning != nil && warning.OldPrice == 99.99 && warning.NewPrice == 119.99
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected a price_increased warning from 99.99 to 119.99"
+		}
+		utils.RecordTest(t, "Cart Revalidation - Price Increase", passed, errMsg)
+	})
+
+	t.Run("Checkout Blocked Until Accepted", func(t *testing.T) {
+		_, err := models.Checkout(utils.TestDB, user.UserID)
+		passed := err != nil && err.Error() == "price changed for "+product.Name
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected checkout to refuse an unaccepted price change"
+		}
+		utils.RecordTest(t, "Cart Revalidation - Checkout Blocked", passed, errMsg)
+	})
+
+	t.Run("Insufficient Stock Warning", func(t *testing.T) {
+		utils.TestDB.Model(&models.Product{}).Where("id = ?", product.ID).Update("stock", 2)
+
+		summary, err := models.GetCart(utils.TestDB, user.UserID)
+		warning := findWarning(summary, models.CartWarningInsufficientStock)
+		passed := err == nil && warning != nil && warning.Requested == 3 &&
+			warning.Available != nil && *warning.Available == 2
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected an insufficient_stock warning for 3 requested, 2 available"
+		}
+		utils.RecordTest(t, "Cart Revalidation - Insufficient Stock", passed, errMsg)
+	})
+
+	t.Run("Accept Changes", func(t *testing.T) {
+		summary, err := models.RevalidateCart(utils.TestDB, owner, nil)
+		passed := err == nil && len(summary.Warnings) == 0 && len(summary.Items) == 1 &&
+			summary.Items[0].Quantity == 2 && summary.Items[0].PriceAtAdd == 119.99
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the new price accepted and quantity lowered to 2"
+		}
+		utils.RecordTest(t, "Cart Revalidation - Accept Changes", passed, errMsg)
+	})
+
+	t.Run("Deleted Product", func(t *testing.T) {
+		utils.TestDB.Delete(&models.Product{}, product.ID)
+
+		summary, err := models.GetCart(utils.TestDB, user.UserID)
+		warning := findWarning(summary, models.CartWarningProductDeleted)
+		revalidated, revalidateErr := models.RevalidateCart(utils.TestDB, owner, []uint{summary.Items[0].ItemID})
+
+		passed := err == nil && warning != nil && summary.TotalItems == 0 &&
+			revalidateErr == nil && len(revalidated.Items) == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected a product_deleted warning and the item removed on revalidation"
+		}
+		utils.RecordTest(t, "Cart Revalidation - Deleted Product", passed, errMsg)
+	})
+}
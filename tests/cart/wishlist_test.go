@@ -0,0 +1,229 @@
+package cart_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+// recordingWishlistNotifier keeps the alerts it was asked to send
+type recordingWishlistNotifier struct {
+	alerts []models.WishlistAlert
+}
+
+func (n *recordingWishlistNotifier) NotifyWishlistAlert(alert *models.WishlistAlert) error {
+	n.alerts = append(n.alerts, *alert)
+	return nil
+}
+
+func resetWishlistTables() {
+	utils.TruncateTable("wishlist_items")
+	utils.TruncateTable("wishlists")
+	utils.TruncateTable("user_interactions")
+	utils.TruncateTable("guest_interactions")
+	resetOrderTables()
+}
+
+func TestWishlists(t *testing.T) {
+	resetWishlistTables()
+	user, product := setupTestData()
+
+	var wishlist *models.Wishlist
+
+	t.Run("Create Wishlist", func(t *testing.T) {
+		var err error
+		wishlist, err = models.CreateWishlist(utils.TestDB, user.UserID, "Birthday")
+		_, dupErr := models.CreateWishlist(utils.TestDB, user.UserID, "Birthday")
+		_, reservedErr := models.CreateWishlist(utils.TestDB, user.UserID, models.SaveForLaterName)
+
+		passed := err == nil && dupErr != nil && dupErr.Error() == "wishlist Birthday already exists" &&
+			reservedErr != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected unique wishlist names and a reserved save-for-later name"
+		}
+		utils.RecordTest(t, "Wishlists - Create", passed, errMsg)
+	})
+
+	t.Run("Add And Move To Cart", func(t *testing.T) {
+		addErr := models.AddToWishlist(utils.TestDB, user.UserID, wishlist.ID, product.ID, 2)
+		list, _ := models.GetWishlist(utils.TestDB, user.UserID, wishlist.ID)
+		added := list != nil && len(list.Items) == 1 && list.Items[0].Quantity == 2 && list.Items[0].InStock
+
+		moveErr := models.MoveWishlistItemToCart(utils.TestDB, user.UserID, wishlist.ID, product.ID, 0)
+		cart, _ := models.GetCart(utils.TestDB, user.UserID)
+		list, _ = models.GetWishlist(utils.TestDB, user.UserID, wishlist.ID)
+
+		passed := addErr == nil && added && moveErr == nil &&
+			len(cart.Items) == 1 && cart.Items[0].Quantity == 2 && len(list.Items) == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the wishlist item to move into the cart"
+		}
+		utils.RecordTest(t, "Wishlists - Move To Cart", passed, errMsg)
+	})
+
+	t.Run("Save For Later", func(t *testing.T) {
+		cart, _ := models.GetCart(utils.TestDB, user.UserID)
+		list, err := models.SaveForLater(utils.TestDB, user.UserID, cart.Items[0].ItemID)
+
+		cart, _ = models.GetCart(utils.TestDB, user.UserID)
+		saved, _ := models.GetWishlist(utils.TestDB, user.UserID, list.ID)
+		deleteErr := models.DeleteWishlist(utils.TestDB, user.UserID, list.ID)
+
+		passed := err == nil && len(cart.Items) == 0 && saved.Kind == models.WishlistKindSaveForLater &&
+			len(saved.Items) == 1 && saved.Items[0].Quantity == 2 && deleteErr != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the cart item on a save-for-later list that cannot be deleted"
+		}
+		utils.RecordTest(t, "Wishlists - Save For Later", passed, errMsg)
+	})
+
+	t.Run("Other Users Lists", func(t *testing.T) {
+		other := &models.User{Email: "other@example.com", Password: "SecureP@ss123"}
+		utils.TestDB.Create(other)
+
+		_, getErr := models.GetWishlist(utils.TestDB, other.UserID, wishlist.ID)
+		addErr := models.AddToWishlist(utils.TestDB, other.UserID, wishlist.ID, product.ID, 1)
+
+		passed := getErr != nil && addErr != nil && addErr.Error() == "wishlist not found"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected another user's wishlist to be hidden"
+		}
+		utils.RecordTest(t, "Wishlists - Isolation", passed, errMsg)
+	})
+}
+
+func TestWishlistAlerts(t *testing.T) {
+	resetWishlistTables()
+	user, product := setupTestData()
+	wishlist, _ := models.CreateWishlist(utils.TestDB, user.UserID, "Watching")
+	models.AddToWishlist(utils.TestDB, user.UserID, wishlist.ID, product.ID, 1)
+
+	setProduct := func(column string, value interface{}) {
+		utils.TestDB.Model(&models.Product{}).Where("id = ?", product.ID).Update(column, value)
+	}
+
+	t.Run("Price Drop", func(t *testing.T) {
+		notifier := &recordingWishlistNotifier{}
+		setProduct("price", 79.99)
+		sent, err := models.CheckWishlistAlerts(utils.TestDB, notifier)
+		again, _ := models.CheckWishlistAlerts(utils.TestDB, notifier)
+
+		passed := err == nil && sent == 1 && again == 0 && len(notifier.alerts) == 1 &&
+			notifier.alerts[0].Type == models.WishlistAlertPriceDrop &&
+			notifier.alerts[0].OldPrice == 99.99 && notifier.alerts[0].NewPrice == 79.99 &&
+			notifier.alerts[0].Email == user.Email
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected one price drop alert"
+		}
+		utils.RecordTest(t, "Wishlist Alerts - Price Drop", passed, errMsg)
+	})
+
+	t.Run("Price Rise Is Silent", func(t *testing.T) {
+		notifier := &recordingWishlistNotifier{}
+		setProduct("price", 89.99)
+		sent, err := models.CheckWishlistAlerts(utils.TestDB, notifier)
+
+		passed := err == nil && sent == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected no alert when the price goes up"
+		}
+		utils.RecordTest(t, "Wishlist Alerts - Price Rise", passed, errMsg)
+	})
+
+	t.Run("Back In Stock", func(t *testing.T) {
+		notifier := &recordingWishlistNotifier{}
+		setProduct("stock", 0)
+		soldOut, _ := models.CheckWishlistAlerts(utils.TestDB, notifier)
+
+		setProduct("stock", 5)
+		sent, err := models.CheckWishlistAlerts(utils.TestDB, notifier)
+
+		passed := err == nil && soldOut == 0 && sent == 1 &&
+			notifier.alerts[0].Type == models.WishlistAlertBackInStock && notifier.alerts[0].Stock == 5
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected one back in stock alert after the product sold out"
+		}
+		utils.RecordTest(t, "Wishlist Alerts - Back In Stock", passed, errMsg)
+	})
+
+	t.Run("Price Drop While Sold Out", func(t *testing.T) {
+		notifier := &recordingWishlistNotifier{}
+		setProduct("stock", 0)
+		models.CheckWishlistAlerts(utils.TestDB, notifier)
+		setProduct("price", 69.99)
+		soldOut, _ := models.CheckWishlistAlerts(utils.TestDB, notifier)
+
+		setProduct("stock", 5)
+		sent, err := models.CheckWishlistAlerts(utils.TestDB, notifier)
+
+		drop := false
+		for _, alert := range notifier.alerts {
+			if alert.Type == models.WishlistAlertPriceDrop && alert.OldPrice == 89.99 && alert.NewPrice == 69.99 {
+				drop = true
+			}
+		}
+		passed := err == nil && soldOut == 0 && sent == 2 && drop
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the price drop to be announced with the restock"
+		}
+		utils.RecordTest(t, "Wishlist Alerts - Price Drop While Sold Out", passed, errMsg)
+	})
+}
+
+func TestWishlistSignals(t *testing.T) {
+	resetWishlistTables()
+	user, product := setupTestData()
+	paired := &models.Product{Name: "Paired Product", Price: 49.99, Category: "Other Category", Stock: 10}
+	utils.TestDB.Create(paired)
+
+	wishlist, _ := models.CreateWishlist(utils.TestDB, user.UserID, "Ideas")
+	models.AddToWishlist(utils.TestDB, user.UserID, wishlist.ID, product.ID, 1)
+	models.AddToWishlist(utils.TestDB, user.UserID, wishlist.ID, paired.ID, 1)
+
+	t.Run("Wishlists Count As Co-Views", func(t *testing.T) {
+		recs, err := models.GetWeightedCollaborativeRecommendations(utils.TestDB, product.ID, 5,
+			models.CoViewWeights{User: 1.0, Guest: 0.5, Wishlist: 1.5})
+
+		passed := err == nil && len(recs) > 0 && recs[0].ID == paired.ID
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the product wishlisted together to be recommended"
+		}
+		utils.RecordTest(t, "Wishlist Signals - Co-Views", passed, errMsg)
+	})
+
+	t.Run("Similarity Weights Per Product", func(t *testing.T) {
+		// The second user views the product but wishlists its pair, so the pair is not a perfect match
+		other := &models.User{Email: "weights@example.com", Password: "SecureP@ss123"}
+		utils.TestDB.Create(other)
+		models.TrackUserView(utils.TestDB, other.UserID, product.ID)
+		otherList, _ := models.CreateWishlist(utils.TestDB, other.UserID, "Ideas")
+		models.AddToWishlist(utils.TestDB, other.UserID, otherList.ID, paired.ID, 1)
+
+		config := models.DefaultSimilarityConfig()
+		config.Weights = models.CoViewWeights{User: 1.0, Guest: 0.5, Wishlist: 1.5}
+		err := models.ComputeProductSimilarities(utils.TestDB, config)
+		similar, _ := models.GetSimilarProducts(utils.TestDB, product.ID, 5)
+
+		// Product weights (1.5, 1.0) against pair weights (1.5, 1.5)
+		expected := (1.5*1.5 + 1.0*1.5) / math.Sqrt((1.5*1.5+1.0*1.0)*(1.5*1.5+1.5*1.5))
+		passed := err == nil && len(similar) == 1 && similar[0].ID == paired.ID &&
+			math.Abs(similar[0].Relevance-expected) < 0.001
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected a cosine score of %.4f from per-product weights, got %+v", expected, similar)
+		}
+		utils.RecordTest(t, "Wishlist Signals - Similarity Weights", passed, errMsg)
+	})
+}
@@ -0,0 +1,99 @@
+package events_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+func TestMain(m *testing.M) {
+	utils.SetupTestDB()
+	code := m.Run()
+	utils.PrintReport()
+	utils.CleanupTestDB()
+	os.Exit(code)
+}
+
+func TestRecordEvent(t *testing.T) {
+	utils.TruncateTable("events")
+	utils.TruncateTable("products")
+
+	product := &models.Product{
+		Name:        "Event Product",
+		Description: "Tracked product",
+		Price:       49.99,
+		Category:    "Accessories",
+		Stock:       10,
+	}
+	utils.TestDB.Create(product)
+
+	t.Run("Valid Guest Event", func(t *testing.T) {
+		event := &models.Event{
+			Type:       models.EventAddToCart,
+			GuestID:    "guest-events",
+			ProductID:  &product.ID,
+			Properties: models.EventProperties{"quantity": 2},
+		}
+		err := models.RecordEvent(utils.TestDB, event)
+		passed := err == nil && event.ID != 0
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		utils.RecordTest(t, "Events - Valid Guest Event", passed, errMsg)
+	})
+
+	t.Run("Missing Visitor", func(t *testing.T) {
+		err := models.RecordEvent(utils.TestDB, &models.Event{
+			Type:      models.EventClick,
+			ProductID: &product.ID,
+		})
+		passed := err != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected error for event without user or guest"
+		}
+		utils.RecordTest(t, "Events - Missing Visitor", passed, errMsg)
+	})
+
+	t.Run("Invalid Schema", func(t *testing.T) {
+		err := models.RecordEvent(utils.TestDB, &models.Event{
+			Type:    models.EventSearch,
+			GuestID: "guest-events",
+		})
+		passed := err != nil && err.Error() == "search event requires a query property"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected search schema validation error"
+		}
+		utils.RecordTest(t, "Events - Invalid Schema", passed, errMsg)
+	})
+
+	t.Run("Unknown Product", func(t *testing.T) {
+		missing := uint(9999)
+		err := models.RecordEvent(utils.TestDB, &models.Event{
+			Type:      models.EventView,
+			GuestID:   "guest-events",
+			ProductID: &missing,
+		})
+		passed := err != nil && err.Error() == "product not found"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected 'product not found' error"
+		}
+		utils.RecordTest(t, "Events - Unknown Product", passed, errMsg)
+	})
+
+	t.Run("Event Counts", func(t *testing.T) {
+		counts, err := models.GetEventCounts(utils.TestDB)
+		passed := err == nil && len(counts) == 1 &&
+			counts[0].Type == models.EventAddToCart && counts[0].Count == 1
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected a single add_to_cart event"
+		}
+		utils.RecordTest(t, "Events - Counts", passed, errMsg)
+	})
+}
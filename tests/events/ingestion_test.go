@@ -0,0 +1,158 @@
+package events_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+func TestIngestionPipeline(t *testing.T) {
+	utils.TruncateTable("guest_interactions")
+	utils.TruncateTable("trending_products")
+	utils.TruncateTable("product_view_buckets")
+	utils.TruncateTable("products")
+
+	product := &models.Product{
+		Name:        "Buffered Product",
+		Description: "Viewed through the pipeline",
+		Price:       19.99,
+		Category:    "Accessories",
+		Stock:       10,
+	}
+	utils.TestDB.Create(product)
+
+	t.Run("Flush On Stop", func(t *testing.T) {
+		models.StartIngestion(utils.TestDB, models.IngestionConfig{
+			QueueSize:     100,
+			Workers:       1,
+			BatchSize:     10,
+			FlushInterval: time.Minute,
+		})
+
+		models.TrackGuestView(utils.TestDB, "guest-a", product.ID)
+		models.TrackGuestView(utils.TestDB, "guest-b", product.ID)
+		models.TrackGuestView(utils.TestDB, "guest-c", product.ID)
+
+		stats, running := models.GetIngestionStats()
+		models.StopIngestion()
+
+		var views int64
+		utils.TestDB.Model(&models.GuestInteraction{}).Where("product_id = ?", product.ID).Count(&views)
+
+		var trending models.TrendingProductDB
+		utils.TestDB.Where("product_id = ?", product.ID).First(&trending)
+
+		passed := running && stats.Enqueued == 3 && views == 3 && trending.TotalViews == 3
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected 3 buffered views to be written on stop"
+		}
+		utils.RecordTest(t, "Ingestion - Flush On Stop", passed, errMsg)
+	})
+
+	t.Run("Bucket By View Time", func(t *testing.T) {
+		var view models.GuestInteraction
+		utils.TestDB.Where("guest_id = ?", "guest-a").First(&view)
+
+		var bucket models.ProductViewBucket
+		utils.TestDB.Where("product_id = ?", product.ID).First(&bucket)
+
+		passed := bucket.Views == 3 && bucket.BucketStart.Equal(view.ViewedAt.Truncate(models.TrendingBucketSize))
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the views in the bucket of their view time"
+		}
+		utils.RecordTest(t, "Ingestion - Bucket By View Time", passed, errMsg)
+	})
+
+	t.Run("Concurrent Batches", func(t *testing.T) {
+		utils.TruncateTable("guest_interactions")
+		utils.TruncateTable("trending_products")
+
+		products := []models.Product{
+			{Name: "Buffered A", Price: 1, Category: "Accessories", Stock: 10},
+			{Name: "Buffered B", Price: 1, Category: "Accessories", Stock: 10},
+			{Name: "Buffered C", Price: 1, Category: "Accessories", Stock: 10},
+		}
+		utils.TestDB.Create(&products)
+
+		models.StartIngestion(utils.TestDB, models.IngestionConfig{
+			QueueSize:     200,
+			Workers:       4,
+			BatchSize:     5,
+			FlushInterval: 10 * time.Millisecond,
+		})
+
+		// Batches touch the same products in different orders
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 15; i++ {
+					product := products[(g+i)%len(products)]
+					models.TrackGuestView(utils.TestDB, fmt.Sprintf("guest-%d-%d", g, i), product.ID)
+				}
+			}(g)
+		}
+		wg.Wait()
+		models.StopIngestion()
+
+		var views, total int64
+		utils.TestDB.Model(&models.GuestInteraction{}).Count(&views)
+		utils.TestDB.Model(&models.TrendingProductDB{}).Select("COALESCE(SUM(total_views), 0)").Scan(&total)
+
+		passed := views == 60 && total == 60
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected all 60 views written and counted, got %d views and %d counted", views, total)
+		}
+		utils.RecordTest(t, "Ingestion - Concurrent Batches", passed, errMsg)
+	})
+
+	t.Run("Repeat User Views Kept", func(t *testing.T) {
+		utils.TruncateTable("user_interactions")
+		user := &models.User{Email: "ingestion@example.com", Password: "SecureP@ss123"}
+		models.CreateUser(utils.TestDB, user)
+
+		models.StartIngestion(utils.TestDB, models.IngestionConfig{
+			QueueSize:     10,
+			Workers:       1,
+			BatchSize:     10,
+			FlushInterval: time.Minute,
+		})
+		models.TrackUserView(utils.TestDB, user.UserID, product.ID)
+		time.Sleep(1100 * time.Millisecond) // Views in the same second share a key
+		models.TrackUserView(utils.TestDB, user.UserID, product.ID)
+		models.StopIngestion()
+
+		var views []models.UserInteraction
+		utils.TestDB.Where("user_id = ? AND product_id = ?", user.UserID, product.ID).Order("viewed_at").Find(&views)
+
+		passed := len(views) == 2 && views[1].ViewedAt.After(views[0].ViewedAt)
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected both views of the product to be kept, got %d", len(views))
+		}
+		utils.RecordTest(t, "Ingestion - Repeat User Views Kept", passed, errMsg)
+	})
+
+	t.Run("Synchronous Fallback", func(t *testing.T) {
+		err := models.TrackGuestView(utils.TestDB, "guest-sync", product.ID)
+		_, running := models.GetIngestionStats()
+
+		var views int64
+		utils.TestDB.Model(&models.GuestInteraction{}).Where("guest_id = ?", "guest-sync").Count(&views)
+
+		passed := err == nil && !running && views == 1
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected view to be written synchronously without a pipeline"
+		}
+		utils.RecordTest(t, "Ingestion - Synchronous Fallback", passed, errMsg)
+	})
+}
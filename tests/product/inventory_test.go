@@ -0,0 +1,133 @@
+package product_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+func TestInventoryLedger(t *testing.T) {
+	utils.TruncateTable("inventory_movements")
+	utils.TruncateTable("products")
+
+	product := &models.Product{
+		Name:        "Ledger Product",
+		Description: "Tracked stock",
+		Price:       10.00,
+		Category:    "Test Category",
+		Stock:       20,
+	}
+	if err := models.CreateProduct(utils.TestDB, product); err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+
+	t.Run("Opening Balance", func(t *testing.T) {
+		history, err := models.GetStockHistory(utils.TestDB, product.ID, 10)
+		passed := err == nil && len(history) == 1 &&
+			history[0].Type == models.MovementReceipt && history[0].BalanceAfter == 20
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected an initial receipt of 20"
+		}
+		utils.RecordTest(t, "Inventory - Opening Balance", passed, errMsg)
+	})
+
+	t.Run("Post Movements", func(t *testing.T) {
+		actorID := uint(7)
+		_, saleErr := models.RecordStockMovement(utils.TestDB, models.StockMovementInput{
+			ProductID: product.ID, Type: models.MovementSale, Quantity: -5, Reason: "counter sale",
+		})
+		movement, returnErr := models.RecordStockMovement(models.WithStockActor(utils.TestDB, actorID), models.StockMovementInput{
+			ProductID: product.ID, Type: models.MovementReturn, Quantity: 2, Reason: "damaged box",
+		})
+
+		var updated models.Product
+		utils.TestDB.First(&updated, product.ID)
+
+		passed := saleErr == nil && returnErr == nil && updated.Stock == 17 &&
+			movement.BalanceAfter == 17 && movement.ActorID != nil && *movement.ActorID == actorID
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected stock 17 with the return attributed to the actor"
+		}
+		utils.RecordTest(t, "Inventory - Post Movements", passed, errMsg)
+	})
+
+	t.Run("Reject Invalid Movements", func(t *testing.T) {
+		_, wrongSign := models.RecordStockMovement(utils.TestDB, models.StockMovementInput{
+			ProductID: product.ID, Type: models.MovementReceipt, Quantity: -1,
+		})
+		_, oversell := models.RecordStockMovement(utils.TestDB, models.StockMovementInput{
+			ProductID: product.ID, Type: models.MovementAdjustment, Quantity: -100,
+		})
+		passed := wrongSign != nil && oversell != nil && oversell.Error() == "insufficient stock"
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected sign and stock validation errors"
+		}
+		utils.RecordTest(t, "Inventory - Reject Invalid Movements", passed, errMsg)
+	})
+
+	t.Run("Update Records Adjustment", func(t *testing.T) {
+		var current models.Product
+		utils.TestDB.First(&current, product.ID)
+		current.Stock = 30
+		err := models.UpdateProduct(utils.TestDB, &current)
+
+		history, _ := models.GetStockHistory(utils.TestDB, product.ID, 1)
+		passed := err == nil && len(history) == 1 &&
+			history[0].Type == models.MovementAdjustment && history[0].Quantity == 13
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected an adjustment of +13 after the product update"
+		}
+		utils.RecordTest(t, "Inventory - Update Records Adjustment", passed, errMsg)
+	})
+
+	t.Run("Reconcile Drift", func(t *testing.T) {
+		// Overwrite stock behind the ledger's back
+		utils.TestDB.Model(&models.Product{}).Where("id = ?", product.ID).Update("stock", 25)
+
+		drifted, err := models.ReconcileStock(utils.TestDB)
+		_, fixErr := models.FixStockDrift(utils.TestDB)
+		remaining, _ := models.ReconcileStock(utils.TestDB)
+
+		passed := err == nil && fixErr == nil && len(drifted) == 1 &&
+			drifted[0].Drift == -5 && len(remaining) == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected a drift of -5 that is cleared by a correction"
+		}
+		utils.RecordTest(t, "Inventory - Reconcile Drift", passed, errMsg)
+	})
+
+	t.Run("Concurrent Drift Fixes", func(t *testing.T) {
+		utils.TestDB.Model(&models.Product{}).Where("id = ?", product.ID).Update("stock", 40)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				models.FixStockDrift(utils.TestDB)
+			}()
+		}
+		wg.Wait()
+
+		var corrections int64
+		utils.TestDB.Model(&models.InventoryMovement{}).
+			Where("product_id = ? AND type = ? AND quantity = ?", product.ID, models.MovementCorrection, 15).
+			Count(&corrections)
+		remaining, _ := models.ReconcileStock(utils.TestDB)
+
+		passed := corrections == 1 && len(remaining) == 0
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected one +15 correction and no drift, got %d corrections and %d drifted", corrections, len(remaining))
+		}
+		utils.RecordTest(t, "Inventory - Concurrent Drift Fixes", passed, errMsg)
+	})
+}
@@ -1,11 +1,17 @@
 package product_test
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/routes"
 	"github.com/amcishara/web_Tracking_system/tests/utils"
+	"github.com/gin-gonic/gin"
 )
 
 func TestMain(m *testing.M) {
@@ -199,3 +205,59 @@ func TestUpdateProduct(t *testing.T) {
 		utils.RecordTest(t, "Product - Update Duplicate Name", passed, errMsg)
 	})
 }
+
+func TestAdminUpdateProductWeight(t *testing.T) {
+	utils.TruncateTable("products")
+	utils.TruncateTable("users")
+	utils.TruncateTable("sessions")
+	utils.TruncateTable("refresh_tokens")
+
+	admin := &models.User{Email: "weights@example.com", Password: "SecureP@ss123", Role: "admin"}
+	utils.TestDB.Create(admin)
+	pair, _ := models.CreateTokenPair(utils.TestDB, admin.UserID, admin.Email, models.SessionInfo{})
+
+	product := &models.Product{
+		Name:        "Heavy Product",
+		Description: "Ships by weight",
+		Price:       50,
+		Category:    "Test Category",
+		Stock:       10,
+		Weight:      2.5,
+	}
+	utils.TestDB.Create(product)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	routes.SetupRouter(router)
+	update := func(body string) (int, float64) {
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/admin/update-products/%d", product.ID), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var stored models.Product
+		utils.TestDB.First(&stored, product.ID)
+		return w.Code, stored.Weight
+	}
+
+	t.Run("Omitted Weight Kept", func(t *testing.T) {
+		code, weight := update(`{"name": "Heavy Product", "description": "Ships by weight", "price": 45, "category": "Test Category", "stock": 10}`)
+		passed := code == http.StatusOK && weight == 2.5
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected the stored weight to be kept, got status %d and weight %.2f", code, weight)
+		}
+		utils.RecordTest(t, "Product - Update Keeps Weight", passed, errMsg)
+	})
+
+	t.Run("Explicit Weight Applied", func(t *testing.T) {
+		code, weight := update(`{"name": "Heavy Product", "description": "Ships by weight", "price": 45, "category": "Test Category", "stock": 10, "weight": 0}`)
+		passed := code == http.StatusOK && weight == 0
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected an explicit zero weight to be stored, got status %d and weight %.2f", code, weight)
+		}
+		utils.RecordTest(t, "Product - Update Sets Weight", passed, errMsg)
+	})
+}
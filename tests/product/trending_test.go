@@ -2,6 +2,7 @@ package product_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/amcishara/web_Tracking_system/models"
 	"github.com/amcishara/web_Tracking_system/tests/utils"
@@ -95,3 +96,129 @@ func TestTrendingProducts(t *testing.T) {
 		utils.RecordTest(t, "Trending - Limit Results", passed, errMsg)
 	})
 }
+
+func TestWindowedTrendingProducts(t *testing.T) {
+	utils.TruncateTable("product_view_buckets")
+	utils.TruncateTable("trending_products")
+	utils.TruncateTable("products")
+
+	products := []models.Product{
+		{Name: "Old Favourite", Description: "Popular last year", Price: 199.99, Category: "Audio", Stock: 10},
+		{Name: "New Hit", Description: "Popular today", Price: 299.99, Category: "Audio", Stock: 10},
+		{Name: "Fresh Laptop", Description: "Popular today", Price: 999.99, Category: "Laptops", Stock: 10},
+	}
+	for i := range products {
+		if err := utils.TestDB.Create(&products[i]).Error; err != nil {
+			t.Fatalf("Failed to create test product: %v", err)
+		}
+	}
+
+	// Old views only count toward the all-time counter
+	for i := 0; i < 10; i++ {
+		models.UpdateTrendingViews(utils.TestDB, products[0].ID, products[0].Name)
+	}
+	utils.TestDB.Model(&models.ProductViewBucket{}).
+		Where("product_id = ?", products[0].ID).
+		Update("bucket_start", time.Now().Add(-30*24*time.Hour))
+
+	for i := 0; i < 3; i++ {
+		models.UpdateTrendingViews(utils.TestDB, products[1].ID, products[1].Name)
+	}
+	models.UpdateTrendingViews(utils.TestDB, products[2].ID, products[2].Name)
+
+	t.Run("Recent Views Win", func(t *testing.T) {
+		trending, err := models.GetWindowedTrendingProducts(utils.TestDB, models.TrendingOptions{
+			Window: "24h",
+			Limit:  3,
+		})
+		passed := err == nil && len(trending) == 3 && trending[0].ID == products[1].ID
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		} else if !passed {
+			errMsg = "Expected recently viewed product to lead the 24h window"
+		}
+		utils.RecordTest(t, "Trending - Window Ranking", passed, errMsg)
+	})
+
+	t.Run("All Time Counter", func(t *testing.T) {
+		trending, err := models.GetWindowedTrendingProducts(utils.TestDB, models.TrendingOptions{
+			Window: "all",
+			Limit:  3,
+		})
+		passed := err == nil && len(trending) > 0 && trending[0].ID == products[0].ID
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected all-time leader first for the 'all' window"
+		}
+		utils.RecordTest(t, "Trending - All Time Window", passed, errMsg)
+	})
+
+	t.Run("Category Filter", func(t *testing.T) {
+		trending, err := models.GetWindowedTrendingProducts(utils.TestDB, models.TrendingOptions{
+			Window:   "7d",
+			Category: "Laptops",
+			Limit:    5,
+		})
+		passed := err == nil && len(trending) == 1 && trending[0].ID == products[2].ID
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected only the Laptops product"
+		}
+		utils.RecordTest(t, "Trending - Category Filter", passed, errMsg)
+	})
+
+	t.Run("Invalid Window", func(t *testing.T) {
+		_, err := models.GetWindowedTrendingProducts(utils.TestDB, models.TrendingOptions{
+			Window: "30d",
+			Limit:  5,
+		})
+		passed := err != nil
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected error for unknown window"
+		}
+		utils.RecordTest(t, "Trending - Invalid Window", passed, errMsg)
+	})
+}
+
+func TestStartJob(t *testing.T) {
+	t.Run("Runs On Interval", func(t *testing.T) {
+		runs := make(chan struct{}, 10)
+		stop := models.StartJob("test-job", 10*time.Millisecond, func() error {
+			runs <- struct{}{}
+			return nil
+		})
+
+		ran := false
+		select {
+		case <-runs:
+			ran = true
+		case <-time.After(time.Second):
+		}
+		stop()
+		stop() // Stopping twice is safe
+
+		utils.RecordTest(t, "Jobs - Runs On Interval", ran, "Expected the job to run")
+	})
+
+	t.Run("Non-Positive Interval", func(t *testing.T) {
+		passed := true
+		for _, interval := range []time.Duration{0, -time.Second} {
+			func() {
+				defer func() {
+					if recover() != nil {
+						passed = false
+					}
+				}()
+				stop := models.StartJob("invalid-job", interval, func() error {
+					passed = false
+					return nil
+				})
+				time.Sleep(20 * time.Millisecond)
+				stop()
+			}()
+		}
+		utils.RecordTest(t, "Jobs - Non-Positive Interval", passed, "Expected the job to be skipped without panicking")
+	})
+}
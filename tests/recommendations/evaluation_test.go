@@ -0,0 +1,164 @@
+package recommendations_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+	"gorm.io/gorm"
+)
+
+func TestEvaluationMetrics(t *testing.T) {
+	recs := []uint{1, 2, 3, 4}
+	relevant := map[uint]bool{1: true, 3: true, 9: true}
+
+	t.Run("Precision And Recall", func(t *testing.T) {
+		precision := models.PrecisionAtK(recs, relevant, 4)
+		recall := models.RecallAtK(recs, relevant, 4)
+		passed := precision == 0.5 && math.Abs(recall-2.0/3.0) < 1e-9
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected precision 0.5 and recall 2/3"
+		}
+		utils.RecordTest(t, "Evaluation - Precision And Recall", passed, errMsg)
+	})
+
+	t.Run("Average Precision", func(t *testing.T) {
+		// Hits at positions 1 and 3: (1/1 + 2/3) / 3 relevant items
+		ap := models.AveragePrecisionAtK(recs, relevant, 4)
+		passed := math.Abs(ap-(1.0+2.0/3.0)/3.0) < 1e-9
+		errMsg := ""
+		if !passed {
+			errMsg = "Unexpected average precision"
+		}
+		utils.RecordTest(t, "Evaluation - Average Precision", passed, errMsg)
+	})
+
+	t.Run("NDCG", func(t *testing.T) {
+		perfect := models.NDCGAtK([]uint{1, 3, 9}, relevant, 3)
+		none := models.NDCGAtK([]uint{5, 6, 7}, relevant, 3)
+		passed := math.Abs(perfect-1) < 1e-9 && none == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected NDCG 1 for ideal ranking and 0 without hits"
+		}
+		utils.RecordTest(t, "Evaluation - NDCG", passed, errMsg)
+	})
+}
+
+func TestOfflineEvaluation(t *testing.T) {
+	for _, table := range []string{"user_interactions", "guest_interactions", "guest_user_links", "product_similarities",
+		"product_view_buckets", "trending_products", "wishlist_items", "wishlists", "products", "users"} {
+		utils.TruncateTable(table)
+	}
+
+	start := time.Now().Add(-48 * time.Hour).Truncate(time.Hour)
+	at := func(hours int) time.Time { return start.Add(time.Duration(hours) * time.Hour) }
+
+	products := []models.Product{
+		{Name: "iPhone", Category: "Electronics", Price: 999, Stock: 10, CreatedAt: at(-1)},
+		{Name: "AirPods", Category: "Electronics", Price: 199, Stock: 10, CreatedAt: at(-1)},
+		{Name: "Novel", Category: "Books", Price: 20, Stock: 10, CreatedAt: at(-1)},
+	}
+	utils.TestDB.Create(&products)
+	iphone, airpods := products[0].ID, products[1].ID
+
+	users := []models.User{{Email: "eval1@example.com", Password: "x"}, {Email: "eval2@example.com", Password: "x"}}
+	utils.TestDB.Create(&users)
+	user1, user2 := users[0].UserID, users[1].UserID
+
+	// A user and a guest pair iPhone with AirPods; a second user views iPhone first and AirPods after the cutoff
+	view := func(userID uint, productID uint, viewedAt time.Time) {
+		utils.TestDB.Exec("INSERT INTO user_interactions (user_id, product_id, viewed_at) VALUES (?, ?, ?)", userID, productID, viewedAt)
+	}
+	view(user1, iphone, at(0))
+	view(user1, airpods, at(1))
+	utils.TestDB.Exec("INSERT INTO guest_interactions (guest_id, product_id, viewed_at) VALUES (?, ?, ?)", "eval-guest", iphone, at(2))
+	utils.TestDB.Exec("INSERT INTO guest_interactions (guest_id, product_id, viewed_at) VALUES (?, ?, ?)", "eval-guest", airpods, at(3))
+	view(user2, iphone, at(4))
+	view(user2, airpods, at(10))
+
+	records, loadErr := models.LoadInteractionLog(utils.TestDB)
+	catalog, _ := models.LoadCatalog(utils.TestDB)
+	cutoff := at(5)
+
+	var report models.EvalReport
+	var snapshotSupport int
+	err := models.WithTrainSnapshot(utils.TestDB, cutoff, "", func(snapshot *gorm.DB) error {
+		snapshot.Raw("SELECT support FROM product_similarities WHERE product_id = ? AND similar_product_id = ?",
+			iphone, airpods).Scan(&snapshotSupport)
+
+		var evalErr error
+		report, evalErr = models.Evaluate(snapshot, records, catalog, cutoff, 1, []string{"collaborative", "trending"})
+		return evalErr
+	})
+	if loadErr != nil {
+		err = loadErr
+	}
+
+	t.Run("Split", func(t *testing.T) {
+		passed := err == nil && report.TrainViews == 5 && report.TestViews == 1
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		} else if !passed {
+			errMsg = "Expected 5 train views and 1 test view"
+		}
+		utils.RecordTest(t, "Evaluation - Split", passed, errMsg)
+	})
+
+	t.Run("Snapshot Sees Train Views Only", func(t *testing.T) {
+		// The user's post-cutoff AirPods view must not count towards the pair's support
+		passed := snapshotSupport == 2
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected snapshot support 2 from the user and the guest, got %d", snapshotSupport)
+		}
+		utils.RecordTest(t, "Evaluation - Snapshot Sees Train Views Only", passed, errMsg)
+	})
+
+	t.Run("Collaborative Hit", func(t *testing.T) {
+		passed := len(report.Strategies) == 2
+		if passed {
+			m := report.Strategies[0]
+			passed = m.Strategy == "collaborative" && m.Visitors == 1 && m.Precision == 1 && m.Recall == 1 && m.NDCG == 1
+		}
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the registered collaborative strategy to recommend AirPods after iPhone"
+		}
+		utils.RecordTest(t, "Evaluation - Collaborative Hit", passed, errMsg)
+	})
+
+	t.Run("Coverage", func(t *testing.T) {
+		passed := len(report.Strategies) == 2 &&
+			math.Abs(report.Strategies[0].Coverage-1.0/3.0) < 1e-9 && report.Strategies[0].Novelty > 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected one of three products covered with positive novelty"
+		}
+		utils.RecordTest(t, "Evaluation - Coverage", passed, errMsg)
+	})
+
+	t.Run("Source Untouched", func(t *testing.T) {
+		var similarities, trending, schemas int64
+		utils.TestDB.Model(&models.ProductSimilarity{}).Count(&similarities)
+		utils.TestDB.Table("trending_products").Count(&trending)
+		utils.TestDB.Raw("SELECT COUNT(*) FROM information_schema.schemata WHERE schema_name = CONCAT(DATABASE(), '_eval')").Scan(&schemas)
+
+		passed := similarities == 0 && trending == 0 && schemas == 0
+		errMsg := ""
+		if !passed {
+			errMsg = "Expected the source tables unchanged and the snapshot schema dropped"
+		}
+		utils.RecordTest(t, "Evaluation - Source Untouched", passed, errMsg)
+	})
+
+	t.Run("Unknown Strategy", func(t *testing.T) {
+		_, err := models.Evaluate(utils.TestDB, records, catalog, cutoff, 1, []string{"nope"})
+		utils.RecordTest(t, "Evaluation - Unknown Strategy", err != nil, "Expected error for unregistered strategy")
+	})
+}
@@ -0,0 +1,194 @@
+package recommendations_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+func TestExperiments(t *testing.T) {
+	products := setupTestProducts(t)
+	utils.TruncateTable("experiment_exposure_items")
+	utils.TruncateTable("experiment_exposures")
+	utils.TruncateTable("experiment_variants")
+	utils.TruncateTable("experiments")
+	utils.TruncateTable("events")
+
+	experiment := &models.Experiment{
+		Name:      "category-vs-price",
+		Placement: models.PlacementOtherRecommendations,
+		Status:    "running",
+		Variants: []models.ExperimentVariant{
+			{Name: "control", Weight: 1, Strategies: models.StrategyList{{Strategy: "category", Weight: 1}}},
+			{Name: "price", Weight: 1, Strategies: models.StrategyList{{Strategy: "price_range", Weight: 1}}},
+		},
+	}
+
+	t.Run("Create Experiment", func(t *testing.T) {
+		err := models.CreateExperiment(utils.TestDB, experiment)
+		passed := err == nil && experiment.ID != 0
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		utils.RecordTest(t, "Experiment - Create", passed, errMsg)
+	})
+
+	t.Run("Deterministic Bucketing", func(t *testing.T) {
+		passed := true
+		seen := make(map[string]bool)
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("g:guest-%d", i)
+			first := models.AssignVariant(experiment, key)
+			second := models.AssignVariant(experiment, key)
+			if first == nil || first.Name != second.Name {
+				passed = false
+			}
+			seen[first.Name] = true
+		}
+		passed = passed && len(seen) == 2
+		utils.RecordTest(t, "Experiment - Deterministic Bucketing", passed, "Expected stable assignment across both variants")
+	})
+
+	var shown []models.ScoredItem
+	t.Run("Exposure Logging", func(t *testing.T) {
+		items, err := models.ServeRecommendations(utils.TestDB, models.PlacementOtherRecommendations,
+			models.RecommendationContext{ProductID: products[0].ID, GuestID: "guest-exp"})
+		shown = items
+
+		var exposures int64
+		utils.TestDB.Model(&models.ExperimentExposure{}).Where("guest_id = ?", "guest-exp").Count(&exposures)
+
+		passed := err == nil && len(items) > 0 && exposures == 1 && items[0].ExperimentID == experiment.ID
+		utils.RecordTest(t, "Experiment - Exposure Logging", passed, "Expected one exposure for the guest and tagged items")
+	})
+
+	t.Run("Untagged View Not Attributed", func(t *testing.T) {
+		if len(shown) > 0 {
+			models.TrackGuestView(utils.TestDB, "guest-exp", shown[0].ID)
+		}
+
+		report, err := models.GetExperimentReport(utils.TestDB, experiment.ID)
+
+		var clicks int64
+		for _, v := range report {
+			clicks += v.Clicks
+		}
+		passed := err == nil && len(shown) > 0 && clicks == 0
+		utils.RecordTest(t, "Experiment - Untagged View Not Attributed", passed, "Expected a plain view not to count as a click")
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		// Guest clicks the first recommendation twice and is shown the placement again
+		if len(shown) > 0 {
+			for i := 0; i < 2; i++ {
+				productID := shown[0].ID
+				models.RecordEvent(utils.TestDB, &models.Event{
+					Type:       models.EventClick,
+					GuestID:    "guest-exp",
+					ProductID:  &productID,
+					Properties: models.EventProperties{"experiment_id": shown[0].ExperimentID},
+				})
+			}
+		}
+		models.ServeRecommendations(utils.TestDB, models.PlacementOtherRecommendations,
+			models.RecommendationContext{ProductID: products[0].ID, GuestID: "guest-exp"})
+
+		report, err := models.GetExperimentReport(utils.TestDB, experiment.ID)
+
+		var exposures, clicks int64
+		for _, v := range report {
+			exposures += v.Exposures
+			clicks += v.Clicks
+		}
+		passed := err == nil && len(report) == 2 && exposures == 1 && clicks == 1
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected one visitor exposed and clicking, got %d exposures and %d clicks", exposures, clicks)
+		}
+		utils.RecordTest(t, "Experiment - Report", passed, errMsg)
+	})
+
+	t.Run("Click For Another Experiment", func(t *testing.T) {
+		if len(shown) > 0 {
+			productID := shown[0].ID
+			models.RecordEvent(utils.TestDB, &models.Event{
+				Type:       models.EventAddToCart,
+				GuestID:    "guest-exp",
+				ProductID:  &productID,
+				Properties: models.EventProperties{"quantity": 1, "experiment_id": experiment.ID + 1},
+			})
+		}
+
+		report, err := models.GetExperimentReport(utils.TestDB, experiment.ID)
+
+		var carts int64
+		for _, v := range report {
+			carts += v.AddToCarts
+		}
+		passed := err == nil && carts == 0
+		utils.RecordTest(t, "Experiment - Click For Another Experiment", passed, "Expected a cart add tagged for another experiment not to count")
+	})
+
+	t.Run("Significance Test", func(t *testing.T) {
+		_, strong := models.TwoProportionZTest(100, 1000, 150, 1000)
+		_, weak := models.TwoProportionZTest(100, 1000, 102, 1000)
+		passed := strong < 0.05 && weak > 0.05
+		utils.RecordTest(t, "Experiment - Significance", passed, fmt.Sprintf("Unexpected p-values %.4f / %.4f", strong, weak))
+	})
+
+	t.Run("Single Running Experiment", func(t *testing.T) {
+		err := models.CreateExperiment(utils.TestDB, &models.Experiment{
+			Name:      "second",
+			Placement: models.PlacementOtherRecommendations,
+			Status:    "running",
+			Variants: []models.ExperimentVariant{
+				{Name: "a", Weight: 1, Strategies: models.StrategyList{{Strategy: "category", Weight: 1}}},
+				{Name: "b", Weight: 1, Strategies: models.StrategyList{{Strategy: "trending", Weight: 1}}},
+			},
+		})
+		utils.RecordTest(t, "Experiment - Single Running", err != nil, "Expected error for a second running experiment")
+	})
+
+	t.Run("Concurrent Starts", func(t *testing.T) {
+		models.SetExperimentStatus(utils.TestDB, experiment.ID, "stopped")
+
+		drafts := make([]*models.Experiment, 4)
+		for i := range drafts {
+			drafts[i] = &models.Experiment{
+				Name:      fmt.Sprintf("draft-%d", i),
+				Placement: models.PlacementOtherRecommendations,
+				Variants: []models.ExperimentVariant{
+					{Name: "a", Weight: 1, Strategies: models.StrategyList{{Strategy: "category", Weight: 1}}},
+					{Name: "b", Weight: 1, Strategies: models.StrategyList{{Strategy: "trending", Weight: 1}}},
+				},
+			}
+			models.CreateExperiment(utils.TestDB, drafts[i])
+		}
+
+		var wg sync.WaitGroup
+		for _, draft := range drafts {
+			wg.Add(1)
+			go func(id uint) {
+				defer wg.Done()
+				models.SetExperimentStatus(utils.TestDB, id, "running")
+			}(draft.ID)
+		}
+		wg.Wait()
+
+		var running int64
+		utils.TestDB.Model(&models.Experiment{}).
+			Where("placement = ? AND status = ?", models.PlacementOtherRecommendations, "running").
+			Count(&running)
+
+		passed := running == 1
+		errMsg := ""
+		if !passed {
+			errMsg = fmt.Sprintf("Expected exactly one running experiment, got %d", running)
+		}
+		utils.RecordTest(t, "Experiment - Concurrent Starts", passed, errMsg)
+	})
+}
@@ -0,0 +1,65 @@
+package recommendations_test
+
+import (
+	"testing"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+func TestForYouRecommendations(t *testing.T) {
+	products := setupTestProducts(t)
+	utils.TruncateTable("trending_products")
+	utils.TruncateTable("product_view_buckets")
+
+	t.Run("Cold Start", func(t *testing.T) {
+		items, coldStart, err := models.GetForYouRecommendations(utils.TestDB, models.RecommendationContext{
+			GuestID: "guest-new",
+		})
+		passed := err == nil && coldStart && len(items) > 0
+		utils.RecordTest(t, "For You - Cold Start", passed, "Expected trending fallback for a new guest")
+	})
+
+	t.Run("Personalized Guest Feed", func(t *testing.T) {
+		// Another guest links iPhone with AirPods
+		models.TrackGuestView(utils.TestDB, "guest-other", products[0].ID) // iPhone
+		models.TrackGuestView(utils.TestDB, "guest-other", products[3].ID) // AirPods
+
+		models.TrackGuestView(utils.TestDB, "guest-feed", products[0].ID) // iPhone
+
+		items, coldStart, err := models.GetForYouRecommendations(utils.TestDB, models.RecommendationContext{
+			GuestID: "guest-feed",
+		})
+
+		passed := err == nil && !coldStart && len(items) > 0
+		hasAirPods, hasViewed, hasSmartphone := false, false, false
+		for _, item := range items {
+			switch {
+			case item.ID == products[0].ID:
+				hasViewed = true
+			case item.ID == products[3].ID:
+				hasAirPods = true
+			case item.Category == "Smartphones":
+				hasSmartphone = true
+			}
+		}
+		passed = passed && hasAirPods && hasSmartphone && !hasViewed
+		utils.RecordTest(t, "For You - Guest Feed", passed, "Expected co-viewed and same-category items, excluding viewed")
+	})
+
+	t.Run("Excludes Cart Items", func(t *testing.T) {
+		utils.TruncateTable("cart_items")
+		models.TrackUserView(utils.TestDB, 1, products[5].ID) // iPad
+		utils.TestDB.Create(&models.CartItem{UserID: 1, ProductID: products[6].ID, Quantity: 1})
+
+		items, _, err := models.GetForYouRecommendations(utils.TestDB, models.RecommendationContext{UserID: 1})
+
+		passed := err == nil
+		for _, item := range items {
+			if item.ID == products[5].ID || item.ID == products[6].ID {
+				passed = false
+			}
+		}
+		utils.RecordTest(t, "For You - Excludes Cart", passed, "Expected viewed and in-cart products excluded")
+	})
+}
@@ -25,6 +25,7 @@ func TestMain(m *testing.M) {
 func setupTestProducts(t *testing.T) []models.Product {
 	utils.TruncateTable("user_interactions")
 	utils.TruncateTable("guest_interactions")
+	utils.TruncateTable("product_similarities")
 	utils.TruncateTable("products")
 
 	// Create diverse test products
@@ -206,3 +207,50 @@ func TestHybridRecommendations(t *testing.T) {
 		utils.RecordTest(t, "Hybrid - Diverse Recommendations", passed, errMsg)
 	})
 }
+
+func TestGuestCollaborativeRecommendations(t *testing.T) {
+	products := setupTestProducts(t)
+	utils.TruncateTable("guest_user_links")
+
+	// Guests: iPhone -> AirPods pattern twice, iPhone -> iPad once
+	models.TrackGuestView(utils.TestDB, "guest-1", products[0].ID)
+	models.TrackGuestView(utils.TestDB, "guest-1", products[3].ID)
+	models.TrackGuestView(utils.TestDB, "guest-2", products[0].ID)
+	models.TrackGuestView(utils.TestDB, "guest-2", products[3].ID)
+	models.TrackGuestView(utils.TestDB, "guest-3", products[0].ID)
+	models.TrackGuestView(utils.TestDB, "guest-3", products[5].ID)
+
+	t.Run("Guest Co-Views", func(t *testing.T) {
+		recs, err := models.GetCollaborativeRecommendations(utils.TestDB, products[0].ID, 5)
+
+		passed := err == nil && len(recs) > 0 && recs[0].ID == products[3].ID
+		utils.RecordTest(t, "Collaborative - Guest Co-Views", passed, "Expected AirPods from guest histories")
+	})
+
+	t.Run("Source Weighting", func(t *testing.T) {
+		// A single user pattern outweighs two guests when guests are discounted heavily
+		models.TrackUserView(utils.TestDB, 1, products[0].ID) // iPhone
+		models.TrackUserView(utils.TestDB, 1, products[6].ID) // MacBook
+
+		recs, err := models.GetWeightedCollaborativeRecommendations(utils.TestDB, products[0].ID, 5,
+			models.CoViewWeights{User: 1.0, Guest: 0.1})
+
+		passed := err == nil && len(recs) > 0 && recs[0].ID == products[6].ID
+		utils.RecordTest(t, "Collaborative - Source Weighting", passed, "Expected user co-view to rank first")
+	})
+
+	t.Run("Guest Only Weighting", func(t *testing.T) {
+		recs, err := models.GetWeightedCollaborativeRecommendations(utils.TestDB, products[0].ID, 5,
+			models.CoViewWeights{User: 1.0, Guest: 0})
+
+		passed := err == nil && len(recs) > 0 && recs[0].ID == products[6].ID
+		if passed {
+			for _, rec := range recs {
+				if rec.ID == products[3].ID {
+					passed = false
+				}
+			}
+		}
+		utils.RecordTest(t, "Collaborative - Zero Guest Weight", passed, "Expected guest co-views to be ignored")
+	})
+}
@@ -0,0 +1,73 @@
+package recommendations_test
+
+import (
+	"testing"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+	"gorm.io/gorm"
+)
+
+// fixedRecommender always returns the same products
+type fixedRecommender struct {
+	name  string
+	items []models.ScoredItem
+}
+
+func (f fixedRecommender) Name() string { return f.name }
+
+func (f fixedRecommender) Recommend(db *gorm.DB, ctx models.RecommendationContext) ([]models.ScoredItem, error) {
+	return f.items, nil
+}
+
+func scored(id uint, score float64, reason string) models.ScoredItem {
+	return models.ScoredItem{
+		ProductRecommendation: models.ProductRecommendation{ID: id},
+		Score:                 score,
+		Reasons:               []string{reason},
+	}
+}
+
+func TestRecommenderRegistry(t *testing.T) {
+	models.RegisterRecommender(fixedRecommender{name: "test_a", items: []models.ScoredItem{
+		scored(1, 1.0, "a"), scored(2, 0.5, "a"), scored(3, 0.2, "a"),
+	}})
+	models.RegisterRecommender(fixedRecommender{name: "test_b", items: []models.ScoredItem{
+		scored(3, 1.0, "b"), scored(4, 0.5, "b"),
+	}})
+
+	t.Run("Weighted Blend", func(t *testing.T) {
+		items, err := models.BlendRecommendations(utils.TestDB, []models.WeightedStrategy{
+			{Strategy: "test_a", Weight: 0.5},
+			{Strategy: "test_b", Weight: 1.0},
+		}, models.RecommendationContext{Limit: 3})
+
+		// Product 3 scores 0.1 + 1.0 and carries both reasons
+		passed := err == nil && len(items) == 3 && items[0].ID == 3 && len(items[0].Reasons) == 2
+		utils.RecordTest(t, "Recommender - Weighted Blend", passed, "Expected product 3 to lead the blend")
+	})
+
+	t.Run("Exclusions", func(t *testing.T) {
+		items, err := models.BlendRecommendations(utils.TestDB, []models.WeightedStrategy{
+			{Strategy: "test_a", Weight: 1.0},
+		}, models.RecommendationContext{ProductID: 1, Exclude: []uint{2}, Limit: 5})
+
+		passed := err == nil && len(items) == 1 && items[0].ID == 3
+		utils.RecordTest(t, "Recommender - Exclusions", passed, "Expected anchor and excluded products removed")
+	})
+
+	t.Run("Placement Config", func(t *testing.T) {
+		err := models.LoadPlacements(`{"test_placement":{"strategies":[{"strategy":"test_b","weight":1}],"limit":1}}`)
+		items, recErr := models.RecommendForPlacement(utils.TestDB, "test_placement", models.RecommendationContext{})
+
+		passed := err == nil && recErr == nil && len(items) == 1 && items[0].ID == 3
+		utils.RecordTest(t, "Recommender - Placement Config", passed, "Expected configured placement to use test_b")
+	})
+
+	t.Run("Unknown Strategy", func(t *testing.T) {
+		err := models.SetPlacement("broken", models.Placement{
+			Strategies: []models.WeightedStrategy{{Strategy: "missing", Weight: 1}},
+		})
+		utils.RecordTest(t, "Recommender - Unknown Strategy", err != nil, "Expected error for unregistered strategy")
+	})
+}
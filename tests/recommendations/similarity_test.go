@@ -0,0 +1,95 @@
+package recommendations_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amcishara/web_Tracking_system/models"
+	"github.com/amcishara/web_Tracking_system/tests/utils"
+)
+
+func TestSimilarityIndex(t *testing.T) {
+	products := setupTestProducts(t)
+
+	// Three users pair iPhone with AirPods, one pairs iPhone with MacBook
+	for userID := uint(1); userID <= 3; userID++ {
+		models.TrackUserView(utils.TestDB, userID, products[0].ID) // iPhone
+		models.TrackUserView(utils.TestDB, userID, products[3].ID) // AirPods
+	}
+	models.TrackUserView(utils.TestDB, 4, products[0].ID) // iPhone
+	models.TrackUserView(utils.TestDB, 4, products[6].ID) // MacBook
+
+	config := models.DefaultSimilarityConfig()
+	config.MinSupport = 2
+
+	t.Run("Build Index", func(t *testing.T) {
+		err := models.ComputeProductSimilarities(utils.TestDB, config)
+		similar, getErr := models.GetSimilarProducts(utils.TestDB, products[0].ID, 5)
+
+		passed := err == nil && getErr == nil && len(similar) == 1 && similar[0].ID == products[3].ID
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		} else if !passed {
+			errMsg = "Expected AirPods as the only neighbor meeting minimum support"
+		}
+		utils.RecordTest(t, "Similarity - Build Index", passed, errMsg)
+	})
+
+	t.Run("Symmetric Neighbors", func(t *testing.T) {
+		similar, err := models.GetSimilarProducts(utils.TestDB, products[3].ID, 5)
+		passed := err == nil && len(similar) == 1 && similar[0].ID == products[0].ID
+		utils.RecordTest(t, "Similarity - Symmetric", passed, "Expected iPhone as neighbor of AirPods")
+	})
+
+	t.Run("Recommendations Use Index", func(t *testing.T) {
+		recs, err := models.GetCollaborativeRecommendations(utils.TestDB, products[0].ID, 5)
+		passed := err == nil && len(recs) > 0 && recs[0].ID == products[3].ID
+		utils.RecordTest(t, "Similarity - Used By Recommendations", passed, "Expected index neighbor first")
+	})
+
+	t.Run("Live Fallback", func(t *testing.T) {
+		// MacBook has no indexed neighbors, so the live co-view query answers
+		recs, err := models.GetCollaborativeRecommendations(utils.TestDB, products[6].ID, 5)
+		passed := err == nil && len(recs) > 0 && recs[0].ID == products[0].ID
+		utils.RecordTest(t, "Similarity - Live Fallback", passed, "Expected live co-view result for unindexed product")
+	})
+
+	t.Run("Jaccard Metric", func(t *testing.T) {
+		config.Metric = "jaccard"
+		config.MinSupport = 1
+		err := models.ComputeProductSimilarities(utils.TestDB, config)
+		similar, _ := models.GetSimilarProducts(utils.TestDB, products[0].ID, 5)
+
+		passed := err == nil && len(similar) == 2 && similar[0].ID == products[3].ID
+		utils.RecordTest(t, "Similarity - Jaccard", passed, "Expected AirPods ahead of MacBook")
+	})
+
+	t.Run("Most Recent Items Kept", func(t *testing.T) {
+		// Two guests browsed the Samsung and Galaxy Watch long ago, then the Pixel and iPad recently
+		now := time.Now()
+		for _, guestID := range []string{"guest-recent-a", "guest-recent-b"} {
+			utils.TestDB.Create(&[]models.GuestInteraction{
+				{GuestID: guestID, ProductID: products[1].ID, ViewedAt: now.Add(-48 * time.Hour)},
+				{GuestID: guestID, ProductID: products[4].ID, ViewedAt: now.Add(-47 * time.Hour)},
+				{GuestID: guestID, ProductID: products[2].ID, ViewedAt: now.Add(-2 * time.Minute)},
+				{GuestID: guestID, ProductID: products[5].ID, ViewedAt: now.Add(-time.Minute)},
+			})
+		}
+
+		recent := models.DefaultSimilarityConfig()
+		recent.MaxItemsPerVisitor = 2
+		err := models.ComputeProductSimilarities(utils.TestDB, recent)
+		kept, _ := models.GetSimilarProducts(utils.TestDB, products[2].ID, 5)
+		dropped, _ := models.GetSimilarProducts(utils.TestDB, products[1].ID, 5)
+
+		passed := err == nil && len(kept) == 1 && kept[0].ID == products[5].ID && len(dropped) == 0
+		utils.RecordTest(t, "Similarity - Most Recent Items Kept", passed, "Expected only the latest views of long histories to pair up")
+	})
+
+	t.Run("Invalid Metric", func(t *testing.T) {
+		config.Metric = "euclidean"
+		err := models.ComputeProductSimilarities(utils.TestDB, config)
+		utils.RecordTest(t, "Similarity - Invalid Metric", err != nil, "Expected error for unknown metric")
+	})
+}
@@ -26,6 +26,32 @@ func SetupTestDB() {
 	fmt.Println("Test database connection successful")
 
 	// Drop existing tables in correct order
+	TestDB.Migrator().DropTable(&models.LoginAuditEvent{})
+	TestDB.Migrator().DropTable(&models.LoginThrottle{})
+	TestDB.Migrator().DropTable(&models.EmailMessage{})
+	TestDB.Migrator().DropTable(&models.AccountToken{})
+	TestDB.Migrator().DropTable(&models.RefreshToken{})
+	TestDB.Migrator().DropTable(&models.WishlistItem{})
+	TestDB.Migrator().DropTable(&models.Wishlist{})
+	TestDB.Migrator().DropTable(&models.AbandonedCartItem{})
+	TestDB.Migrator().DropTable(&models.AbandonedCart{})
+	TestDB.Migrator().DropTable(&models.CartCoupon{})
+	TestDB.Migrator().DropTable(&models.PromotionRedemption{})
+	TestDB.Migrator().DropTable(&models.Promotion{})
+	TestDB.Migrator().DropTable(&models.StockReservation{})
+	TestDB.Migrator().DropTable(&models.PaymentWebhookEvent{})
+	TestDB.Migrator().DropTable(&models.Payment{})
+	TestDB.Migrator().DropTable(&models.InventoryMovement{})
+	TestDB.Migrator().DropTable(&models.OrderItem{})
+	TestDB.Migrator().DropTable(&models.Order{})
+	TestDB.Migrator().DropTable(&models.ExperimentExposureItem{})
+	TestDB.Migrator().DropTable(&models.ExperimentExposure{})
+	TestDB.Migrator().DropTable(&models.ExperimentVariant{})
+	TestDB.Migrator().DropTable(&models.Experiment{})
+	TestDB.Migrator().DropTable(&models.ProductSimilarity{})
+	TestDB.Migrator().DropTable(&models.ProductViewBucket{})
+	TestDB.Migrator().DropTable(&models.GuestUserLink{})
+	TestDB.Migrator().DropTable(&models.Event{})
 	TestDB.Migrator().DropTable(&models.CartItem{})
 	TestDB.Migrator().DropTable(&models.GuestInteraction{})
 	TestDB.Migrator().DropTable("trending_products")
@@ -40,6 +66,32 @@ func SetupTestDB() {
 		&models.Session{},
 		&models.CartItem{},
 		&models.GuestInteraction{},
+		&models.Event{},
+		&models.GuestUserLink{},
+		&models.ProductViewBucket{},
+		&models.ProductSimilarity{},
+		&models.Experiment{},
+		&models.ExperimentVariant{},
+		&models.ExperimentExposure{},
+		&models.ExperimentExposureItem{},
+		&models.Order{},
+		&models.OrderItem{},
+		&models.InventoryMovement{},
+		&models.Payment{},
+		&models.PaymentWebhookEvent{},
+		&models.StockReservation{},
+		&models.Promotion{},
+		&models.PromotionRedemption{},
+		&models.CartCoupon{},
+		&models.AbandonedCart{},
+		&models.AbandonedCartItem{},
+		&models.Wishlist{},
+		&models.WishlistItem{},
+		&models.RefreshToken{},
+		&models.AccountToken{},
+		&models.EmailMessage{},
+		&models.LoginThrottle{},
+		&models.LoginAuditEvent{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate test database:", err)
@@ -61,6 +113,32 @@ func SetupTestDB() {
 
 // CleanupTestDB drops all test tables
 func CleanupTestDB() {
+	TestDB.Migrator().DropTable(&models.LoginAuditEvent{})
+	TestDB.Migrator().DropTable(&models.LoginThrottle{})
+	TestDB.Migrator().DropTable(&models.EmailMessage{})
+	TestDB.Migrator().DropTable(&models.AccountToken{})
+	TestDB.Migrator().DropTable(&models.RefreshToken{})
+	TestDB.Migrator().DropTable(&models.WishlistItem{})
+	TestDB.Migrator().DropTable(&models.Wishlist{})
+	TestDB.Migrator().DropTable(&models.AbandonedCartItem{})
+	TestDB.Migrator().DropTable(&models.AbandonedCart{})
+	TestDB.Migrator().DropTable(&models.CartCoupon{})
+	TestDB.Migrator().DropTable(&models.PromotionRedemption{})
+	TestDB.Migrator().DropTable(&models.Promotion{})
+	TestDB.Migrator().DropTable(&models.StockReservation{})
+	TestDB.Migrator().DropTable(&models.PaymentWebhookEvent{})
+	TestDB.Migrator().DropTable(&models.Payment{})
+	TestDB.Migrator().DropTable(&models.InventoryMovement{})
+	TestDB.Migrator().DropTable(&models.OrderItem{})
+	TestDB.Migrator().DropTable(&models.Order{})
+	TestDB.Migrator().DropTable(&models.ExperimentExposureItem{})
+	TestDB.Migrator().DropTable(&models.ExperimentExposure{})
+	TestDB.Migrator().DropTable(&models.ExperimentVariant{})
+	TestDB.Migrator().DropTable(&models.Experiment{})
+	TestDB.Migrator().DropTable(&models.ProductSimilarity{})
+	TestDB.Migrator().DropTable(&models.ProductViewBucket{})
+	TestDB.Migrator().DropTable(&models.GuestUserLink{})
+	TestDB.Migrator().DropTable(&models.Event{})
 	TestDB.Migrator().DropTable(&models.CartItem{})
 	TestDB.Migrator().DropTable(&models.GuestInteraction{})
 	TestDB.Migrator().DropTable(&models.Product{})
@@ -1,22 +1,249 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-var jwtSecret = []byte("your-secret-key") // In production, use environment variable
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Add valid signing algorithms constant
+var ValidSigningAlgorithms = map[string]jwt.SigningMethod{
+	AlgHS256: jwt.SigningMethodHS256,
+	AlgRS256: jwt.SigningMethodRS256,
+	AlgEdDSA: jwt.SigningMethodEdDSA,
+}
+
+// minSecretLength is the shortest HS256 secret accepted from a key file
+const minSecretLength = 32
+
+// AccessTokenTTL is how long an access token and its session stay valid; refresh tokens renew them
+var AccessTokenTTL = 15 * time.Minute
+
+// SigningKey is a JWT key identified by its kid.
+// A key signs new tokens from ActiveFrom until a newer key becomes active,
+// and validates tokens until ExpiresAt (zero means it never expires).
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	ActiveFrom time.Time
+	ExpiresAt  time.Time
+
+	secret  []byte           // HS256
+	private crypto.Signer    // RS256 and EdDSA; nil for verify-only keys
+	public  crypto.PublicKey // RS256 and EdDSA
+}
+
+// NewHMACKey creates an HS256 key from a shared secret
+func NewHMACKey(id string, secret []byte) *SigningKey {
+	return &SigningKey{ID: id, Algorithm: AlgHS256, secret: secret}
+}
+
+// NewRSAKey creates an RS256 key from an RSA private key
+func NewRSAKey(id string, key *rsa.PrivateKey) *SigningKey {
+	return &SigningKey{ID: id, Algorithm: AlgRS256, private: key, public: &key.PublicKey}
+}
+
+// NewEd25519Key creates an EdDSA key from an Ed25519 private key
+func NewEd25519Key(id string, key ed25519.PrivateKey) *SigningKey {
+	return &SigningKey{ID: id, Algorithm: AlgEdDSA, private: key, public: key.Public()}
+}
+
+// CanSign reports whether the key holds the material to sign tokens
+func (k *SigningKey) CanSign() bool {
+	return k.secret != nil || k.private != nil
+}
+
+// Helper function to check whether the key still validates tokens at a time
+func (k *SigningKey) validAt(now time.Time) bool {
+	return k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt)
+}
+
+// Helper function to return the key used to sign tokens
+func (k *SigningKey) signingKey() interface{} {
+	if k.Algorithm == AlgHS256 {
+		return k.secret
+	}
+	return k.private
+}
+
+// Helper function to return the key used to verify tokens
+func (k *SigningKey) verificationKey() interface{} {
+	if k.Algorithm == AlgHS256 {
+		return k.secret
+	}
+	return k.public
+}
+
+// KeySet holds every configured key, looked up by kid
+type KeySet struct {
+	keys []*SigningKey // Sorted by ActiveFrom, newest first
+	byID map[string]*SigningKey
+}
+
+// NewKeySet validates keys and builds a key set; at least one key must be able to sign
+func NewKeySet(keys []*SigningKey) (*KeySet, error) {
+	set := &KeySet{byID: make(map[string]*SigningKey, len(keys))}
+	canSign := false
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, fmt.Errorf("key id required")
+		}
+		if _, exists := set.byID[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id: %s", key.ID)
+		}
+		if _, ok := ValidSigningAlgorithms[key.Algorithm]; !ok {
+			return nil, fmt.Errorf("key %s has unsupported algorithm: %s", key.ID, key.Algorithm)
+		}
+		if key.Algorithm == AlgHS256 && len(key.secret) == 0 {
+			return nil, fmt.Errorf("key %s has no secret", key.ID)
+		}
+		if key.Algorithm != AlgHS256 && key.public == nil {
+			return nil, fmt.Errorf("key %s has no public key", key.ID)
+		}
+		if key.CanSign() {
+			canSign = true
+		}
+		set.byID[key.ID] = key
+		set.keys = append(set.keys, key)
+	}
+	if !canSign {
+		return nil, fmt.Errorf("no signing key configured")
+	}
+
+	sort.SliceStable(set.keys, func(i, j int) bool {
+		return set.keys[i].ActiveFrom.After(set.keys[j].ActiveFrom)
+	})
+	return set, nil
+}
+
+// SigningKey returns the newest active key able to sign at a time
+func (s *KeySet) SigningKey(now time.Time) (*SigningKey, error) {
+	for _, key := range s.keys {
+		if key.CanSign() && !key.ActiveFrom.After(now) && key.validAt(now) {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("no active signing key")
+}
+
+// Key returns the key with a kid while it still validates tokens
+func (s *KeySet) Key(id string, now time.Time) (*SigningKey, error) {
+	key, ok := s.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %s", id)
+	}
+	if !key.validAt(now) {
+		return nil, fmt.Errorf("key %s has expired", id)
+	}
+	return key, nil
+}
+
+var keySet atomic.Pointer[KeySet]
+
+func init() {
+	// Development fallback until main configures JWT_KEYS_FILE or JWT_SECRET
+	set, _ := NewKeySet([]*SigningKey{NewHMACKey("default", []byte("your-secret-key"))})
+	SetKeySet(set)
+}
+
+// SetKeySet replaces the keys used to sign and validate tokens
+func SetKeySet(set *KeySet) {
+	keySet.Store(set)
+}
+
+// CurrentKeySet returns the keys used to sign and validate tokens
+func CurrentKeySet() *KeySet {
+	return keySet.Load()
+}
 
 func GenerateToken(userID uint, email string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	return signToken(jwt.MapClaims{
 		"user_id": userID,
 		"email":   email,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // 24 hour expiry
+		"exp":     time.Now().Add(AccessTokenTTL).Unix(),
+		"jti":     uuid.New().String(), // Tokens issued in the same second stay unique
 	})
+}
 
-	tokenString, err := token.SignedString(jwtSecret)
+func ValidateToken(tokenString string) (*jwt.MapClaims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Action tokens sent by email must never work as access tokens
+	if _, ok := claims["purpose"]; ok {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return &claims, nil
+}
+
+// GenerateActionToken signs a token for one purpose, e.g. a password reset link, returning it and its jti.
+// It is refused by ValidateToken, so it cannot be used to sign in.
+func GenerateActionToken(userID uint, purpose string, ttl time.Duration) (string, string, error) {
+	jti := uuid.New().String()
+	token, err := signToken(jwt.MapClaims{
+		"user_id": userID,
+		"purpose": purpose,
+		"exp":     time.Now().Add(ttl).Unix(),
+		"jti":     jti,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return token, jti, nil
+}
+
+// ValidateActionToken checks an action token's signature, expiry and purpose, returning its user ID and jti
+func ValidateActionToken(tokenString, purpose string) (uint, string, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if claims["purpose"] != purpose {
+		return 0, "", fmt.Errorf("invalid token")
+	}
+	userID, ok := claims["user_id"].(float64)
+	jti, _ := claims["jti"].(string)
+	if !ok || jti == "" {
+		return 0, "", fmt.Errorf("invalid token")
+	}
+	return uint(userID), jti, nil
+}
+
+// Helper function to sign claims with the current signing key, naming the key in the kid header
+func signToken(claims jwt.MapClaims) (string, error) {
+	key, err := CurrentKeySet().SigningKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(ValidSigningAlgorithms[key.Algorithm], claims)
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.signingKey())
 	if err != nil {
 		return "", err
 	}
@@ -24,12 +251,23 @@ func GenerateToken(userID uint, email string) (string, error) {
 	return tokenString, nil
 }
 
-func ValidateToken(tokenString string) (*jwt.MapClaims, error) {
+// Helper function to verify a token against the key named by its kid header
+func parseToken(tokenString string) (jwt.MapClaims, error) {
+	set := CurrentKeySet()
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("token has no key id")
+		}
+		key, err := set.Key(kid, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		// The key decides the algorithm, never the token
+		if token.Method.Alg() != key.Algorithm {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return jwtSecret, nil
+		return key.verificationKey(), nil
 	})
 
 	if err != nil {
@@ -37,8 +275,183 @@ func ValidateToken(tokenString string) (*jwt.MapClaims, error) {
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return &claims, nil
+		return claims, nil
 	}
 
 	return nil, fmt.Errorf("invalid token")
 }
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKSet is the JSON Web Key Set document
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public keys that validate tokens now, including keys scheduled to
+// start signing later so verifiers can fetch them in advance. HS256 secrets are never published.
+func (s *KeySet) JWKS(now time.Time) JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0)}
+	for _, key := range s.keys {
+		if !key.validAt(now) {
+			continue
+		}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyID:     key.ID,
+				KeyType:   "RSA",
+				Algorithm: key.Algorithm,
+				Use:       "sig",
+				N:         base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyID:     key.ID,
+				KeyType:   "OKP",
+				Algorithm: key.Algorithm,
+				Use:       "sig",
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return set
+}
+
+// keyFileEntry is one key of a JWT_KEYS_FILE; paths are relative to the file
+type keyFileEntry struct {
+	ID             string     `json:"kid"`
+	Algorithm      string     `json:"alg"`
+	Secret         string     `json:"secret,omitempty"`           // HS256
+	PrivateKeyFile string     `json:"private_key_file,omitempty"` // RS256 or EdDSA, PEM
+	PublicKeyFile  string     `json:"public_key_file,omitempty"`  // Verify-only RS256 or EdDSA, PEM
+	ActiveFrom     *time.Time `json:"active_from,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+// LoadKeySetFile reads a key set from a JSON file of the form {"keys": [...]}
+func LoadKeySetFile(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Keys []keyFileEntry `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(name string) string {
+		if filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+
+	keys := make([]*SigningKey, 0, len(file.Keys))
+	for _, entry := range file.Keys {
+		var key *SigningKey
+		switch {
+		case entry.Algorithm == AlgHS256:
+			if len(entry.Secret) < minSecretLength {
+				return nil, fmt.Errorf("key %s secret must be at least %d characters", entry.ID, minSecretLength)
+			}
+			key = NewHMACKey(entry.ID, []byte(entry.Secret))
+		case entry.PrivateKeyFile != "":
+			key, err = loadPrivateKey(entry.ID, resolve(entry.PrivateKeyFile))
+		case entry.PublicKeyFile != "":
+			key, err = loadPublicKey(entry.ID, resolve(entry.PublicKeyFile))
+		default:
+			err = fmt.Errorf("key %s needs a private_key_file or public_key_file", entry.ID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if key.Algorithm != entry.Algorithm {
+			return nil, fmt.Errorf("key %s is a %s key, not %s", entry.ID, key.Algorithm, entry.Algorithm)
+		}
+		if entry.ActiveFrom != nil {
+			key.ActiveFrom = *entry.ActiveFrom
+		}
+		if entry.ExpiresAt != nil {
+			key.ExpiresAt = *entry.ExpiresAt
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(keys)
+}
+
+// Helper function to read a PEM private key (PKCS#8, or PKCS#1 for RSA)
+func loadPrivateKey(id, path string) (*SigningKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(block.Bytes); rsaErr == nil {
+			parsed = rsaKey
+		} else {
+			return nil, fmt.Errorf("key %s: invalid private key: %v", id, err)
+		}
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return NewRSAKey(id, k), nil
+	case ed25519.PrivateKey:
+		return NewEd25519Key(id, k), nil
+	}
+	return nil, fmt.Errorf("key %s: unsupported private key type %T", id, parsed)
+}
+
+// Helper function to read a PEM public key (PKIX, or PKCS#1 for RSA)
+func loadPublicKey(id, path string) (*SigningKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		if rsaKey, rsaErr := x509.ParsePKCS1PublicKey(block.Bytes); rsaErr == nil {
+			parsed = rsaKey
+		} else {
+			return nil, fmt.Errorf("key %s: invalid public key: %v", id, err)
+		}
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PublicKey:
+		return &SigningKey{ID: id, Algorithm: AlgRS256, public: k}, nil
+	case ed25519.PublicKey:
+		return &SigningKey{ID: id, Algorithm: AlgEdDSA, public: k}, nil
+	}
+	return nil, fmt.Errorf("key %s: unsupported public key type %T", id, parsed)
+}
+
+// Helper function to read the first PEM block of a file
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+	return block, nil
+}